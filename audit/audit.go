@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+// 审计覆盖的操作类型，与 kom callback 的处理器名称保持一致
+const (
+	VerbCreate      = "create"
+	VerbUpdate      = "update"
+	VerbPatch       = "patch"
+	VerbDelete      = "delete"
+	VerbExec        = "exec"
+	VerbStreamExec  = "stream-exec"
+	VerbPortForward = "port-forward"
+
+	// Ctl 高层操作
+	VerbDrain     = "drain"
+	VerbCordon    = "cordon"
+	VerbTaint     = "taint"
+	VerbRollout   = "rollout"
+	VerbScale     = "scale"
+	VerbImage     = "image"
+	VerbNodeShell = "node-shell"
+	VerbTerminal  = "terminal"
+	VerbDebug     = "debug"
+)
+
+// 执行结果
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// DefaultVerbs 默认审计的操作，包括所有变更类操作、容器内执行命令、端口转发以及 Ctl 高层操作
+var DefaultVerbs = []string{
+	VerbCreate, VerbUpdate, VerbPatch, VerbDelete, VerbExec, VerbStreamExec, VerbPortForward,
+	VerbDrain, VerbCordon, VerbTaint, VerbRollout, VerbScale, VerbImage, VerbNodeShell, VerbTerminal, VerbDebug,
+}
+
+// Event 一条审计记录
+type Event struct {
+	Time      time.Time              `json:"time"`
+	Identity  string                 `json:"identity,omitempty"`  // 操作者身份，从context中获取
+	Cluster   string                 `json:"cluster"`             // 集群ID
+	Verb      string                 `json:"verb"`                // 操作类型
+	Group     string                 `json:"group,omitempty"`     // 资源组
+	Version   string                 `json:"version,omitempty"`   // 资源版本
+	Kind      string                 `json:"kind,omitempty"`      // 资源类型
+	Namespace string                 `json:"namespace,omitempty"` // 命名空间
+	Name      string                 `json:"name,omitempty"`      // 资源名称
+	PatchType string                 `json:"patchType,omitempty"` // PATCH类型
+	PatchData string                 `json:"patchData,omitempty"` // PATCH数据，已脱敏
+	Container string                 `json:"container,omitempty"` // 容器名称，exec、port-forward 使用
+	Command   []string               `json:"command,omitempty"`   // 容器内执行的命令及参数
+	Ports     string                 `json:"ports,omitempty"`     // 端口转发，格式 localPort:podPort
+	Action    string                 `json:"action,omitempty"`    // Ctl 高层操作，如 drain、undo，底层操作属于某个高层操作时同样记录
+	Params    map[string]string      `json:"params,omitempty"`    // Ctl 高层操作参数
+	Object    map[string]interface{} `json:"object,omitempty"`    // 提交的对象，已脱敏，需开启 WithObject
+	Outcome   string                 `json:"outcome"`             // success 或 failure
+	Error     string                 `json:"error,omitempty"`     // 失败原因
+	Duration  time.Duration          `json:"duration"`            // 执行耗时
+}
+
+// Auditor 审计器，通过 callback 机制挂载到集群的各个处理器上
+type Auditor struct {
+	sinks        []Sink
+	verbs        []string
+	identityFunc func(ctx context.Context) string
+	redactor     *Redactor
+	recordObject bool
+}
+
+// Option 审计器配置项
+type Option func(*Auditor)
+
+// WithSink 增加一个审计输出，可多次调用
+func WithSink(s Sink) Option {
+	return func(a *Auditor) { a.sinks = append(a.sinks, s) }
+}
+
+// WithVerbs 设置需要审计的操作，默认为 DefaultVerbs
+func WithVerbs(verbs ...string) Option {
+	return func(a *Auditor) { a.verbs = verbs }
+}
+
+// WithIdentityKey 从 context 中按 key 读取操作者身份
+// 与 MCP Server 的 AuthKey 配合使用，即可记录 MCP 调用者
+func WithIdentityKey(key any) Option {
+	return func(a *Auditor) {
+		a.identityFunc = func(ctx context.Context) string {
+			if v := ctx.Value(key); v != nil {
+				return fmt.Sprintf("%v", v)
+			}
+			return ""
+		}
+	}
+}
+
+// WithIdentityFunc 自定义从 context 中解析操作者身份的方法
+func WithIdentityFunc(fn func(ctx context.Context) string) Option {
+	return func(a *Auditor) { a.identityFunc = fn }
+}
+
+// WithRedactor 自定义脱敏规则，默认为 DefaultRedactor()
+func WithRedactor(r *Redactor) Option {
+	return func(a *Auditor) { a.redactor = r }
+}
+
+// WithObject 记录 create、update 提交的对象内容（已脱敏）
+func WithObject() Option {
+	return func(a *Auditor) { a.recordObject = true }
+}
+
+// New 创建审计器
+func New(opts ...Option) *Auditor {
+	a := &Auditor{
+		verbs:    DefaultVerbs,
+		redactor: DefaultRedactor(),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(a)
+		}
+	}
+	return a
+}
+
+// Register 创建审计器并注册到指定集群
+// Example:
+// audit.Register(kom.Cluster("default"), audit.WithSink(audit.NewRingBuffer(1000)), audit.WithIdentityKey("username"))
+func Register(k *kom.Kubectl, opts ...Option) (*Auditor, error) {
+	a := New(opts...)
+	if err := a.Register(k); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Register 将审计回调注册到指定集群
+// 在收尾回调中生成审计记录，开始时间取自 Statement.StartedAt，因此失败的操作同样会被记录
+func (a *Auditor) Register(k *kom.Kubectl) error {
+	if k == nil {
+		return fmt.Errorf("audit register: kubectl is nil")
+	}
+	cb := k.Callback()
+	if cb == nil {
+		return fmt.Errorf("audit register: cluster %s callbacks not initialized", k.ID)
+	}
+	for _, verb := range a.verbs {
+		p := cb.Processor(verb)
+		if p == nil {
+			return fmt.Errorf("audit register: unsupported verb %s", verb)
+		}
+		if err := p.Finally().Register("kom:audit", a.Finish(verb)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finish 返回生成审计记录的收尾回调
+func (a *Auditor) Finish(verb string) func(k *kom.Kubectl) error {
+	return func(k *kom.Kubectl) error {
+		e := a.buildEvent(k, verb)
+		for _, s := range a.sinks {
+			if err := s.Write(e); err != nil {
+				klog.V(2).Infof("audit sink write error: %v", err)
+			}
+		}
+		return nil
+	}
+}
+
+// buildEvent 根据 Statement 生成审计记录
+func (a *Auditor) buildEvent(k *kom.Kubectl, verb string) *Event {
+	stmt := k.Statement
+	now := time.Now()
+	e := &Event{
+		Time:      now,
+		Cluster:   k.ID,
+		Verb:      verb,
+		Group:     stmt.GVK.Group,
+		Version:   stmt.GVK.Version,
+		Kind:      stmt.GVK.Kind,
+		Namespace: stmt.Namespace,
+		Name:      stmt.Name,
+		Outcome:   OutcomeSuccess,
+	}
+	if start := stmt.StartedAt(); !start.IsZero() {
+		e.Time = start
+		e.Duration = now.Sub(start)
+	}
+	if a.identityFunc != nil && stmt.Context != nil {
+		e.Identity = a.identityFunc(stmt.Context)
+	}
+	if k.Error != nil {
+		e.Outcome = OutcomeFailure
+		e.Error = k.Error.Error()
+	}
+	if stmt.CtlAction != nil {
+		e.Action = stmt.CtlAction.Action
+		e.Params = stmt.CtlAction.Params
+	}
+
+	switch verb {
+	case VerbPatch:
+		e.PatchType = string(stmt.PatchType)
+		e.PatchData = a.redactor.RedactPatch(e.Kind, stmt.PatchData)
+	case VerbExec, VerbStreamExec, VerbTerminal:
+		e.Container = stmt.ContainerName
+		if stmt.Command != "" {
+			e.Command = append([]string{stmt.Command}, stmt.Args...)
+		}
+	case VerbPortForward:
+		e.Container = stmt.ContainerName
+		e.Ports = fmt.Sprintf("%s:%s", stmt.PortForwardLocalPort, stmt.PortForwardPodPort)
+	case VerbCreate, VerbUpdate:
+		if a.recordObject && stmt.Dest != nil {
+			if obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(stmt.Dest); err == nil {
+				e.Object = a.redactor.RedactObject(e.Kind, obj)
+			}
+		}
+	}
+	return e
+}
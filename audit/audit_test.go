@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newStatementKubectl(kind string) *kom.Kubectl {
+	k := &kom.Kubectl{ID: "audit-cluster"}
+	k.Statement = &kom.Statement{
+		Kubectl:   k,
+		Context:   context.WithValue(context.Background(), "username", "alice"),
+		GVK:       schema.GroupVersionKind{Version: "v1", Kind: kind},
+		Namespace: "default",
+		Name:      "demo",
+	}
+	return k
+}
+
+func TestAuditorPatchEvent(t *testing.T) {
+	buf := NewRingBuffer(10)
+	a := New(WithSink(buf), WithIdentityKey("username"))
+
+	k := newStatementKubectl("Secret")
+	k.Statement.PatchType = types.MergePatchType
+	k.Statement.PatchData = `{"data":{"password":"cGFzcw=="},"metadata":{"labels":{"a":"b"}}}`
+
+	_ = a.Finish(VerbPatch)(k)
+
+	events := buf.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	if e.Identity != "alice" || e.Cluster != "audit-cluster" || e.Verb != VerbPatch || e.Outcome != OutcomeSuccess {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if strings.Contains(e.PatchData, "cGFzcw==") || !strings.Contains(e.PatchData, RedactedValue) {
+		t.Errorf("patch data not redacted: %s", e.PatchData)
+	}
+	if !strings.Contains(e.PatchData, `"a":"b"`) {
+		t.Errorf("non sensitive fields should be kept: %s", e.PatchData)
+	}
+}
+
+func TestAuditorFailureAndExec(t *testing.T) {
+	var got []*Event
+	a := New(WithSink(SinkFunc(func(e *Event) error {
+		got = append(got, e)
+		return nil
+	})))
+
+	k := newStatementKubectl("Pod")
+	k.Statement.Command = "ls"
+	k.Statement.Args = []string{"-l", "/"}
+	k.Statement.ContainerName = "nginx"
+	k.Error = fmt.Errorf("exec failed")
+
+	_ = a.Finish(VerbExec)(k)
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	e := got[0]
+	if e.Outcome != OutcomeFailure || e.Error != "exec failed" {
+		t.Errorf("unexpected outcome %s %s", e.Outcome, e.Error)
+	}
+	if strings.Join(e.Command, " ") != "ls -l /" || e.Container != "nginx" {
+		t.Errorf("unexpected command %v container %s", e.Command, e.Container)
+	}
+	if e.Identity != "" {
+		t.Errorf("identity should be empty without identity option, got %s", e.Identity)
+	}
+}
+
+func TestAuditorCtlAction(t *testing.T) {
+	buf := NewRingBuffer(10)
+	a := New(WithSink(buf))
+
+	k := newStatementKubectl("Node")
+	k.Statement.CtlAction = &kom.CtlAction{Processor: VerbTaint, Action: "untaint", Params: map[string]string{"taint": "k:NoSchedule"}}
+	_ = a.Finish(VerbTaint)(k)
+
+	e := buf.Events()[0]
+	if e.Verb != VerbTaint || e.Action != "untaint" || e.Params["taint"] != "k:NoSchedule" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	r := DefaultRedactor()
+	obj := map[string]interface{}{
+		"kind":       "Secret",
+		"data":       map[string]interface{}{"token": "abc"},
+		"stringData": map[string]interface{}{"password": "p", "removed": nil},
+	}
+	out := r.RedactObject("", obj)
+	if out["data"].(map[string]interface{})["token"] != RedactedValue {
+		t.Errorf("data not redacted: %v", out)
+	}
+	if v, ok := out["stringData"].(map[string]interface{})["removed"]; !ok || v != nil {
+		t.Errorf("null value should be kept: %v", out)
+	}
+	if obj["data"].(map[string]interface{})["token"] != "abc" {
+		t.Errorf("original object should not be modified")
+	}
+
+	jsonPatch := r.RedactPatch("Secret", `[{"op":"replace","path":"/data/token","value":"abc"},{"op":"remove","path":"/metadata/labels/x"}]`)
+	if strings.Contains(jsonPatch, "abc") {
+		t.Errorf("json patch not redacted: %s", jsonPatch)
+	}
+	if r.RedactPatch("Secret", "not json") != RedactedValue {
+		t.Errorf("invalid patch should be fully redacted")
+	}
+	if p := r.RedactPatch("ConfigMap", `{"data":{"a":"b"}}`); p != `{"data":{"a":"b"}}` {
+		t.Errorf("non sensitive kind should not be redacted: %s", p)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	buf := NewRingBuffer(3)
+	for i := 0; i < 5; i++ {
+		_ = buf.Write(&Event{Name: fmt.Sprintf("e%d", i)})
+	}
+	events := buf.Events()
+	if len(events) != 3 || events[0].Name != "e2" || events[2].Name != "e4" {
+		t.Errorf("unexpected ring buffer events %v", events)
+	}
+}
+
+func TestFileSinkRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	s, err := NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := s.Write(&Event{Verb: VerbDelete, Name: fmt.Sprintf("obj-%d", i)}); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+	_ = s.Close()
+
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("expected file %s: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Errorf("backups should be limited to 2")
+	}
+
+	f, _ := os.Open(path)
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Errorf("invalid json line %s", scanner.Text())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/duke-git/lancet/v2/slice"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RedactedValue 脱敏后的占位值
+const RedactedValue = "******"
+
+// Redactor 审计记录脱敏规则
+// 对于 Kinds 中的资源，Fields 中列出的顶层字段下的所有值都会被替换为 RedactedValue
+type Redactor struct {
+	Kinds  []string // 需要脱敏的资源类型，如 Secret
+	Fields []string // 需要脱敏的顶层字段，如 data、stringData
+}
+
+// DefaultRedactor 默认脱敏规则：Secret 的 data、stringData
+func DefaultRedactor() *Redactor {
+	return &Redactor{
+		Kinds:  []string{"Secret"},
+		Fields: []string{"data", "stringData"},
+	}
+}
+
+func (r *Redactor) match(kind string) bool {
+	return r != nil && slice.Contain(r.Kinds, kind)
+}
+
+// RedactObject 对对象进行脱敏，返回脱敏后的副本，不修改原对象
+func (r *Redactor) RedactObject(kind string, obj map[string]interface{}) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	if kind == "" {
+		kind, _ = obj["kind"].(string)
+	}
+	if !r.match(kind) {
+		return obj
+	}
+	out := runtime.DeepCopyJSON(obj)
+	r.redactFields(out)
+	return out
+}
+
+// RedactPatch 对 PATCH 数据进行脱敏
+// 支持 JSON Merge Patch、Strategic Merge Patch 以及 JSON Patch 三种格式
+// 无法解析的 PATCH 数据将整体脱敏
+func (r *Redactor) RedactPatch(kind string, data string) string {
+	if data == "" || !r.match(kind) {
+		return data
+	}
+	var raw interface{}
+	if err := json.Unmarshal([]byte(data), &raw); err != nil {
+		return RedactedValue
+	}
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		r.redactFields(v)
+	case []interface{}:
+		// JSON Patch: [{"op":"add","path":"/data/key","value":"xxx"}]
+		for _, item := range v {
+			op, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			path, _ := op["path"].(string)
+			for _, f := range r.Fields {
+				if path == "/"+f || strings.HasPrefix(path, "/"+f+"/") {
+					if _, exists := op["value"]; exists {
+						op["value"] = redactValue(op["value"])
+					}
+				}
+			}
+		}
+	}
+	bytes, err := json.Marshal(raw)
+	if err != nil {
+		return RedactedValue
+	}
+	return string(bytes)
+}
+
+func (r *Redactor) redactFields(obj map[string]interface{}) {
+	for _, f := range r.Fields {
+		if v, ok := obj[f]; ok && v != nil {
+			obj[f] = redactValue(v)
+		}
+	}
+}
+
+// redactValue 保留结构（key），仅替换值
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if item == nil {
+				// null 表示删除该key，保留
+				out[k] = nil
+				continue
+			}
+			out[k] = redactValue(item)
+		}
+		return out
+	default:
+		return RedactedValue
+	}
+}
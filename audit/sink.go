@@ -0,0 +1,152 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Sink 审计记录输出
+type Sink interface {
+	Write(e *Event) error
+}
+
+// SinkFunc 使用函数作为审计输出
+type SinkFunc func(e *Event) error
+
+func (f SinkFunc) Write(e *Event) error {
+	return f(e)
+}
+
+// RingBuffer 内存环形缓冲区，只保留最近的 size 条审计记录
+type RingBuffer struct {
+	mu     sync.RWMutex
+	events []Event
+	next   int
+	full   bool
+}
+
+// NewRingBuffer 创建环形缓冲区，size 小于等于0时使用默认值1000
+func NewRingBuffer(size int) *RingBuffer {
+	if size <= 0 {
+		size = 1000
+	}
+	return &RingBuffer{events: make([]Event, size)}
+}
+
+func (r *RingBuffer) Write(e *Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[r.next] = *e
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+	return nil
+}
+
+// Events 按时间先后顺序返回缓冲区中的审计记录
+func (r *RingBuffer) Events() []Event {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if !r.full {
+		return append([]Event(nil), r.events[:r.next]...)
+	}
+	result := make([]Event, 0, len(r.events))
+	result = append(result, r.events[r.next:]...)
+	result = append(result, r.events[:r.next]...)
+	return result
+}
+
+// FileSink 以 JSON Lines 格式写入文件，文件超过大小限制时进行轮转
+// 轮转后的文件依次命名为 path.1、path.2 ...，最多保留 maxBackups 个
+type FileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileSink 创建文件输出
+// maxSize 单个文件最大字节数，小于等于0表示不轮转
+// maxBackups 保留的历史文件个数，小于等于0时默认为1
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	if maxBackups <= 0 {
+		maxBackups = 1
+	}
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open audit file %s error: %w", s.path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("stat audit file %s error: %w", s.path, err)
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) Write(e *Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return fmt.Errorf("audit file %s is closed", s.path)
+	}
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// rotate 关闭当前文件，依次后移历史文件，再重新打开
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	s.file = nil
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		src := fmt.Sprintf("%s.%d", s.path, i)
+		if _, err := os.Stat(src); err == nil {
+			_ = os.Rename(src, fmt.Sprintf("%s.%d", s.path, i+1))
+		}
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("rotate audit file %s error: %w", s.path, err)
+	}
+	return s.open()
+}
+
+// Close 关闭文件
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
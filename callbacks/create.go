@@ -25,14 +25,18 @@ func Create(k *kom.Kubectl) error {
 	unstructuredObj.SetUnstructuredContent(unstructuredData)
 	var res *unstructured.Unstructured
 
+	createOptions := metav1.CreateOptions{}
+	if stmt.DryRun {
+		createOptions.DryRun = []string{metav1.DryRunAll}
+	}
 	if namespaced {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 			unstructuredObj.SetNamespace(ns)
 		}
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, unstructuredObj, createOptions)
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Create(ctx, unstructuredObj, metav1.CreateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Create(ctx, unstructuredObj, createOptions)
 	}
 
 	if err != nil {
@@ -42,5 +46,10 @@ func Create(k *kom.Kubectl) error {
 	if stmt.RemoveManagedFields {
 		utils.RemoveManagedFields(res)
 	}
+	// 将 unstructured 转换回原始对象，DryRun 时即为准入变更后的结果
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, stmt.Dest)
+	if err != nil {
+		return err
+	}
 	return nil
 }
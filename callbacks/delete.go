@@ -24,6 +24,9 @@ func Delete(k *kom.Kubectl) error {
 		deleteOptions.PropagationPolicy = &background
 		deleteOptions.GracePeriodSeconds = utils.Int64Ptr(0)
 	}
+	if stmt.DryRun {
+		deleteOptions.DryRun = []string{metav1.DryRunAll}
+	}
 
 	var err error
 	if name == "" {
@@ -56,8 +56,23 @@ func List(k *kom.Kubectl) error {
 	elemType := destValue.Elem().Type().Elem()
 
 	cacheKey := fmt.Sprintf("%s/%s/%s/%s/%s", ns, gvr.Group, gvr.Resource, gvr.Version, listOptionsMD5)
+	if stmt.MetadataOnly {
+		cacheKey += "/metadata"
+	}
 	list, err := utils.GetOrSetCache(stmt.ClusterCache(), cacheKey, stmt.CacheTTL, func() (list *unstructured.UnstructuredList, err error) {
 		// TODO 获取列表改为使用Option,解决大数据量获取问题。
+		if stmt.MetadataOnly {
+			// 只获取 metadata，命名空间的处理与下方一致
+			switch {
+			case !namespaced:
+				ns = ""
+			case stmt.AllNamespace || len(namespaceList) > 1:
+				ns = metav1.NamespaceAll
+			case ns == "":
+				ns = metav1.NamespaceDefault
+			}
+			return listMetadata(k, ns, listOptions)
+		}
 		if namespaced {
 			if stmt.AllNamespace || len(namespaceList) > 1 {
 				// 全部命名空间 或者  传入多个命名空间
@@ -139,6 +154,31 @@ func List(k *kom.Kubectl) error {
 	return nil
 }
 
+// listMetadata 通过元数据客户端获取列表，只包含 metadata，并按资源类型设置 apiVersion、kind
+func listMetadata(k *kom.Kubectl, ns string, listOptions metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	stmt := k.Statement
+	client := k.MetadataClient()
+	if client == nil {
+		return nil, fmt.Errorf("metadata client is nil")
+	}
+	metaList, err := client.Resource(stmt.GVR).Namespace(ns).List(stmt.Context, listOptions)
+	if err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 0, len(metaList.Items))}
+	list.SetResourceVersion(metaList.GetResourceVersion())
+	for i := range metaList.Items {
+		metadata, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&metaList.Items[i].ObjectMeta)
+		if err != nil {
+			return nil, err
+		}
+		item := unstructured.Unstructured{Object: map[string]interface{}{"metadata": metadata}}
+		item.SetGroupVersionKind(stmt.GVK)
+		list.Items = append(list.Items, item)
+	}
+	return list, nil
+}
+
 func executeOrderBy(result []*unstructured.Unstructured, order string) {
 	// order by `metadata.name` asc, `metadata.host` asc
 	// todo 目前只实现了单一字段的排序，还没有搞定多个字段的排序
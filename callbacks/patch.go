@@ -8,6 +8,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 func Patch(k *kom.Kubectl) error {
@@ -27,13 +28,24 @@ func Patch(k *kom.Kubectl) error {
 		err = fmt.Errorf("patch对象必须指定名称")
 		return err
 	}
+	patchOptions := metav1.PatchOptions{}
+	if stmt.DryRun {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+	if stmt.FieldManager != "" {
+		patchOptions.FieldManager = stmt.FieldManager
+	}
+	if patchType == types.ApplyPatchType {
+		// Force 仅对服务端应用生效，其他类型的 PATCH 携带该参数会被 API Server 拒绝
+		patchOptions.Force = &stmt.ForceConflicts
+	}
 	if namespaced {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, []byte(patchData), patchOptions)
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, []byte(patchData), metav1.PatchOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, []byte(patchData), patchOptions)
 	}
 	if err != nil {
 		return err
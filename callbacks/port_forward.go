@@ -2,19 +2,21 @@ package callbacks
 
 import (
 	"fmt"
+	"io"
 	"net/http"
-	"os"
 
 	"github.com/weibaohui/kom/kom"
+	"k8s.io/apimachinery/pkg/util/httpstream"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
 	"k8s.io/klog/v2"
 )
 
 // PortForward 建立本地端口到指定 Kubernetes Pod 端口的端口转发会话。
-// 
-// 如果未设置本地端口或 Pod 端口，则返回错误。端口转发会监听多个本地地址，并在连接建立后异步通知就绪状态。
-// 
+//
+// 如果未设置本地端口或 Pod 端口，则返回错误。未设置 Statement.PortForwardRequest 时只监听 localhost。
+// 设置了 Statement.PortForwardRequest 时，按其中的监听地址、端口映射执行，并在就绪后回调实际监听的端口。
+//
 // 返回端口转发过程中的任何错误。
 func PortForward(k *kom.Kubectl) error {
 
@@ -25,15 +27,16 @@ func PortForward(k *kom.Kubectl) error {
 	podPort := stmt.PortForwardPodPort
 	localPort := stmt.PortForwardLocalPort
 	containerName := stmt.ContainerName
+	request := stmt.PortForwardRequest
 
 	// 检查端口，必须设置
-	if localPort == "" || podPort == "" {
+	if request == nil && (localPort == "" || podPort == "") {
 		return fmt.Errorf("localPort and podPort must be set")
 	}
-
-	if stopCh == nil {
-		stopCh = make(chan struct{}, 1)
+	if request != nil && len(request.Ports) == 0 {
+		return fmt.Errorf("ports must be set")
 	}
+
 	req := k.Client().CoreV1().RESTClient().
 		Post().
 		Namespace(ns).
@@ -41,8 +44,6 @@ func PortForward(k *kom.Kubectl) error {
 		Resource("pods").
 		SubResource("portforward").
 		Param("container", containerName)
-	 
-	readyChan := make(chan struct{})
 
 	// 创建 PortForward 请求
 	transport, upgrader, err := spdy.RoundTripperFor(k.RestConfig())
@@ -51,18 +52,62 @@ func PortForward(k *kom.Kubectl) error {
 	}
 	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
 
-	addresses := []string{"localhost", "127.0.0.1", "0.0.0.0"}
-	ports := []string{fmt.Sprintf("%s:%s", localPort, podPort)}
-	forwarder, err := portforward.NewOnAddresses(dialer, addresses, ports, stopCh, readyChan, os.Stdout, os.Stderr)
+	if request == nil {
+		// 未设置 PortForwardRequest 时只监听 localhost，日志输出丢弃
+		request = &kom.PortForwardRequest{
+			Ports:  []string{fmt.Sprintf("%s:%s", localPort, podPort)},
+			StopCh: stopCh,
+		}
+	}
+	return forwardRequest(dialer, request, ns, name)
+}
+
+// forwardRequest 按 PortForwardRequest 执行端口转发，阻塞直到 StopCh 关闭或连接中断
+func forwardRequest(dialer httpstream.Dialer, request *kom.PortForwardRequest, ns, name string) error {
+	addresses := request.Addresses
+	if len(addresses) == 0 {
+		addresses = []string{"localhost"}
+	}
+	stopCh := request.StopCh
+	if stopCh == nil {
+		stopCh = make(chan struct{})
+	}
+	out, errOut := request.Out, request.ErrOut
+	if out == nil {
+		out = io.Discard
+	}
+	if errOut == nil {
+		errOut = io.Discard
+	}
+
+	readyChan := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, addresses, request.Ports, stopCh, readyChan, out, errOut)
 	if err != nil {
 		return fmt.Errorf("failed to create forwarder: %w", err)
 	}
 
+	finished := make(chan struct{})
+	defer close(finished)
 	go func() {
-		<-readyChan
-		klog.V(6).Infof("Port forwarding ready: 0.0.0.0:%s -> %s/%s:%s\n", localPort, ns, name, podPort)
+		select {
+		case <-readyChan:
+		case <-finished:
+			return
+		}
+		forwarded, err := forwarder.GetPorts()
+		if err != nil {
+			klog.V(6).Infof("Port forwarding get ports error: %v", err)
+			return
+		}
+		ports := make([]kom.ForwardedPort, 0, len(forwarded))
+		for _, p := range forwarded {
+			ports = append(ports, kom.ForwardedPort{Local: p.Local, Remote: p.Remote})
+		}
+		klog.V(6).Infof("Port forwarding ready: %v %v -> %s/%s", addresses, ports, ns, name)
+		if request.OnReady != nil {
+			request.OnReady(ports)
+		}
 	}()
 
 	return forwarder.ForwardPorts()
-
 }
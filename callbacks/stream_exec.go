@@ -52,12 +52,21 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 		req.Param("command", arg)
 	}
 
-	//	如果设置 tty=true，但没有 stdin=true，可能导致命令执行失败或挂起
-	//	某些命令如 bash、top 在 tty=false 下会拒绝运行或自动退出
-	req.Param("tty", fmt.Sprintf("%v", stmt.Stdin != nil)).
-		Param("stdin", fmt.Sprintf("%v", stmt.Stdin != nil)).
-		Param("stdout", "true").
-		Param("stderr", "true")
+	if stmt.ExecTTY != nil && stmt.StreamOptions != nil {
+		// StartExec 显式指定 TTY，按 StreamOptions 设置各个流，TTY 模式下 stderr 合并到 stdout 中
+		opts := stmt.StreamOptions
+		req.Param("tty", fmt.Sprintf("%v", *stmt.ExecTTY)).
+			Param("stdin", fmt.Sprintf("%v", opts.Stdin != nil)).
+			Param("stdout", fmt.Sprintf("%v", opts.Stdout != nil)).
+			Param("stderr", fmt.Sprintf("%v", opts.Stderr != nil && !*stmt.ExecTTY))
+	} else {
+		//	如果设置 tty=true，但没有 stdin=true，可能导致命令执行失败或挂起
+		//	某些命令如 bash、top 在 tty=false 下会拒绝运行或自动退出
+		req.Param("tty", fmt.Sprintf("%v", stmt.Stdin != nil)).
+			Param("stdin", fmt.Sprintf("%v", stmt.Stdin != nil)).
+			Param("stdout", "true").
+			Param("stderr", "true")
+	}
 
 	executor, err := createExecutor(req.URL(), k.RestConfig())
 	if err != nil {
@@ -120,7 +129,8 @@ func StreamExecuteCommand(k *kom.Kubectl) error {
 	err = executor.StreamWithContext(ctx, *stmt.StreamOptions)
 	if err != nil {
 		klog.V(8).Infof("Error Stream executing command: %v", err)
-		return fmt.Errorf("error Stream executing command: %v", err)
+		// 使用 %w 保留原始错误，调用方可以从中获取命令的退出码
+		return fmt.Errorf("error Stream executing command: %w", err)
 	}
 
 	return nil
@@ -27,14 +27,18 @@ func Update(k *kom.Kubectl) error {
 
 	var res *unstructured.Unstructured
 
+	updateOptions := metav1.UpdateOptions{}
+	if stmt.DryRun {
+		updateOptions.DryRun = []string{metav1.DryRunAll}
+	}
 	if namespaced {
 		if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
 		unstructuredObj.SetNamespace(ns)
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Update(ctx, unstructuredObj, metav1.UpdateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Update(ctx, unstructuredObj, updateOptions)
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Update(ctx, unstructuredObj, metav1.UpdateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Update(ctx, unstructuredObj, updateOptions)
 	}
 
 	if err != nil {
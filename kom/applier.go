@@ -1,77 +1,273 @@
 package kom
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/weibaohui/kom/utils"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"sigs.k8s.io/yaml"
 )
 
 type applier struct {
 	kubectl *Kubectl
 }
 
-func (a *applier) Apply(str string) (result []string) {
-	docs := splitYAML(str)
+// ApplyOption Apply 配置项
+type ApplyOption func(*applyOptions)
 
-	for _, doc := range docs {
-		if strings.TrimSpace(doc) == "" {
-			continue
-		}
-		// 解析 YAML 到 Unstructured 对象
+type applyOptions struct {
+	threeWayMerge      bool          // 使用 last-applied-configuration 进行三路合并
+	crdEstablishedWait time.Duration // 等待同批次新建的 CRD 就绪的超时时间
+	setID              string        // 应用集合 ID，设置后清理集合中已移除的资源
+}
 
-		obj := &unstructured.Unstructured{}
-		var raw map[string]interface{}
-		if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
-			result = append(result, fmt.Sprintf("YAML 解析失败: %v", err))
-			continue
+// ApplyThreeWayMerge 使用与 kubectl apply（客户端应用）一致的三路合并
+// 创建及更新时记录 kubectl.kubernetes.io/last-applied-configuration 注解，
+// 更新时根据上次应用的配置、本次 YAML 及线上对象计算补丁：YAML 中删除的字段会从线上对象中删除，其他控制器设置的字段保持不变。
+// 内置资源使用 strategic merge patch，CRD 使用 JSON merge patch
+func ApplyThreeWayMerge() ApplyOption {
+	return func(o *applyOptions) {
+		o.threeWayMerge = true
+	}
+}
+
+// ApplyCRDEstablishedTimeout 设置等待同批次 CRD 就绪的超时时间，默认 1 分钟
+func ApplyCRDEstablishedTimeout(timeout time.Duration) ApplyOption {
+	return func(o *applyOptions) {
+		o.crdEstablishedWait = timeout
+	}
+}
+
+func newApplyOptions(opts []ApplyOption) *applyOptions {
+	options := &applyOptions{
+		crdEstablishedWait: time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
 		}
-		obj.Object = raw
-		result = append(result, a.createOrUpdateCRD(obj))
 	}
+	return options
+}
+
+// 资源应用结果
+const (
+	ApplyActionCreated   = "created"
+	ApplyActionUpdated   = "updated"
+	ApplyActionUnchanged = "unchanged"
+	ApplyActionFailed    = "failed"
+	ApplyActionDeleted   = "deleted"
+)
+
+// ApplyResult 单个资源的应用结果
+type ApplyResult struct {
+	GVK       schema.GroupVersionKind    `json:"gvk"`
+	Namespace string                     `json:"namespace,omitempty"`
+	Name      string                     `json:"name,omitempty"`
+	Action    string                     `json:"action"`              // created、updated、unchanged、deleted、failed
+	DryRun    bool                       `json:"dryRun,omitempty"`    // 是否为服务端试运行
+	Conflicts []ApplyConflict            `json:"conflicts,omitempty"` // 字段冲突，仅服务端应用失败时存在
+	Error     error                      `json:"-"`
+	Object    *unstructured.Unstructured `json:"-"`               // API Server 返回的对象
+	Ready     *ReadyStatus               `json:"ready,omitempty"` // 就绪状态，仅 ApplyAndWait 时存在
+}
 
+// String 输出文本结果，与 Apply 返回的内容一致
+func (r *ApplyResult) String() string {
+	if r.Error != nil {
+		return r.Error.Error()
+	}
+	suffix := ""
+	if r.DryRun {
+		suffix = " (server dry run)"
+	}
+	return fmt.Sprintf("%s/%s %s%s", r.GVK.Kind, r.Name, r.Action, suffix)
+}
+
+// newApplyResult 根据对象创建应用结果
+func (a *applier) newApplyResult(obj *unstructured.Unstructured) *ApplyResult {
+	return &ApplyResult{
+		GVK:       obj.GroupVersionKind(),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		DryRun:    a.kubectl.Statement.DryRun,
+	}
+}
+
+// fail 记录失败原因
+func (r *ApplyResult) fail(err error) *ApplyResult {
+	r.Action = ApplyActionFailed
+	r.Error = err
+	r.Conflicts = ParseApplyConflicts(err)
+	return r
+}
+
+// Apply 创建或更新 YAML 中的资源，返回每个资源的执行结果
+// 默认使用 Update 整体覆盖已存在的资源，执行顺序及结果详见 ApplyWithResult
+func (a *applier) Apply(str string, opts ...ApplyOption) (result []string) {
+	for _, r := range a.ApplyWithResult(str, opts...) {
+		result = append(result, r.String())
+	}
 	return result
 }
-func (a *applier) Delete(str string) (result []string) {
-	docs := splitYAML(str)
 
-	for _, doc := range docs {
-		if strings.TrimSpace(doc) == "" {
+// ApplyWithResult 创建或更新 YAML 中的资源，返回每个资源的结构化结果
+// 资源按依赖关系排序后依次应用：Namespace、CRD、RBAC、ConfigMap/Secret、Service、工作负载，最后是自定义资源；
+// 同一批次中创建或更新的 CRD，会等待其 Established 后再应用对应的自定义资源
+func (a *applier) ApplyWithResult(str string, opts ...ApplyOption) []*ApplyResult {
+	options := newApplyOptions(opts)
+	return a.applyAll(str, options, func(obj *unstructured.Unstructured) *ApplyResult {
+		if options.threeWayMerge {
+			return a.threeWayApply(obj)
+		}
+		return a.createOrUpdateCRD(obj)
+	})
+}
+
+// ApplyAndWait 创建或更新 YAML 中的资源，并等待应用成功的资源就绪
+// 就绪条件详见 Kubectl.WaitReady，各资源并行等待，共用超时时间；每个资源的就绪状态记录在 ApplyResult.Ready 中。
+// 存在应用失败、超时未就绪或进入失败状态的资源时返回错误。试运行（DryRun）时不等待
+//
+// Example:
+// results, err := kom.DefaultCluster().Applier().ApplyAndWait(yaml, 5*time.Minute)
+func (a *applier) ApplyAndWait(str string, timeout time.Duration, opts ...ApplyOption) ([]*ApplyResult, error) {
+	results := a.ApplyWithResult(str, opts...)
+	if a.kubectl.Statement.DryRun {
+		return results, nil
+	}
+	ctx, cancel := context.WithTimeout(a.kubectl.Statement.Context, timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var statuses []*ReadyStatus
+	var failed []string
+	for _, r := range results {
+		if r.Error != nil {
+			failed = append(failed, r.String())
 			continue
 		}
-		// 解析 YAML 到 Unstructured 对象
-		obj := &unstructured.Unstructured{}
-		var raw map[string]interface{}
-		if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
-			result = append(result, fmt.Sprintf("YAML 解析失败: %v", err))
+		if r.Action == ApplyActionDeleted {
 			continue
 		}
-		obj.Object = raw
-		result = append(result, a.deleteCRD(obj))
+		r.Ready = &ReadyStatus{GVK: r.GVK, Namespace: r.Namespace, Name: r.Name}
+		statuses = append(statuses, r.Ready)
+		wg.Add(1)
+		go func(r *ApplyResult) {
+			defer wg.Done()
+			*r.Ready = *a.kubectl.waitReady(ctx, r.GVK, r.Namespace, r.Name)
+		}(r)
+	}
+	wg.Wait()
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("apply failed: %s", strings.Join(failed, "; "))
+	}
+	return results, readyError(ctx, timeout, statuses)
+}
+
+// applyAll 解析并按依赖顺序应用 YAML 中的资源，设置了应用集合时，最后清理集合中已移除的资源
+func (a *applier) applyAll(str string, options *applyOptions, apply func(obj *unstructured.Unstructured) *ApplyResult) (results []*ApplyResult) {
+	objs, results := a.parseDocs(str)
+	var parent *v1.ConfigMap
+	var recorded []schema.GroupKind
+	if options.setID != "" {
+		if err := validateApplySetID(options.setID); err != nil {
+			return append(results, &ApplyResult{Action: ApplyActionFailed, Error: err})
+		}
+		for _, obj := range objs {
+			stampApplySet(obj, options.setID)
+		}
+		var err error
+		if parent, recorded, err = a.loadApplySet(options.setID); err != nil {
+			return append(results, &ApplyResult{Action: ApplyActionFailed, Error: err})
+		}
+		if !a.kubectl.Statement.DryRun {
+			// 应用前记录全部资源类型，中途失败时下次应用仍能清理
+			if parent, err = a.saveApplySet(options.setID, parent, objectGroupKinds(objs, recorded...), objs); err != nil {
+				return append(results, &ApplyResult{Action: ApplyActionFailed, Error: err})
+			}
+		}
 	}
+	sortByApplyOrder(objs)
 
+	pending := map[schema.GroupKind]string{} // 本批次应用的 CRD 所定义的资源 -> CRD 名称
+	for _, obj := range objs {
+		if crd, ok := pending[obj.GroupVersionKind().GroupKind()]; ok {
+			delete(pending, obj.GroupVersionKind().GroupKind())
+			if err := a.waitCRDEstablished(crd, obj.GroupVersionKind(), options.crdEstablishedWait); err != nil {
+				results = append(results, a.newApplyResult(obj).fail(err))
+				continue
+			}
+		}
+		result := apply(obj)
+		results = append(results, result)
+		if result.Error == nil && isCRD(obj.GroupVersionKind()) {
+			group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+			pending[schema.GroupKind{Group: group, Kind: kind}] = obj.GetName()
+		}
+	}
+	if options.setID != "" {
+		if hasFailed(results) {
+			err := fmt.Errorf("apply set %s: prune skipped because some objects failed to apply", options.setID)
+			return append(results, &ApplyResult{Action: ApplyActionFailed, Error: err})
+		}
+		pruned := a.prune(options.setID, objs, recorded)
+		results = append(results, pruned...)
+		if !a.kubectl.Statement.DryRun && !hasFailed(pruned) {
+			// 清理完成后只保留 YAML 中的资源类型
+			if _, err := a.saveApplySet(options.setID, parent, objectGroupKinds(objs), objs); err != nil {
+				results = append(results, &ApplyResult{Action: ApplyActionFailed, Error: err})
+			}
+		}
+	}
+	return results
+}
+
+// parseDocs 解析 YAML 或 JSON 清单，解析失败的文档直接返回失败结果
+func (a *applier) parseDocs(str string) (objs []*unstructured.Unstructured, failed []*ApplyResult) {
+	objs, errs := parseManifests(str)
+	for _, err := range errs {
+		failed = append(failed, &ApplyResult{Action: ApplyActionFailed, Error: fmt.Errorf("YAML 解析失败: %w", err)})
+	}
+	return objs, failed
+}
+
+// Delete 删除 YAML 或 JSON 清单中的资源，清单格式与 Apply 相同
+func (a *applier) Delete(str string) (result []string) {
+	objs, errs := parseManifests(str)
+	for _, err := range errs {
+		result = append(result, fmt.Sprintf("YAML 解析失败: %v", err))
+	}
+	for _, obj := range objs {
+		result = append(result, a.deleteCRD(obj))
+	}
 	return result
 }
-func (a *applier) createOrUpdateCRD(obj *unstructured.Unstructured) string {
+func (a *applier) createOrUpdateCRD(obj *unstructured.Unstructured) *ApplyResult {
+	result := a.newApplyResult(obj)
 	// 提取 Group, Version, Kind
 	gvk := obj.GroupVersionKind()
 	if gvk.Kind == "" || gvk.Version == "" {
-		return fmt.Sprintf("YAML 缺少必要的 Group, Version 或 Kind")
+		return result.fail(fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind"))
 	}
 
 	_, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{gvk})
 
 	ns := obj.GetNamespace()
 	name := obj.GetName()
-	kind := obj.GetKind()
 
 	if ns == "" && namespaced {
 		ns = metav1.NamespaceDefault // 默认命名空间
 		obj.SetNamespace(ns)
+		result.Namespace = ns
 	}
 	var cr *unstructured.Unstructured
 	err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&cr).Error
@@ -81,16 +277,24 @@ func (a *applier) createOrUpdateCRD(obj *unstructured.Unstructured) string {
 		obj.SetResourceVersion(cr.GetResourceVersion())
 		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Update(&obj).Error
 		if err != nil {
-			return fmt.Sprintf("update %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+			return result.fail(fmt.Errorf("update %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
+		}
+		result.Object = obj
+		result.Action = ApplyActionUpdated
+		if !result.DryRun && cr.GetResourceVersion() != "" && cr.GetResourceVersion() == obj.GetResourceVersion() {
+			// 内容没有变化时，API Server 不会更新 resourceVersion
+			result.Action = ApplyActionUnchanged
 		}
-		return fmt.Sprintf("%s/%s updated", kind, name)
+		return result
 	} else {
 		// 不存在，那么就创建
 		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Create(&obj).Error
 		if err != nil {
-			return fmt.Sprintf("create %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
+			return result.fail(fmt.Errorf("create %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
 		}
-		return fmt.Sprintf("%s/%s created", kind, name)
+		result.Object = obj
+		result.Action = ApplyActionCreated
+		return result
 	}
 }
 func (a *applier) deleteCRD(obj *unstructured.Unstructured) string {
@@ -105,11 +309,126 @@ func (a *applier) deleteCRD(obj *unstructured.Unstructured) string {
 	if err != nil {
 		return fmt.Sprintf("delete %s/%s,%s %s/%s error:%v", gvk.Group, gvk.Version, gvk.Kind, ns, name, err)
 	}
-	return fmt.Sprintf("%s/%s deleted", gvk.Kind, name)
+	return fmt.Sprintf("%s/%s deleted%s", gvk.Kind, name, a.dryRunSuffix())
+}
+
+// dryRunSuffix 试运行时在结果后追加标识，与 kubectl 保持一致
+func (a *applier) dryRunSuffix() string {
+	if a.kubectl.Statement.DryRun {
+		return " (server dry run)"
+	}
+	return ""
 }
 
-// splitYAML 按 "---" 分割多文档 YAML
-func splitYAML(yamlStr string) []string {
-	yamlStr = utils.NormalizeNewlines(yamlStr)
-	return strings.Split(yamlStr, "\n---\n")
+// applyOrder 资源应用顺序，被依赖的资源在前，未列出的资源（如自定义资源）排在最后
+var applyOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+// applyOrderIndex 获取资源的应用顺序，只对内置资源生效，避免与自定义资源同名
+func applyOrderIndex(gvk schema.GroupVersionKind) int {
+	if !strings.Contains(gvk.Group, ".") || strings.HasSuffix(gvk.Group, ".k8s.io") {
+		for i, kind := range applyOrder {
+			if kind == gvk.Kind {
+				return i
+			}
+		}
+	}
+	return len(applyOrder)
+}
+
+// sortByApplyOrder 按依赖关系排序，同类资源保持 YAML 中的顺序
+func sortByApplyOrder(objs []*unstructured.Unstructured) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return applyOrderIndex(objs[i].GroupVersionKind()) < applyOrderIndex(objs[j].GroupVersionKind())
+	})
+}
+
+func isCRD(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+// waitCRDEstablished 等待 CRD 就绪，并确认其定义的资源已出现在 API 资源列表中，以便后续解析 GVR
+func (a *applier) waitCRDEstablished(name string, gvk schema.GroupVersionKind, timeout time.Duration) error {
+	if a.kubectl.Statement.DryRun {
+		// 试运行时 CRD 并未真正创建
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	established := false
+	for {
+		if !established {
+			var crd *unstructured.Unstructured
+			err := a.kubectl.CRD("apiextensions.k8s.io", "v1", "CustomResourceDefinition").Name(name).Get(&crd).Error
+			if err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err == nil && crd != nil && crdEstablished(crd) {
+				established = true
+				a.kubectl.ClusterCache().Del("crdList")
+				a.kubectl.ClusterCache().Wait()
+			}
+		}
+		if established {
+			if _, _, ok := a.kubectl.Tools().GetGVRByGVK(gvk); ok {
+				return nil
+			}
+			// CRD 变更后 API 资源列表由 Watch 异步刷新，这里主动刷新一次
+			a.kubectl.Status().SetAPIResources(a.kubectl.initializeAPIResources())
+			if _, _, ok := a.kubectl.Tools().GetGVRByGVK(gvk); ok {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("wait for CustomResourceDefinition %s established timeout after %s", name, timeout)
+		}
+		select {
+		case <-a.kubectl.Statement.Context.Done():
+			return a.kubectl.Statement.Context.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// crdEstablished 判断 CRD 的 Established 条件是否为 True
+func crdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
 }
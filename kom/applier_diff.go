@@ -0,0 +1,376 @@
+package kom
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// 字段变更类型
+const (
+	DiffChangeAdded    = "added"
+	DiffChangeRemoved  = "removed"
+	DiffChangeModified = "modified"
+)
+
+// DiffChange 单个字段的变更
+type DiffChange struct {
+	Path string      `json:"path"` // 字段路径，如 spec.template.spec.containers[0].image
+	Type string      `json:"type"` // added、removed、modified
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DiffResult 单个资源线上对象与应用后结果的差异
+type DiffResult struct {
+	GVK       schema.GroupVersionKind    `json:"gvk"`
+	Namespace string                     `json:"namespace,omitempty"`
+	Name      string                     `json:"name,omitempty"`
+	Action    string                     `json:"action"`            // 应用后的动作：created、updated、unchanged、deleted、failed
+	Diff      string                     `json:"diff,omitempty"`    // 统一格式（unified）的 YAML 文本差异
+	Changes   []DiffChange               `json:"changes,omitempty"` // 变更的字段
+	Error     error                      `json:"-"`
+	Live      *unstructured.Unstructured `json:"-"` // 线上对象（已清理），不存在时为 nil
+	Merged    *unstructured.Unstructured `json:"-"` // 服务端试运行的结果（已清理），删除时为 nil
+}
+
+// DiffSummary 差异汇总，元素格式为 Kind namespace/name
+type DiffSummary struct {
+	Created   []string `json:"created,omitempty"`
+	Updated   []string `json:"updated,omitempty"`
+	Unchanged []string `json:"unchanged,omitempty"`
+	Deleted   []string `json:"deleted,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+}
+
+// DiffReport Diff 的结果
+type DiffReport struct {
+	Items   []*DiffResult `json:"items"`
+	Summary DiffSummary   `json:"summary"`
+}
+
+// add 添加一项差异并更新汇总
+func (r *DiffReport) add(item *DiffResult) {
+	r.Items = append(r.Items, item)
+	id := fmt.Sprintf("%s %s/%s", item.GVK.Kind, item.Namespace, item.Name)
+	if item.Namespace == "" {
+		id = fmt.Sprintf("%s %s", item.GVK.Kind, item.Name)
+	}
+	switch item.Action {
+	case ApplyActionCreated:
+		r.Summary.Created = append(r.Summary.Created, id)
+	case ApplyActionUpdated:
+		r.Summary.Updated = append(r.Summary.Updated, id)
+	case ApplyActionUnchanged:
+		r.Summary.Unchanged = append(r.Summary.Unchanged, id)
+	case ApplyActionDeleted:
+		r.Summary.Deleted = append(r.Summary.Deleted, id)
+	default:
+		r.Summary.Failed = append(r.Summary.Failed, id)
+	}
+}
+
+// Diff 对比线上对象与应用 YAML 后的结果，不做任何变更
+// 应用结果来自服务端试运行（DryRun），与 Apply 使用相同的配置项，因此包含准入控制及默认值的影响；
+// 对比前会移除 managedFields、status、resourceVersion 等不影响配置的字段。
+// 集群或本次调用设置了 Secret 脱敏策略时，差异中 Secret 的值同样脱敏，通过 RevealSecrets 显示明文
+func (a *applier) Diff(str string, opts ...ApplyOption) *DiffReport {
+	options := newApplyOptions(opts)
+	policy := a.kubectl.secretRedactPolicy()
+	dry := a.dryRunApplier()
+	lives := map[*ApplyResult]*unstructured.Unstructured{}
+	results := dry.applyAll(str, options, func(obj *unstructured.Unstructured) *ApplyResult {
+		live, err := dry.liveObject(obj)
+		if err != nil {
+			return dry.newApplyResult(obj).fail(err)
+		}
+		var result *ApplyResult
+		if options.threeWayMerge {
+			result = dry.threeWayApply(obj)
+		} else {
+			result = dry.createOrUpdateCRD(obj)
+		}
+		lives[result] = live
+		return result
+	})
+
+	report := &DiffReport{}
+	for _, r := range results {
+		report.add(newDiffResult(r, lives[r], policy))
+	}
+	return report
+}
+
+// dryRunApplier 返回服务端试运行的 applier，不影响当前实例的设置
+func (a *applier) dryRunApplier() *applier {
+	tx := a.kubectl.newInstance()
+	tx.clone = 1 // 作为根实例使用，每次链式调用都会复制 Statement
+	tx.Statement.DryRun = true
+	return &applier{kubectl: tx}
+}
+
+// liveObject 获取 YAML 对应的线上对象，不存在时返回 nil
+func (a *applier) liveObject(obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return nil, fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind")
+	}
+	_, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{gvk})
+	if obj.GetNamespace() == "" && namespaced {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	var live *unstructured.Unstructured
+	// 读取明文用于对比，差异结果统一按脱敏策略处理
+	err := a.kubectl.RevealSecrets().CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(obj.GetNamespace()).Name(obj.GetName()).Get(&live).Error
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, obj.GetNamespace(), obj.GetName(), err)
+	}
+	if live == nil || live.GetName() == "" {
+		return nil, nil
+	}
+	return live.DeepCopy(), nil
+}
+
+// newDiffResult 根据试运行结果及线上对象计算差异，policy 不为空时对 Secret 的值脱敏
+func newDiffResult(r *ApplyResult, live *unstructured.Unstructured, policy *SecretRedactPolicy) *DiffResult {
+	item := &DiffResult{
+		GVK:       r.GVK,
+		Namespace: r.Namespace,
+		Name:      r.Name,
+		Action:    r.Action,
+		Error:     r.Error,
+	}
+	if r.Error != nil {
+		return item
+	}
+	var merged *unstructured.Unstructured
+	if r.Action == ApplyActionDeleted {
+		// 应用集合中将被删除的资源
+		live = r.Object.DeepCopy()
+	} else if r.Object != nil {
+		merged = r.Object.DeepCopy()
+	}
+	item.Live = normalizeForDiff(live)
+	item.Merged = normalizeForDiff(merged)
+	if policy != nil && r.GVK.Group == "" && r.GVK.Kind == "Secret" {
+		redactDiffSecret(item.Live, item.Merged, policy)
+	}
+	if err := item.compute(); err != nil {
+		item.Action = ApplyActionFailed
+		item.Error = err
+		return item
+	}
+	if item.Action == ApplyActionUpdated && len(item.Changes) == 0 {
+		item.Action = ApplyActionUnchanged
+	}
+	return item
+}
+
+// compute 计算文本差异及字段变更
+func (d *DiffResult) compute() error {
+	var before, after map[string]interface{}
+	var from, to string
+	if d.Live != nil {
+		before = d.Live.Object
+		from = "live/" + d.displayName()
+	} else {
+		from = "/dev/null"
+	}
+	if d.Merged != nil {
+		after = d.Merged.Object
+		to = "merged/" + d.displayName()
+	} else {
+		to = "/dev/null"
+	}
+
+	d.Changes = diffFields("", before, after)
+	if len(d.Changes) == 0 {
+		return nil
+	}
+	a, err := toDiffYAML(d.Live)
+	if err != nil {
+		return err
+	}
+	b, err := toDiffYAML(d.Merged)
+	if err != nil {
+		return err
+	}
+	d.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: from,
+		ToFile:   to,
+		Context:  3,
+	})
+	return err
+}
+
+func (d *DiffResult) displayName() string {
+	if d.Namespace == "" {
+		return fmt.Sprintf("%s/%s", d.GVK.Kind, d.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", d.GVK.Kind, d.Namespace, d.Name)
+}
+
+func toDiffYAML(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	bytes, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// normalizeForDiff 移除对比时的干扰字段，并通过 JSON 统一数值类型
+func normalizeForDiff(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil {
+		return nil
+	}
+	if bytes, err := json.Marshal(obj.Object); err == nil {
+		var m map[string]interface{}
+		if err = json.Unmarshal(bytes, &m); err == nil {
+			obj = &unstructured.Unstructured{Object: m}
+		}
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "resourceVersion")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "generation")
+	unstructured.RemoveNestedField(obj.Object, "metadata", "creationTimestamp")
+	return obj
+}
+
+// redactDiffSecret 对比前替换 Secret data、stringData 中需要脱敏的值，与 kubectl diff 一致：
+// 值未变化时两侧均为掩码，值变化时分别为 "掩码 (before)"、"掩码 (after)"，差异中仍可看出哪些 key 发生了变化。
+// last-applied-configuration 注解记录了完整的 Secret，同样整体替换为掩码
+func redactDiffSecret(live, merged *unstructured.Unstructured, policy *SecretRedactPolicy) {
+	mask := policy.mask()
+	for _, field := range []string{"data", "stringData"} {
+		maskDiffValues(secretDiffField(live, field), secretDiffField(merged, field), policy.Match, mask)
+	}
+	maskDiffValues(secretDiffField(live, "metadata", "annotations"), secretDiffField(merged, "metadata", "annotations"), func(key string) bool {
+		return key == v1.LastAppliedConfigAnnotation
+	}, mask)
+}
+
+// maskDiffValues 将两侧 match 的 key 的值替换为掩码
+func maskDiffValues(before, after map[string]interface{}, match func(key string) bool, mask string) {
+	for key, value := range before {
+		if !match(key) {
+			continue
+		}
+		next, ok := after[key]
+		switch {
+		case !ok:
+			before[key] = mask
+		case reflect.DeepEqual(value, next):
+			before[key], after[key] = mask, mask
+		default:
+			before[key], after[key] = mask+" (before)", mask+" (after)"
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok && match(key) {
+			after[key] = mask
+		}
+	}
+}
+
+func secretDiffField(obj *unstructured.Unstructured, fields ...string) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	m, _, _ := unstructured.NestedFieldNoCopy(obj.Object, fields...)
+	result, _ := m.(map[string]interface{})
+	return result
+}
+
+// diffFields 递归对比两个对象，返回变更的字段
+func diffFields(path string, before, after interface{}) (changes []DiffChange) {
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]struct{}{}
+		for k := range b {
+			keys[k] = struct{}{}
+		}
+		for k := range a {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			bv, bok := b[k]
+			av, aok := a[k]
+			child := joinDiffPath(path, k)
+			switch {
+			case !bok:
+				changes = append(changes, DiffChange{Path: child, Type: DiffChangeAdded, New: av})
+			case !aok:
+				changes = append(changes, DiffChange{Path: child, Type: DiffChangeRemoved, Old: bv})
+			default:
+				changes = append(changes, diffFields(child, bv, av)...)
+			}
+		}
+		return changes
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(b) || i < len(a); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(b):
+				changes = append(changes, DiffChange{Path: child, Type: DiffChangeAdded, New: a[i]})
+			case i >= len(a):
+				changes = append(changes, DiffChange{Path: child, Type: DiffChangeRemoved, Old: b[i]})
+			default:
+				changes = append(changes, diffFields(child, b[i], a[i])...)
+			}
+		}
+		return changes
+	}
+	if before == nil {
+		return []DiffChange{{Path: path, Type: DiffChangeAdded, New: after}}
+	}
+	if after == nil {
+		return []DiffChange{{Path: path, Type: DiffChangeRemoved, Old: before}}
+	}
+	return []DiffChange{{Path: path, Type: DiffChangeModified, Old: before, New: after}}
+}
+
+func joinDiffPath(path, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		key = "[" + key + "]"
+		return path + key
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,194 @@
+package kom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplierDiff(t *testing.T) {
+	live := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "diff-cm", Namespace: "default", ResourceVersion: "7"},
+		Data:       map[string]string{"a": "1", "b": "2"},
+	}
+	k := RegisterFakeCluster("diff-cluster", live)
+
+	manifest := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: diff-cm
+  namespace: default
+data:
+  a: "10"
+  c: "3"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: diff-new
+  namespace: default
+data:
+  x: "1"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: diff-cm
+  namespace: default
+`
+	report := k.Applier().Diff(manifest)
+	if len(report.Items) != 3 {
+		t.Fatalf("expected 3 items, got %d", len(report.Items))
+	}
+	if len(report.Summary.Updated) != 1 || report.Summary.Updated[0] != "ConfigMap default/diff-cm" {
+		t.Errorf("unexpected updated summary %v", report.Summary.Updated)
+	}
+	if len(report.Summary.Created) != 2 {
+		t.Errorf("unexpected created summary %v", report.Summary.Created)
+	}
+
+	var updated *DiffResult
+	for _, item := range report.Items {
+		if item.GVK.Kind == "ConfigMap" && item.Name == "diff-cm" {
+			updated = item
+		}
+	}
+	if updated == nil {
+		t.Fatalf("diff-cm not found in report")
+	}
+	changes := map[string]string{}
+	for _, c := range updated.Changes {
+		changes[c.Path] = c.Type
+	}
+	want := map[string]string{"data.a": DiffChangeModified, "data.b": DiffChangeRemoved, "data.c": DiffChangeAdded}
+	if len(changes) != len(want) {
+		t.Errorf("unexpected changes %v", updated.Changes)
+	}
+	for path, typ := range want {
+		if changes[path] != typ {
+			t.Errorf("change %s should be %s, got %s", path, typ, changes[path])
+		}
+	}
+	if !strings.Contains(updated.Diff, `-  a: "1"`) || !strings.Contains(updated.Diff, `+  a: "10"`) {
+		t.Errorf("unexpected unified diff:\n%s", updated.Diff)
+	}
+	if strings.Contains(updated.Diff, "resourceVersion") {
+		t.Errorf("diff should not contain resourceVersion:\n%s", updated.Diff)
+	}
+
+	// Diff 不做任何变更
+	var item v1.ConfigMap
+	if err := k.Resource(&item).Namespace("default").Name("diff-cm").Get(&item).Error; err != nil {
+		t.Fatalf("get configmap failed: %v", err)
+	}
+	if item.Data["a"] != "1" {
+		t.Errorf("diff should not change live object, got %v", item.Data)
+	}
+	if err := k.Resource(&item).Namespace("default").Name("diff-new").Get(&item).Error; err == nil {
+		t.Errorf("diff should not create objects")
+	}
+	if k.Statement.DryRun {
+		t.Errorf("diff should not change the caller's dry-run setting")
+	}
+}
+
+func TestDiffFields(t *testing.T) {
+	before := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"image": "nginx:1"}},
+		},
+	}
+	after := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"image": "nginx:2"}, map[string]interface{}{"image": "busybox"}},
+		},
+	}
+	changes := diffFields("", before, after)
+	if len(changes) != 2 {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	if changes[0].Path != "spec.containers[0].image" || changes[0].Type != DiffChangeModified {
+		t.Errorf("unexpected change %v", changes[0])
+	}
+	if changes[1].Path != "spec.containers[1]" || changes[1].Type != DiffChangeAdded {
+		t.Errorf("unexpected change %v", changes[1])
+	}
+}
+
+func TestApplierDiffSecret(t *testing.T) {
+	live := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "diff-secret", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("old-pass"), "token": []byte("same-token")},
+	}
+	k := RegisterFakeCluster("diff-secret-cluster", live)
+	Clusters().GetClusterById("diff-secret-cluster").redactPolicy = NewSecretRedactPolicy()
+
+	// password: new-pass, token: same-token, extra: extra-value
+	manifest := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: diff-secret
+  namespace: default
+data:
+  password: bmV3LXBhc3M=
+  token: c2FtZS10b2tlbg==
+  extra: ZXh0cmEtdmFsdWU=
+`
+	report := k.Applier().Diff(manifest)
+	if len(report.Items) != 1 || report.Items[0].Error != nil {
+		t.Fatalf("unexpected report %+v", report.Items)
+	}
+	item := report.Items[0]
+	for _, secret := range []string{"b2xkLXBhc3M=", "bmV3LXBhc3M=", "c2FtZS10b2tlbg==", "ZXh0cmEtdmFsdWU="} {
+		if strings.Contains(item.Diff, secret) {
+			t.Errorf("diff should not contain secret value %s:\n%s", secret, item.Diff)
+		}
+	}
+	if !strings.Contains(item.Diff, "password: '****** (before)'") || !strings.Contains(item.Diff, "password: '****** (after)'") {
+		t.Errorf("changed values should be marked:\n%s", item.Diff)
+	}
+	changes := map[string]DiffChange{}
+	for _, c := range item.Changes {
+		changes[c.Path] = c
+	}
+	if len(changes) != 2 || changes["data.password"].Type != DiffChangeModified || changes["data.extra"].New != DefaultSecretMask {
+		t.Errorf("unexpected changes %+v", item.Changes)
+	}
+
+	report = k.RevealSecrets().Applier().Diff(manifest)
+	if !strings.Contains(report.Items[0].Diff, "bmV3LXBhc3M=") {
+		t.Errorf("reveal secrets should show values:\n%s", report.Items[0].Diff)
+	}
+
+	// 三路合并时两侧的 last-applied-configuration 注解均记录了完整的 Secret
+	if r := k.RevealSecrets().Applier().ApplyWithResult(manifest, ApplyThreeWayMerge()); len(r) != 1 || r[0].Error != nil {
+		t.Fatalf("apply failed: %v", r)
+	}
+	changed := strings.Replace(manifest, "bmV3LXBhc3M=", "bmV4dC1wYXNz", 1)
+	report = k.Applier().Diff(changed, ApplyThreeWayMerge())
+	if len(report.Items) != 1 || report.Items[0].Error != nil {
+		t.Fatalf("unexpected report %+v", report.Items)
+	}
+	item = report.Items[0]
+	for _, secret := range []string{"bmV3LXBhc3M=", "bmV4dC1wYXNz", "c2FtZS10b2tlbg=="} {
+		if strings.Contains(item.Diff, secret) {
+			t.Errorf("diff should not contain secret value %s:\n%s", secret, item.Diff)
+		}
+	}
+	for _, c := range item.Changes {
+		if strings.Contains(fmt.Sprint(c.Old, c.New), "bmV") {
+			t.Errorf("change should not contain secret value %+v", c)
+		}
+	}
+	annotation := "metadata.annotations." + v1.LastAppliedConfigAnnotation
+	for _, c := range item.Changes {
+		if c.Path == annotation && (c.Old != DefaultSecretMask+" (before)" || c.New != DefaultSecretMask+" (after)") {
+			t.Errorf("last applied annotation should be masked, got %+v", c)
+		}
+	}
+}
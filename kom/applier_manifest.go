@@ -0,0 +1,200 @@
+package kom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/weibaohui/kom/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// ManifestError 清单解析错误，指明出错的文档及行号
+type ManifestError struct {
+	Index int   // 文档序号，从 1 开始
+	Line  int   // 出错的行号，从 1 开始，无法定位到具体行时为文档的起始行
+	Err   error // 原始错误
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("document %d (line %d): %v", e.Index, e.Line, e.Err)
+}
+
+func (e *ManifestError) Unwrap() error {
+	return e.Err
+}
+
+// manifestDoc 清单中的单个文档
+type manifestDoc struct {
+	index int // 文档序号，从 1 开始
+	line  int // 起始行号，从 1 开始
+	data  []byte
+}
+
+// yamlErrLineRegexp 匹配 YAML 解析错误中的相对行号
+var yamlErrLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// parseManifests 解析 YAML 或 JSON 格式的清单，返回其中的资源对象
+// YAML 支持多文档（--- 分隔，可带注释）、文档结束标记（...）及 CRLF 换行；
+// JSON 支持单个对象、对象数组及多个连续的对象，与 apimachinery 的 YAMLOrJSONDecoder 一样通过 encoding/json 的流式解码分割；
+// kind 为 List 或 xxxList 的对象会展开为其中的 items。
+// 单个文档解析失败不影响其他文档，错误类型为 *ManifestError
+func parseManifests(str string) (objs []*unstructured.Unstructured, errs []error) {
+	str = utils.NormalizeNewlines(str)
+	var docs []manifestDoc
+	if trimmed := strings.TrimSpace(str); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var err error
+		docs, err = splitJSONDocs(str)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		var err error
+		docs, err = splitYAMLDocs(str)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, doc := range docs {
+		var raw interface{}
+		if err := yaml.Unmarshal(doc.data, &raw); err != nil {
+			errs = append(errs, &ManifestError{Index: doc.index, Line: doc.errorLine(err), Err: err})
+			continue
+		}
+		var items []interface{}
+		switch v := raw.(type) {
+		case nil:
+			// 空文档或只有注释
+			continue
+		case []interface{}:
+			// JSON 数组
+			items = v
+		default:
+			items = []interface{}{v}
+		}
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				errs = append(errs, &ManifestError{Index: doc.index, Line: doc.line, Err: fmt.Errorf("expected an object, got %T", item)})
+				continue
+			}
+			expanded, err := expandList(&unstructured.Unstructured{Object: m})
+			if err != nil {
+				errs = append(errs, &ManifestError{Index: doc.index, Line: doc.line, Err: err})
+				continue
+			}
+			objs = append(objs, expanded...)
+		}
+	}
+	return objs, errs
+}
+
+// errorLine 将 YAML 错误中的相对行号换算为清单中的行号
+func (d manifestDoc) errorLine(err error) int {
+	if m := yamlErrLineRegexp.FindStringSubmatch(err.Error()); m != nil {
+		if n, convErr := strconv.Atoi(m[1]); convErr == nil && n > 0 {
+			return d.line + n - 1
+		}
+	}
+	return d.line
+}
+
+// splitYAMLDocs 通过 apimachinery 的 YAML 文档读取器分割多文档 YAML，与 kubectl 的行为一致：
+// 以 --- 开头的行为文档分隔符，其后只能跟随注释；块标量中缩进的 --- 不会被视为分隔符
+func splitYAMLDocs(str string) (docs []manifestDoc, err error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(str)))
+	offset := 0
+	for {
+		data, readErr := reader.Read()
+		if errors.Is(readErr, io.EOF) {
+			return docs, nil
+		}
+		if readErr != nil {
+			return docs, &ManifestError{Index: len(docs) + 1, Line: lineAt(str, offset), Err: readErr}
+		}
+		// 读取器返回的文档为原文中的连续内容，据此定位起始行
+		if i := strings.Index(str[offset:], string(data)); i >= 0 {
+			offset += i
+		}
+		docs = append(docs, manifestDoc{index: len(docs) + 1, line: lineAt(str, offset), data: append([]byte(nil), data...)})
+		offset += len(data)
+	}
+}
+
+// splitJSONDocs 分割连续的 JSON 值
+func splitJSONDocs(str string) (docs []manifestDoc, err error) {
+	decoder := json.NewDecoder(strings.NewReader(str))
+	for {
+		var raw json.RawMessage
+		offset := decoder.InputOffset()
+		if err = decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs, nil
+			}
+			line := jsonErrorLine(str, offset, err)
+			return docs, &ManifestError{Index: len(docs) + 1, Line: line, Err: err}
+		}
+		// 跳过值之前的空白，定位到值的起始行
+		start := int(offset)
+		for start < len(str) && strings.ContainsRune(" \t\r\n", rune(str[start])) {
+			start++
+		}
+		docs = append(docs, manifestDoc{index: len(docs) + 1, line: lineAt(str, start), data: raw})
+	}
+}
+
+// jsonErrorLine 获取 JSON 错误所在的行号
+func jsonErrorLine(str string, offset int64, err error) int {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return lineAt(str, int(offset+syntaxErr.Offset))
+	}
+	return lineAt(str, int(offset))
+}
+
+// lineAt 返回偏移量所在的行号，从 1 开始
+func lineAt(str string, offset int) int {
+	if offset > len(str) {
+		offset = len(str)
+	}
+	return bytes.Count([]byte(str[:offset]), []byte("\n")) + 1
+}
+
+// expandList 展开 List 类型的对象，其他对象原样返回
+// 类型化的列表（如 DeploymentList）中的元素可能缺少 apiVersion 及 kind，使用列表的类型补全
+func expandList(obj *unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	kind := obj.GetKind()
+	if !strings.HasSuffix(kind, "List") || !obj.IsList() {
+		return []*unstructured.Unstructured{obj}, nil
+	}
+	itemKind := strings.TrimSuffix(kind, "List")
+	items, _, _ := unstructured.NestedSlice(obj.Object, "items")
+	var objs []*unstructured.Unstructured
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s items[%d]: expected an object, got %T", kind, i, item)
+		}
+		child := &unstructured.Unstructured{Object: m}
+		if child.GetKind() == "" && itemKind != "" {
+			child.SetKind(itemKind)
+			child.SetAPIVersion(obj.GetAPIVersion())
+		}
+		// List 中可以嵌套 List
+		expanded, err := expandList(child)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, expanded...)
+	}
+	return objs, nil
+}
@@ -0,0 +1,140 @@
+package kom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseManifestsYAMLStream(t *testing.T) {
+	manifest := "---\r\n" +
+		"# leading comment\r\n" +
+		"apiVersion: v1\r\n" +
+		"kind: ConfigMap\r\n" +
+		"metadata:\r\n" +
+		"  name: a\r\n" +
+		"--- # second\r\n" +
+		"apiVersion: v1\r\n" +
+		"kind: ConfigMap\r\n" +
+		"metadata:\r\n" +
+		"  name: b\r\n" +
+		"data:\r\n" +
+		"  script: |\r\n" +
+		"    echo ---\r\n" +
+		"    ---\r\n" +
+		"...\r\n" +
+		"---\r\n" +
+		"# only comment\r\n" +
+		"---\r\n" +
+		"apiVersion: v1\r\n" +
+		"kind: List\r\n" +
+		"items:\r\n" +
+		"- apiVersion: v1\r\n" +
+		"  kind: Secret\r\n" +
+		"  metadata:\r\n" +
+		"    name: c\r\n" +
+		"- apiVersion: v1\r\n" +
+		"  kind: Service\r\n" +
+		"  metadata:\r\n" +
+		"    name: d\r\n"
+
+	objs, errs := parseManifests(manifest)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	var names []string
+	for _, obj := range objs {
+		names = append(names, obj.GetKind()+"/"+obj.GetName())
+	}
+	if strings.Join(names, ",") != "ConfigMap/a,ConfigMap/b,Secret/c,Service/d" {
+		t.Errorf("unexpected objects %v", names)
+	}
+	if script, _, _ := unstructured.NestedString(objs[1].Object, "data", "script"); script != "echo ---\n---\n" {
+		t.Errorf("block scalar should be kept, got %q", script)
+	}
+}
+
+func TestParseManifestsJSON(t *testing.T) {
+	manifest := `[
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "a"}},
+  {"apiVersion": "apps/v1", "kind": "DeploymentList", "items": [{"metadata": {"name": "web"}}]}
+]
+{"apiVersion": "v1", "kind": "Secret", "metadata": {"name": "b"}}`
+	objs, errs := parseManifests(manifest)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+	if len(objs) != 3 {
+		t.Fatalf("expected 3 objects, got %d", len(objs))
+	}
+	if objs[1].GetKind() != "Deployment" || objs[1].GetAPIVersion() != "apps/v1" || objs[1].GetName() != "web" {
+		t.Errorf("typed list item should inherit kind, got %s %s", objs[1].GetAPIVersion(), objs[1].GetKind())
+	}
+	if objs[2].GetKind() != "Secret" {
+		t.Errorf("unexpected object %s", objs[2].GetKind())
+	}
+}
+
+func TestParseManifestsErrorLocation(t *testing.T) {
+	manifest := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+  labels: [a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+`
+	objs, errs := parseManifests(manifest)
+	if len(objs) != 2 {
+		t.Errorf("valid documents should still be parsed, got %d", len(objs))
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", errs)
+	}
+	var manifestErr *ManifestError
+	if !errors.As(errs[0], &manifestErr) {
+		t.Fatalf("expected ManifestError, got %T", errs[0])
+	}
+	if manifestErr.Index != 2 {
+		t.Errorf("error should point at document 2, got %d", manifestErr.Index)
+	}
+	if manifestErr.Line < 6 || manifestErr.Line > 11 {
+		t.Errorf("error line should be inside document 2, got %d", manifestErr.Line)
+	}
+
+	// 文档分隔符后只能跟随注释，错误指向正在读取的文档
+	_, errs = parseManifests("kind: ConfigMap\nmetadata:\n  name: a\n---\nkind: Secret\n--- kind: Secret\n")
+	if len(errs) != 1 || !errors.As(errs[0], &manifestErr) || manifestErr.Index != 2 || manifestErr.Line != 4 {
+		t.Errorf("unexpected separator error %v", errs)
+	}
+
+	_, errs = parseManifests("{\"kind\": \"ConfigMap\"}\n{\"kind\": }")
+	if len(errs) != 1 || !errors.As(errs[0], &manifestErr) || manifestErr.Index != 2 || manifestErr.Line != 2 {
+		t.Errorf("unexpected json error %v", errs)
+	}
+}
+
+func TestApplyAndDeleteJSON(t *testing.T) {
+	k := RegisterFakeCluster("apply-json-cluster")
+	manifest := `{"apiVersion": "v1", "kind": "List", "items": [
+  {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json-cm", "namespace": "default"}}
+]}`
+	results := k.Applier().Apply(manifest)
+	if len(results) != 1 || !strings.Contains(results[0], "created") {
+		t.Fatalf("unexpected apply results %v", results)
+	}
+	results = k.Applier().Delete(manifest)
+	if len(results) != 1 || !strings.Contains(results[0], "deleted") {
+		t.Errorf("unexpected delete results %v", results)
+	}
+}
@@ -0,0 +1,128 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const orderedCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: myapps.example.com
+spec:
+  group: example.com
+  scope: Namespaced
+  names:
+    kind: MyApp
+    plural: myapps
+    singular: myapp
+    listKind: MyAppList
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`
+
+const orderedManifests = `
+apiVersion: example.com/v1
+kind: MyApp
+metadata:
+  name: w1
+  namespace: apps
+spec:
+  size: 1
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: nginx
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+  namespace: apps
+data:
+  a: "1"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apps
+---` + orderedCRD
+
+func TestApplyWithResultOrder(t *testing.T) {
+	k := RegisterFakeCluster("apply-order-cluster")
+	var created []string
+	_ = k.Callback().Create().Before("fake:create").Register("test:record", func(k *Kubectl) error {
+		created = append(created, k.Statement.GVK.Kind)
+		return nil
+	})
+
+	// CRD 已就绪
+	manifests := orderedManifests + `status:
+  conditions:
+  - type: Established
+    status: "True"
+`
+	results := k.Applier().ApplyWithResult(manifests)
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	want := []string{"Namespace", "CustomResourceDefinition", "ConfigMap", "Deployment", "MyApp"}
+	for i, r := range results {
+		if r.Error != nil || r.Action != ApplyActionCreated {
+			t.Errorf("unexpected result %s", r)
+		}
+		if r.GVK.Kind != want[i] {
+			t.Errorf("result %d should be %s, got %s", i, want[i], r.GVK.Kind)
+		}
+	}
+	if strings.Join(created, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected apply order %v", created)
+	}
+	if results[4].Object == nil || results[4].Namespace != "apps" || results[4].Name != "w1" {
+		t.Errorf("result should carry the created object")
+	}
+}
+
+func TestApplyWaitCRDEstablished(t *testing.T) {
+	k := RegisterFakeCluster("apply-crd-wait-cluster")
+	myApp := `
+apiVersion: example.com/v1
+kind: MyApp
+metadata:
+  name: w1
+  namespace: default
+---` + orderedCRD
+
+	// CRD 未就绪时，对应的自定义资源不会被应用
+	results := k.Applier().ApplyWithResult(myApp, ApplyCRDEstablishedTimeout(100*time.Millisecond))
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Action != ApplyActionCreated || results[0].GVK.Kind != "CustomResourceDefinition" {
+		t.Errorf("crd should be created first, got %s", results[0])
+	}
+	if results[1].Action != ApplyActionFailed || !strings.Contains(results[1].Error.Error(), "established") {
+		t.Errorf("myapp should fail waiting for crd, got %s", results[1])
+	}
+}
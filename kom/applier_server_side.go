@@ -0,0 +1,162 @@
+package kom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/structured-merge-diff/v6/fieldpath"
+)
+
+// DefaultFieldManager 服务端应用未指定字段管理器时使用的名称
+const DefaultFieldManager = "kom"
+
+// ApplyConflict 服务端应用时与其他字段管理器冲突的字段
+type ApplyConflict struct {
+	Field   string `json:"field"`   // 冲突的字段路径，如 .spec.replicas
+	Manager string `json:"manager"` // 当前持有该字段的字段管理器，如 kube-controller-manager
+	Message string `json:"message"` // API Server 返回的原始信息
+}
+
+// lastAppliedFieldPath 客户端应用（kubectl apply）写入的 last-applied-configuration 注解
+var lastAppliedFieldPath = fieldpath.NewSet(fieldpath.MakePathOrDie("metadata", "annotations", v1.LastAppliedConfigAnnotation))
+
+// conflictManagerRegexp 从冲突信息中提取字段管理器，如 conflict with "kube-controller-manager" using apps/v1
+var conflictManagerRegexp = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// ServerSideApply 服务端应用，通过 ApplyPatchType 提交，只更新 YAML 中声明的字段，不影响其他控制器持有的字段
+// fieldManager 为空时使用 DefaultFieldManager；force 为 true 时强制接管冲突字段
+// 曾经使用 kubectl 客户端应用管理的资源，会先将其字段归属迁移到 fieldManager
+func (a *applier) ServerSideApply(str string, fieldManager string, force bool) []*ApplyResult {
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+	return a.applyAll(str, newApplyOptions(nil), func(obj *unstructured.Unstructured) *ApplyResult {
+		return a.serverSideApply(obj, fieldManager, force)
+	})
+}
+
+func (a *applier) serverSideApply(obj *unstructured.Unstructured, fieldManager string, force bool) *ApplyResult {
+	gvk := obj.GroupVersionKind()
+	result := a.newApplyResult(obj)
+	fail := func(err error) *ApplyResult {
+		return result.fail(fmt.Errorf("apply %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, result.Namespace, result.Name, err))
+	}
+	if gvk.Kind == "" || gvk.Version == "" {
+		return result.fail(fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind"))
+	}
+
+	_, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{gvk})
+	if result.Namespace == "" && namespaced {
+		result.Namespace = metav1.NamespaceDefault // 默认命名空间
+		obj.SetNamespace(result.Namespace)
+	}
+
+	var live *unstructured.Unstructured
+	err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(result.Namespace).Name(result.Name).Get(&live).Error
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fail(err)
+	}
+	if err != nil || live == nil || live.GetName() == "" {
+		live = nil
+	}
+	if live != nil {
+		if err = a.upgradeClientSideApply(live, fieldManager); err != nil {
+			return fail(fmt.Errorf("migrate client-side apply managed fields error: %w", err))
+		}
+	}
+
+	// 服务端应用的请求中不能携带 managedFields
+	obj.SetManagedFields(nil)
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return fail(err)
+	}
+	tx := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(result.Namespace).Name(result.Name)
+	tx.Statement.FieldManager = fieldManager
+	tx.Statement.ForceConflicts = force
+	var res *unstructured.Unstructured
+	if err = tx.Patch(&res, types.ApplyPatchType, string(data)).Error; err != nil {
+		return fail(err)
+	}
+	result.Object = res
+
+	switch {
+	case live == nil:
+		result.Action = ApplyActionCreated
+	case !result.DryRun && res != nil && live.GetResourceVersion() != "" && live.GetResourceVersion() == res.GetResourceVersion():
+		result.Action = ApplyActionUnchanged
+	default:
+		result.Action = ApplyActionUpdated
+	}
+	return result
+}
+
+// upgradeClientSideApply 将 kubectl 客户端应用持有的字段迁移到服务端应用的字段管理器
+// 与 kubectl apply --server-side 行为一致：持有 last-applied-configuration 注解的 Update 类字段管理器，视为客户端应用
+// 否则客户端应用遗留的字段在 YAML 中删除后，不会被服务端应用删除
+func (a *applier) upgradeClientSideApply(live *unstructured.Unstructured, fieldManager string) error {
+	managers := sets.New[string]()
+	for _, entry := range csaupgrade.FindFieldsOwners(live.GetManagedFields(), metav1.ManagedFieldsOperationUpdate, lastAppliedFieldPath) {
+		managers.Insert(entry.Manager)
+	}
+	if managers.Len() == 0 {
+		return nil
+	}
+	gvk := live.GroupVersionKind()
+	current := live
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		patch, err := csaupgrade.UpgradeManagedFieldsPatch(current, managers, fieldManager)
+		if err != nil || patch == nil {
+			// patch 为空表示已经迁移
+			return err
+		}
+		var res *unstructured.Unstructured
+		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(live.GetNamespace()).Name(live.GetName()).
+			Patch(&res, types.JSONPatchType, string(patch)).Error
+		if apierrors.IsConflict(err) {
+			// 补丁中包含 resourceVersion 校验，冲突时重新获取
+			if getErr := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(live.GetNamespace()).Name(live.GetName()).Get(&current).Error; getErr != nil {
+				return getErr
+			}
+		}
+		return err
+	})
+}
+
+// ParseApplyConflicts 解析服务端应用返回的字段冲突，非冲突错误返回 nil
+func ParseApplyConflicts(err error) []ApplyConflict {
+	var status apierrors.APIStatus
+	if err == nil || !errors.As(err, &status) {
+		return nil
+	}
+	s := status.Status()
+	if s.Reason != metav1.StatusReasonConflict || s.Details == nil {
+		return nil
+	}
+	var conflicts []ApplyConflict
+	for _, cause := range s.Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := ApplyConflict{
+			Field:   cause.Field,
+			Message: cause.Message,
+		}
+		if m := conflictManagerRegexp.FindStringSubmatch(cause.Message); len(m) == 2 {
+			conflict.Manager = m[1]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
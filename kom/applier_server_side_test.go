@@ -0,0 +1,168 @@
+package kom
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+const ssaDeployYAML = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ssa-deploy
+  namespace: default
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: ssa
+  template:
+    metadata:
+      labels:
+        app: ssa
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.25
+`
+
+func newSSADeploy(managedFields ...metav1.ManagedFieldsEntry) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:          "ssa-deploy",
+			Namespace:     "default",
+			ManagedFields: managedFields,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(1)),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "ssa"}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "ssa"}},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "nginx", Image: "nginx:1.24"}}},
+			},
+		},
+	}
+}
+
+// recordPatches 记录 PATCH 请求的类型
+func recordPatches(k *Kubectl) *[]types.PatchType {
+	var patches []types.PatchType
+	_ = k.Callback().Patch().Before("fake:patch").Register("test:record", func(k *Kubectl) error {
+		patches = append(patches, k.Statement.PatchType)
+		return nil
+	})
+	return &patches
+}
+
+func TestServerSideApply(t *testing.T) {
+	k := RegisterFakeCluster("ssa-cluster", newSSADeploy())
+	patches := recordPatches(k)
+
+	results := k.Applier().ServerSideApply(ssaDeployYAML, "", false)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	r := results[0]
+	if r.Error != nil || r.Action != ApplyActionUpdated {
+		t.Fatalf("unexpected result %s", r)
+	}
+	if r.Object == nil || r.Object.GetName() != "ssa-deploy" {
+		t.Errorf("result should carry the applied object")
+	}
+	if len(*patches) != 1 || (*patches)[0] != types.ApplyPatchType {
+		t.Fatalf("expected one apply patch, got %v", *patches)
+	}
+
+	var item appsv1.Deployment
+	if err := k.Resource(&item).Namespace("default").Name("ssa-deploy").Get(&item).Error; err != nil {
+		t.Fatalf("get deployment failed: %v", err)
+	}
+	if *item.Spec.Replicas != 2 || item.Spec.Template.Spec.Containers[0].Image != "nginx:1.25" {
+		t.Errorf("deployment not applied: %v", item.Spec)
+	}
+}
+
+func TestServerSideApplyConflicts(t *testing.T) {
+	k := RegisterFakeCluster("ssa-conflict-cluster", newSSADeploy())
+	var fieldManager string
+	var force bool
+	_ = k.Callback().Patch().Before("fake:patch").Register("test:conflict", func(k *Kubectl) error {
+		fieldManager = k.Statement.FieldManager
+		force = k.Statement.ForceConflicts
+		if force {
+			return nil
+		}
+		return apierrors.NewApplyConflict([]metav1.StatusCause{
+			{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: `conflict with "kube-controller-manager" using apps/v1`,
+				Field:   ".spec.replicas",
+			},
+		}, `Apply failed with 1 conflict: conflict with "kube-controller-manager" using apps/v1: .spec.replicas`)
+	})
+
+	results := k.Applier().ServerSideApply(ssaDeployYAML, "deployer", false)
+	if len(results) != 1 || results[0].Action != ApplyActionFailed || results[0].Error == nil {
+		t.Fatalf("apply should fail with conflicts, got %v", results)
+	}
+	conflicts := results[0].Conflicts
+	if len(conflicts) != 1 || conflicts[0].Field != ".spec.replicas" || conflicts[0].Manager != "kube-controller-manager" {
+		t.Errorf("unexpected conflicts %+v", conflicts)
+	}
+	if fieldManager != "deployer" {
+		t.Errorf("field manager should be deployer, got %s", fieldManager)
+	}
+
+	// 强制接管冲突字段
+	results = k.Applier().ServerSideApply(ssaDeployYAML, "deployer", true)
+	if len(results) != 1 || results[0].Error != nil || len(results[0].Conflicts) != 0 {
+		t.Errorf("force apply should succeed, got %v", results)
+	}
+}
+
+func TestServerSideApplyMigrateClientSideApply(t *testing.T) {
+	csa := metav1.ManagedFieldsEntry{
+		Manager:    "kubectl-client-side-apply",
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		APIVersion: "apps/v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{".":{},"f:kubectl.kubernetes.io/last-applied-configuration":{}}},"f:spec":{"f:replicas":{}}}`)},
+	}
+	k := RegisterFakeCluster("ssa-migrate-cluster", newSSADeploy(csa))
+	patches := recordPatches(k)
+
+	results := k.Applier().ServerSideApply(ssaDeployYAML, "deployer", false)
+	if len(results) != 1 || results[0].Error != nil {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if len(*patches) != 2 || (*patches)[0] != types.JSONPatchType || (*patches)[1] != types.ApplyPatchType {
+		t.Fatalf("expected managed fields migration before apply, got %d patches", len(*patches))
+	}
+
+	var item appsv1.Deployment
+	if err := k.Resource(&item).Namespace("default").Name("ssa-deploy").Get(&item).Error; err != nil {
+		t.Fatalf("get deployment failed: %v", err)
+	}
+	for _, entry := range item.ManagedFields {
+		if entry.Manager == "kubectl-client-side-apply" {
+			t.Errorf("client-side apply manager should be migrated, got %v", item.ManagedFields)
+		}
+	}
+}
+
+func TestParseApplyConflicts(t *testing.T) {
+	if ParseApplyConflicts(nil) != nil {
+		t.Errorf("nil error should have no conflicts")
+	}
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "x")
+	if ParseApplyConflicts(notFound) != nil {
+		t.Errorf("not found error should have no conflicts")
+	}
+}
@@ -0,0 +1,265 @@
+package kom
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/weibaohui/kom/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog/v2"
+)
+
+// ApplySetLabel 标记资源所属的应用集合，值为集合 ID
+const ApplySetLabel = "kom.kubernetes.io/apply-set"
+
+// ApplySetParentLabel 标记应用集合的父对象（ConfigMap），值为集合 ID
+const ApplySetParentLabel = "kom.kubernetes.io/apply-set-parent"
+
+// ApplySetGroupKindsAnnotation 父对象上记录集合包含的资源类型，格式同 kubectl 的 applyset.kubernetes.io/contains-group-kinds，
+// 如 ConfigMap,Deployment.apps。资源类型的最后一个对象从 YAML 中移除后，仍可据此清理该类型的资源
+const ApplySetGroupKindsAnnotation = "kom.kubernetes.io/apply-set-group-kinds"
+
+// ApplySetID 设置应用集合 ID
+// 应用时为每个资源添加 ApplySetLabel 标签，应用完成后删除集群中带有相同标签、但已不在 YAML 中的资源；
+// 用于 Diff 时，将要删除的资源体现在 Summary.Deleted 中
+func ApplySetID(setID string) ApplyOption {
+	return func(o *applyOptions) {
+		o.setID = setID
+	}
+}
+
+// ApplySet 按应用集合创建或更新资源，并清理集合中已从 YAML 移除的资源
+// 集合包含的资源类型记录在父对象（名为 kom-apply-set-<集合 ID> 的 ConfigMap）上，清理范围为记录的类型与本次 YAML 中类型的并集；
+// 试运行（DryRun）时只返回将要删除的资源，不做删除，也不修改父对象。
+// 任一资源应用失败时不做清理，避免误删
+//
+// Example:
+// results := kom.DefaultCluster().Applier().ApplySet(yaml, "my-app")
+func (a *applier) ApplySet(str string, setID string, opts ...ApplyOption) []*ApplyResult {
+	return a.ApplyWithResult(str, append(opts, ApplySetID(setID))...)
+}
+
+// validateApplySetID 集合 ID 作为标签值使用，需要符合标签值的格式
+func validateApplySetID(setID string) error {
+	if setID == "" {
+		return fmt.Errorf("apply set id is empty")
+	}
+	if errs := validation.IsValidLabelValue(setID); len(errs) > 0 {
+		return fmt.Errorf("invalid apply set id %q: %s", setID, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// stampApplySet 为资源添加集合标签
+func stampApplySet(obj *unstructured.Unstructured, setID string) {
+	utils.NewLabelsManager(map[string]string{ApplySetLabel: setID}).AddLabelsToObject(obj)
+}
+
+// prune 删除集合中已不在 YAML 中的资源，按应用顺序的逆序删除
+// recorded 为父对象中记录的资源类型，YAML 中已没有该类型的对象时，通过集群中注册的版本查询
+func (a *applier) prune(setID string, objs []*unstructured.Unstructured, recorded []schema.GroupKind) (results []*ApplyResult) {
+	keep := map[string]struct{}{}
+	var gvks []schema.GroupVersionKind
+	seen := map[schema.GroupKind]struct{}{}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		keep[applySetKey(gvk.GroupKind(), obj.GetNamespace(), obj.GetName())] = struct{}{}
+		if _, ok := seen[gvk.GroupKind()]; !ok {
+			seen[gvk.GroupKind()] = struct{}{}
+			gvks = append(gvks, gvk)
+		}
+	}
+	for _, gk := range recorded {
+		if _, ok := seen[gk]; ok {
+			continue
+		}
+		seen[gk] = struct{}{}
+		gvk, ok := a.servedGVK(gk)
+		if !ok {
+			// 资源类型已从集群中移除（如 CRD 已删除），不存在需要清理的对象
+			klog.V(6).Infof("apply set %s: %s is not served, skip prune", setID, gk.String())
+			continue
+		}
+		gvks = append(gvks, gvk)
+	}
+
+	var candidates []*unstructured.Unstructured
+	for _, gvk := range gvks {
+		var items []*unstructured.Unstructured
+		err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).
+			AllNamespace().
+			WithLabelSelector(fmt.Sprintf("%s=%s", ApplySetLabel, setID)).
+			List(&items).Error
+		if err != nil {
+			result := &ApplyResult{GVK: gvk, DryRun: a.kubectl.Statement.DryRun}
+			results = append(results, result.fail(fmt.Errorf("list %s/%s,%s for apply set %s error:%w", gvk.Group, gvk.Version, gvk.Kind, setID, err)))
+			continue
+		}
+		for _, item := range items {
+			if _, ok := keep[applySetKey(gvk.GroupKind(), item.GetNamespace(), item.GetName())]; ok {
+				continue
+			}
+			item.SetGroupVersionKind(gvk)
+			candidates = append(candidates, item)
+		}
+	}
+
+	sortByApplyOrder(candidates)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		results = append(results, a.pruneObject(candidates[i]))
+	}
+	return results
+}
+
+// pruneObject 删除单个资源
+func (a *applier) pruneObject(obj *unstructured.Unstructured) *ApplyResult {
+	result := a.newApplyResult(obj)
+	gvk := result.GVK
+	klog.V(6).Infof("prune %s %s/%s from apply set", gvk.Kind, result.Namespace, result.Name)
+	err := a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(result.Namespace).Name(result.Name).Delete().Error
+	if err != nil {
+		return result.fail(fmt.Errorf("delete %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, result.Namespace, result.Name, err))
+	}
+	result.Action = ApplyActionDeleted
+	result.Object = obj
+	return result
+}
+
+// servedGVK 查找集群中注册的资源类型版本
+func (a *applier) servedGVK(gk schema.GroupKind) (schema.GroupVersionKind, bool) {
+	for _, r := range a.kubectl.Status().APIResources() {
+		if r != nil && !strings.Contains(r.Name, "/") && r.Group == gk.Group && r.Kind == gk.Kind {
+			return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}, true
+		}
+	}
+	return schema.GroupVersionKind{}, false
+}
+
+// loadApplySet 查找集合的父对象，返回其中记录的资源类型，父对象不存在时返回 nil
+func (a *applier) loadApplySet(setID string) (*v1.ConfigMap, []schema.GroupKind, error) {
+	var parents []v1.ConfigMap
+	err := a.kubectl.newInstance().WithContext(a.kubectl.Statement.Context).
+		Resource(&v1.ConfigMap{}).
+		AllNamespace().
+		WithLabelSelector(fmt.Sprintf("%s=%s", ApplySetParentLabel, setID)).
+		List(&parents).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("get parent of apply set %s error:%w", setID, err)
+	}
+	if len(parents) == 0 {
+		return nil, nil, nil
+	}
+	parent := &parents[0]
+	var kinds []schema.GroupKind
+	for _, s := range strings.Split(parent.Annotations[ApplySetGroupKindsAnnotation], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			kinds = append(kinds, schema.ParseGroupKind(s))
+		}
+	}
+	return parent, kinds, nil
+}
+
+// saveApplySet 在父对象上记录集合包含的资源类型，父对象不存在时创建
+// 父对象创建在 YAML 中第一个对象所在的命名空间，没有时为 default
+func (a *applier) saveApplySet(setID string, parent *v1.ConfigMap, kinds []schema.GroupKind, objs []*unstructured.Unstructured) (*v1.ConfigMap, error) {
+	value := formatGroupKinds(kinds)
+	if parent != nil {
+		if parent.Annotations[ApplySetGroupKindsAnnotation] == value {
+			return parent, nil
+		}
+		patch, _ := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"annotations": map[string]string{ApplySetGroupKindsAnnotation: value}},
+		})
+		var updated v1.ConfigMap
+		err := a.kubectl.newInstance().WithContext(a.kubectl.Statement.Context).
+			Resource(parent).Namespace(parent.Namespace).Name(parent.Name).
+			Patch(&updated, types.MergePatchType, string(patch)).Error
+		if err != nil {
+			return parent, fmt.Errorf("update parent of apply set %s error:%w", setID, err)
+		}
+		return &updated, nil
+	}
+
+	namespace := metav1.NamespaceDefault
+	for _, obj := range objs {
+		if obj.GetNamespace() != "" {
+			namespace = obj.GetNamespace()
+			break
+		}
+	}
+	parent = &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        applySetParentName(setID),
+			Namespace:   namespace,
+			Labels:      map[string]string{ApplySetParentLabel: setID},
+			Annotations: map[string]string{ApplySetGroupKindsAnnotation: value},
+		},
+	}
+	err := a.kubectl.newInstance().WithContext(a.kubectl.Statement.Context).Resource(parent).Create(parent).Error
+	if err != nil {
+		return nil, fmt.Errorf("create parent of apply set %s error:%w", setID, err)
+	}
+	return parent, nil
+}
+
+// applySetParentName 父对象名称，集合 ID 不符合资源名称格式时使用其哈希值
+func applySetParentName(setID string) string {
+	name := "kom-apply-set-" + setID
+	if len(validation.IsDNS1123Subdomain(name)) == 0 {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(setID))
+	return fmt.Sprintf("kom-apply-set-%x", h.Sum32())
+}
+
+// objectGroupKinds 返回对象的资源类型，与 extra 合并后去重
+func objectGroupKinds(objs []*unstructured.Unstructured, extra ...schema.GroupKind) []schema.GroupKind {
+	seen := map[schema.GroupKind]struct{}{}
+	var kinds []schema.GroupKind
+	add := func(gk schema.GroupKind) {
+		if _, ok := seen[gk]; !ok && gk.Kind != "" {
+			seen[gk] = struct{}{}
+			kinds = append(kinds, gk)
+		}
+	}
+	for _, obj := range objs {
+		add(obj.GroupVersionKind().GroupKind())
+	}
+	for _, gk := range extra {
+		add(gk)
+	}
+	return kinds
+}
+
+func formatGroupKinds(kinds []schema.GroupKind) string {
+	names := make([]string, 0, len(kinds))
+	for _, gk := range kinds {
+		names = append(names, gk.String())
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func applySetKey(gk schema.GroupKind, ns, name string) string {
+	return fmt.Sprintf("%s/%s/%s", gk.String(), ns, name)
+}
+
+// hasFailed 判断是否存在失败的结果
+func hasFailed(results []*ApplyResult) bool {
+	for _, r := range results {
+		if r.Error != nil {
+			return true
+		}
+	}
+	return false
+}
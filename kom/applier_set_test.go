@@ -0,0 +1,154 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const applySetManifest = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: set-a
+  namespace: default
+data:
+  a: "1"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: set-b
+  namespace: other
+data:
+  b: "1"
+`
+
+func TestApplySetPrune(t *testing.T) {
+	k := RegisterFakeCluster("apply-set-cluster")
+	// 不属于集合的资源不会被清理
+	unmanaged := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: "default"}}
+	if err := k.Resource(unmanaged).Create(unmanaged).Error; err != nil {
+		t.Fatalf("create configmap failed: %v", err)
+	}
+
+	results := k.Applier().ApplySet(applySetManifest, "demo")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Error != nil || r.Action != ApplyActionCreated {
+			t.Errorf("unexpected result %s", r)
+		}
+	}
+	var cm v1.ConfigMap
+	if err := k.Resource(&cm).Namespace("other").Name("set-b").Get(&cm).Error; err != nil {
+		t.Fatalf("get configmap failed: %v", err)
+	}
+	if cm.Labels[ApplySetLabel] != "demo" {
+		t.Errorf("object should be labelled with apply set, got %v", cm.Labels)
+	}
+
+	// 从 YAML 中移除 set-b
+	manifest := strings.SplitN(applySetManifest, "---", 2)[0]
+
+	// 试运行只报告，不删除
+	dry := k.Applier().Diff(manifest, ApplySetID("demo"))
+	if len(dry.Summary.Deleted) != 1 || dry.Summary.Deleted[0] != "ConfigMap other/set-b" {
+		t.Errorf("unexpected deleted summary %v", dry.Summary.Deleted)
+	}
+	if err := k.Resource(&cm).Namespace("other").Name("set-b").Get(&cm).Error; err != nil {
+		t.Errorf("diff should not delete objects: %v", err)
+	}
+
+	results = k.Applier().ApplySet(manifest, "demo")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[1].Action != ApplyActionDeleted || results[1].Name != "set-b" || results[1].Namespace != "other" {
+		t.Errorf("set-b should be pruned, got %s", results[1])
+	}
+	if err := k.Resource(&cm).Namespace("other").Name("set-b").Get(&cm).Error; err == nil {
+		t.Errorf("set-b should be deleted")
+	}
+	if err := k.Resource(&cm).Namespace("default").Name("unmanaged").Get(&cm).Error; err != nil {
+		t.Errorf("unmanaged object should be kept: %v", err)
+	}
+}
+
+func TestApplySetSkipPruneOnFailure(t *testing.T) {
+	k := RegisterFakeCluster("apply-set-failure-cluster")
+	k.Applier().ApplySet(applySetManifest, "demo")
+
+	// 存在解析失败的文档时不做清理
+	manifest := strings.SplitN(applySetManifest, "---", 2)[0] + "---\nkind: ConfigMap\n"
+	results := k.Applier().ApplySet(manifest, "demo")
+	if !hasFailed(results) {
+		t.Fatalf("expected failed results")
+	}
+	for _, r := range results {
+		if r.Action == ApplyActionDeleted {
+			t.Errorf("prune should be skipped, got %s", r)
+		}
+	}
+	var cm v1.ConfigMap
+	if err := k.Resource(&cm).Namespace("other").Name("set-b").Get(&cm).Error; err != nil {
+		t.Errorf("set-b should be kept: %v", err)
+	}
+
+	if results := k.Applier().ApplySet(applySetManifest, "bad id!"); len(results) != 1 || results[0].Error == nil {
+		t.Errorf("invalid set id should fail")
+	}
+}
+
+func TestApplySetPruneRemovedKind(t *testing.T) {
+	k := RegisterFakeCluster("apply-set-kind-cluster")
+	manifest := applySetManifest + `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: set-secret
+  namespace: default
+stringData:
+  token: abc
+`
+	for _, r := range k.Applier().ApplySet(manifest, "demo") {
+		if r.Error != nil {
+			t.Fatalf("apply failed: %s", r)
+		}
+	}
+	var parent v1.ConfigMap
+	if err := k.Resource(&parent).Namespace("default").Name("kom-apply-set-demo").Get(&parent).Error; err != nil {
+		t.Fatalf("get apply set parent failed: %v", err)
+	}
+	if parent.Annotations[ApplySetGroupKindsAnnotation] != "ConfigMap,Secret" || parent.Labels[ApplySetParentLabel] != "demo" {
+		t.Errorf("unexpected parent %v %v", parent.Labels, parent.Annotations)
+	}
+
+	// 移除 YAML 中唯一的 Secret，该类型仍会被清理
+	results := k.Applier().ApplySet(applySetManifest, "demo")
+	var pruned []string
+	for _, r := range results {
+		if r.Error != nil {
+			t.Errorf("unexpected result %s", r)
+		}
+		if r.Action == ApplyActionDeleted {
+			pruned = append(pruned, r.GVK.Kind+"/"+r.Name)
+		}
+	}
+	if len(pruned) != 1 || pruned[0] != "Secret/set-secret" {
+		t.Errorf("secret should be pruned, got %v", pruned)
+	}
+	var secret v1.Secret
+	if err := k.Resource(&secret).Namespace("default").Name("set-secret").Get(&secret).Error; err == nil {
+		t.Errorf("set-secret should be deleted")
+	}
+	if err := k.Resource(&parent).Namespace("default").Name("kom-apply-set-demo").Get(&parent).Error; err != nil {
+		t.Fatalf("get apply set parent failed: %v", err)
+	}
+	if parent.Annotations[ApplySetGroupKindsAnnotation] != "ConfigMap" {
+		t.Errorf("pruned kinds should be removed from parent, got %v", parent.Annotations)
+	}
+}
@@ -0,0 +1,131 @@
+package kom
+
+import (
+	"encoding/json"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/mergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// threeWayApply 使用 last-applied-configuration 三路合并创建或更新资源
+func (a *applier) threeWayApply(obj *unstructured.Unstructured) *ApplyResult {
+	result := a.newApplyResult(obj)
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return result.fail(fmt.Errorf("YAML 缺少必要的 Group, Version 或 Kind"))
+	}
+
+	_, namespaced := a.kubectl.Tools().ParseGVK2GVR([]schema.GroupVersionKind{gvk})
+
+	ns := obj.GetNamespace()
+	name := obj.GetName()
+
+	if ns == "" && namespaced {
+		ns = metav1.NamespaceDefault // 默认命名空间
+		obj.SetNamespace(ns)
+		result.Namespace = ns
+	}
+
+	modified, err := setLastApplied(obj)
+	if err != nil {
+		return result.fail(fmt.Errorf("apply %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
+	}
+
+	// 以明文读取线上对象，否则集群启用脱敏时 Secret 总会被判定为有变更
+	var live *unstructured.Unstructured
+	err = a.kubectl.RevealSecrets().CRD(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&live).Error
+	if err != nil && !apierrors.IsNotFound(err) {
+		return result.fail(fmt.Errorf("get %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
+	}
+	if err != nil || live == nil || live.GetName() == "" {
+		// 不存在，那么就创建，同时记录本次应用的配置
+		err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Create(&obj).Error
+		if err != nil {
+			return result.fail(fmt.Errorf("create %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
+		}
+		result.Object = obj
+		result.Action = ApplyActionCreated
+		return result
+	}
+
+	pt, patch, err := threeWayMergePatch(gvk, live, modified)
+	if err != nil {
+		return result.fail(fmt.Errorf("create patch %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
+	}
+	if string(patch) == "{}" {
+		result.Object = live
+		result.Action = ApplyActionUnchanged
+		return result
+	}
+	var res *unstructured.Unstructured
+	err = a.kubectl.CRD(gvk.Group, gvk.Version, gvk.Kind).Name(name).Namespace(ns).Patch(&res, pt, string(patch)).Error
+	if err != nil {
+		return result.fail(fmt.Errorf("update %s/%s,%s %s/%s error:%w", gvk.Group, gvk.Version, gvk.Kind, ns, name, err))
+	}
+	result.Object = res
+	result.Action = ApplyActionUpdated
+	return result
+}
+
+// setLastApplied 将对象本身（不含该注解）写入 last-applied-configuration 注解，返回写入注解后的对象 JSON
+func setLastApplied(obj *unstructured.Unstructured) ([]byte, error) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	delete(annotations, v1.LastAppliedConfigAnnotation)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+	original, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, err
+	}
+	annotations[v1.LastAppliedConfigAnnotation] = string(original)
+	obj.SetAnnotations(annotations)
+	return json.Marshal(obj.Object)
+}
+
+// threeWayMergePatch 根据上次应用的配置、本次配置及线上对象计算补丁
+// scheme 中注册的内置资源使用 strategic merge patch，其他资源（CRD）使用 JSON merge patch
+func threeWayMergePatch(gvk schema.GroupVersionKind, live *unstructured.Unstructured, modified []byte) (types.PatchType, []byte, error) {
+	current, err := json.Marshal(live.Object)
+	if err != nil {
+		return "", nil, err
+	}
+	var original []byte
+	if lastApplied, ok := live.GetAnnotations()[v1.LastAppliedConfigAnnotation]; ok {
+		original = []byte(lastApplied)
+	}
+
+	// 与 kubectl 一致，不允许修改 apiVersion、kind 及名称
+	preconditions := []mergepatch.PreconditionFunc{
+		mergepatch.RequireKeyUnchanged("apiVersion"),
+		mergepatch.RequireKeyUnchanged("kind"),
+		mergepatch.RequireMetadataKeyUnchanged("name"),
+	}
+
+	versioned, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		// 未注册的类型，无法获取合并策略
+		patch, err := jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current, preconditions...)
+		return types.MergePatchType, patch, err
+	}
+	meta, err := strategicpatch.NewPatchMetaFromStruct(versioned)
+	if err != nil {
+		return "", nil, err
+	}
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, meta, true, preconditions...)
+	return types.StrategicMergePatchType, patch, err
+}
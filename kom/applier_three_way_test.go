@@ -0,0 +1,115 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestApplyThreeWayMerge(t *testing.T) {
+	k := RegisterFakeCluster("three-way-cluster")
+
+	cm := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: three-way
+  namespace: default
+data:
+  a: "1"
+  b: "2"
+`
+	results := k.Applier().Apply(cm, ApplyThreeWayMerge())
+	if len(results) != 1 || !strings.Contains(results[0], "created") {
+		t.Fatalf("unexpected results %v", results)
+	}
+	var item v1.ConfigMap
+	if err := k.Resource(&item).Namespace("default").Name("three-way").Get(&item).Error; err != nil {
+		t.Fatalf("get configmap failed: %v", err)
+	}
+	if item.Annotations[v1.LastAppliedConfigAnnotation] == "" {
+		t.Fatalf("last-applied-configuration should be recorded")
+	}
+
+	// 其他控制器设置的字段
+	err := k.Resource(&item).Namespace("default").Name("three-way").
+		Patch(&item, types.MergePatchType, `{"data":{"c":"3"}}`).Error
+	if err != nil {
+		t.Fatalf("patch configmap failed: %v", err)
+	}
+
+	// YAML 中删除 b，修改 a
+	updated := strings.Replace(strings.Replace(cm, `  b: "2"`+"\n", "", 1), `a: "1"`, `a: "10"`, 1)
+	results = k.Applier().Apply(updated, ApplyThreeWayMerge())
+	if len(results) != 1 || !strings.Contains(results[0], "updated") {
+		t.Fatalf("unexpected results %v", results)
+	}
+	item = v1.ConfigMap{}
+	if err = k.Resource(&item).Namespace("default").Name("three-way").Get(&item).Error; err != nil {
+		t.Fatalf("get configmap failed: %v", err)
+	}
+	if item.Data["a"] != "10" {
+		t.Errorf("a should be updated, got %v", item.Data)
+	}
+	if _, ok := item.Data["b"]; ok {
+		t.Errorf("b removed from manifest should be removed, got %v", item.Data)
+	}
+	if item.Data["c"] != "3" {
+		t.Errorf("c set by others should be kept, got %v", item.Data)
+	}
+
+	results = k.Applier().Apply(updated, ApplyThreeWayMerge())
+	if len(results) != 1 || !strings.Contains(results[0], "unchanged") {
+		t.Errorf("unexpected results %v", results)
+	}
+}
+
+// 集群启用 Secret 脱敏时，未修改的 Secret 仍判定为未变更
+func TestApplyThreeWayMergeRedactedSecret(t *testing.T) {
+	k := RegisterFakeCluster("three-way-redact-cluster")
+	Clusters().GetClusterById("three-way-redact-cluster").redactPolicy = NewSecretRedactPolicy()
+
+	secret := `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: three-way-secret
+  namespace: default
+data:
+  password: cEBzcw==
+`
+	results := k.Applier().ApplyWithResult(secret, ApplyThreeWayMerge())
+	if len(results) != 1 || results[0].Action != ApplyActionCreated {
+		t.Fatalf("unexpected results %+v", results[0])
+	}
+	results = k.Applier().ApplyWithResult(secret, ApplyThreeWayMerge())
+	if len(results) != 1 || results[0].Action != ApplyActionUnchanged {
+		t.Errorf("secret should be unchanged, got %+v", results[0])
+	}
+}
+
+func TestThreeWayMergePatchType(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"name": "w"},
+		"spec":       map[string]interface{}{"size": int64(1)},
+	}}
+	live := obj.DeepCopy()
+	modified, err := setLastApplied(obj)
+	if err != nil {
+		t.Fatalf("set last applied failed: %v", err)
+	}
+	pt, _, err := threeWayMergePatch(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, live, modified)
+	if err != nil || pt != types.MergePatchType {
+		t.Errorf("CRD should use JSON merge patch, got %s %v", pt, err)
+	}
+	pt, _, err = threeWayMergePatch(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, live, modified)
+	if err != nil || pt != types.StrategicMergePatchType {
+		t.Errorf("built-in resource should use strategic merge patch, got %s %v", pt, err)
+	}
+}
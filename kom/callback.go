@@ -3,6 +3,7 @@ package kom
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"k8s.io/klog/v2"
 )
@@ -13,9 +14,10 @@ type callbacks struct {
 }
 
 type processor struct {
-	km        *Kubectl
-	fns       []func(*Kubectl) error
-	callbacks []*callback
+	km         *Kubectl
+	fns        []func(*Kubectl) error
+	finallyFns []func(*Kubectl) error // 收尾回调，无论执行成功与否都会调用
+	callbacks  []*callback
 }
 type callback struct {
 	name      string
@@ -23,12 +25,13 @@ type callback struct {
 	after     string
 	remove    bool
 	replace   bool
+	finally   bool // 是否为收尾回调
 	handler   func(*Kubectl) error
 	processor *processor
 }
 
 func (k *Kubectl) initializeCallbacks() *callbacks {
-	return &callbacks{
+	cs := &callbacks{
 		processors: map[string]*processor{
 			"doc":          {km: k},
 			"get":          {km: k},
@@ -43,8 +46,21 @@ func (k *Kubectl) initializeCallbacks() *callbacks {
 			"describe":     {km: k},
 			"stream-exec":  {km: k},
 			"port-forward": {km: k},
+			// Ctl 高层操作
+			"drain":      {km: k},
+			"cordon":     {km: k},
+			"taint":      {km: k},
+			"rollout":    {km: k},
+			"scale":      {km: k},
+			"image":      {km: k},
+			"node-shell": {km: k},
+			"terminal":   {km: k},
+			"debug":      {km: k},
 		},
 	}
+	cs.registerCtlHandlers()
+	cs.registerRedactHandlers()
+	return cs
 }
 
 func (cs *callbacks) Create() *processor {
@@ -87,6 +103,38 @@ func (cs *callbacks) Logs() *processor {
 func (cs *callbacks) Watch() *processor {
 	return cs.processors["watch"]
 }
+func (cs *callbacks) Drain() *processor {
+	return cs.processors["drain"]
+}
+func (cs *callbacks) Cordon() *processor {
+	return cs.processors["cordon"]
+}
+func (cs *callbacks) Taint() *processor {
+	return cs.processors["taint"]
+}
+func (cs *callbacks) Rollout() *processor {
+	return cs.processors["rollout"]
+}
+func (cs *callbacks) Scale() *processor {
+	return cs.processors["scale"]
+}
+func (cs *callbacks) Image() *processor {
+	return cs.processors["image"]
+}
+func (cs *callbacks) NodeShell() *processor {
+	return cs.processors["node-shell"]
+}
+func (cs *callbacks) Terminal() *processor {
+	return cs.processors["terminal"]
+}
+func (cs *callbacks) Debug() *processor {
+	return cs.processors["debug"]
+}
+
+// Processor 按名称获取处理器，不存在时返回 nil
+func (cs *callbacks) Processor(name string) *processor {
+	return cs.processors[name]
+}
 func (c *callback) Remove(name string) error {
 	klog.V(4).Infof("removing callback `%s` \n", name)
 	c.name = name
@@ -100,6 +148,12 @@ func (c *callback) Replace(name string, fn func(*Kubectl) error) error {
 	c.name = name
 	c.handler = fn
 	c.replace = true
+	// 替换收尾回调时，保持收尾属性不变
+	for _, cb := range c.processor.callbacks {
+		if cb.name == name && !cb.remove {
+			c.finally = cb.finally
+		}
+	}
 	c.processor.callbacks = append(c.processor.callbacks, c)
 	return c.processor.compile()
 }
@@ -138,15 +192,22 @@ func (p *processor) Replace(name string, fn func(*Kubectl) error) error {
 	return (&callback{processor: p}).Replace(name, fn)
 }
 
-func (p *processor) Execute(k *Kubectl) error {
+func (p *processor) Execute(k *Kubectl) (err error) {
 	// // 执行前做必要检查
 	// if k.Statement.GVR.Empty() {
 	// 	k.Statement.Error = fmt.Errorf("请先调用Resource()、CRD()、GVR()等方法指明操作对象的GVR")
 	// 	return k.Statement.Error
 	// }
 
+	// 记录本次执行的开始时间，收尾回调执行完毕后出栈
+	stmt := k.Statement
+	stmt.execStarts = append(stmt.execStarts, time.Now())
+	defer func() { stmt.execStarts = stmt.execStarts[:len(stmt.execStarts)-1] }()
+	if len(p.finallyFns) > 0 {
+		defer p.runFinally(k, &err)
+	}
 	for _, f := range p.fns {
-		err := f(k)
+		err = f(k)
 		if err != nil {
 			return err
 		}
@@ -154,6 +215,21 @@ func (p *processor) Execute(k *Kubectl) error {
 	return nil
 }
 
+// runFinally 执行收尾回调
+// 收尾回调执行期间 k.Error 为本次执行的结果，执行完毕后恢复为调用前的值，
+// 不会覆盖调用方设置的 k.Error，也不会将本次结果带入后续的链式调用。
+// 收尾回调返回的错误只记录日志，不会改变执行结果
+func (p *processor) runFinally(k *Kubectl, err *error) {
+	prev := k.Error
+	k.Error = *err
+	defer func() { k.Error = prev }()
+	for _, f := range p.finallyFns {
+		if e := f(k); e != nil {
+			klog.V(4).Infof("finally callback error: %v", e)
+		}
+	}
+}
+
 func (p *processor) Before(name string) *callback {
 	return &callback{before: name, processor: p}
 }
@@ -162,6 +238,14 @@ func (p *processor) After(name string) *callback {
 	return &callback{after: name, processor: p}
 }
 
+// Finally 注册收尾回调，无论前面的回调成功还是失败，收尾回调都会在最后执行
+// 适用于审计、统计等需要获取执行结果的场景，执行结果可从 k.Error 中获取
+// Example:
+// k.Callback().Create().Finally().Register("audit", fn)
+func (p *processor) Finally() *callback {
+	return &callback{finally: true, processor: p}
+}
+
 func (p *processor) Register(name string, fn func(*Kubectl) error) error {
 	return (&callback{processor: p}).Register(name, fn)
 }
@@ -181,8 +265,20 @@ func (p *processor) compile() (err error) {
 	}
 	p.callbacks = callbacks
 
-	if p.fns, err = sortCallbacks(p.callbacks); err != nil {
+	var normal, finally []*callback
+	for _, cb := range p.callbacks {
+		if cb.finally {
+			finally = append(finally, cb)
+		} else {
+			normal = append(normal, cb)
+		}
+	}
+	if p.fns, err = sortCallbacks(normal); err != nil {
 		klog.V(4).Infof("Got error when compile callbacks, got %v", err)
+		return
+	}
+	if p.finallyFns, err = sortCallbacks(finally); err != nil {
+		klog.V(4).Infof("Got error when compile finally callbacks, got %v", err)
 	}
 	return
 }
@@ -153,3 +153,53 @@ func TestCallbackObjectChaining(t *testing.T) {
 	// But we can register and verify sorting if we had a full test case.
 	// For now just ensuring methods can be called is enough for coverage.
 }
+
+func TestCallbackFinally(t *testing.T) {
+	RegisterFakeCluster("cb-finally")
+	k := Cluster("cb-finally")
+	p := k.Callback().Doc()
+
+	var order []string
+	var finallyErr error
+	p.Register("step", func(k *Kubectl) error {
+		order = append(order, "step")
+		return fmt.Errorf("step error")
+	})
+	p.Register("skipped", func(k *Kubectl) error {
+		order = append(order, "skipped")
+		return nil
+	})
+	p.Finally().Register("finally", func(k *Kubectl) error {
+		order = append(order, "finally")
+		finallyErr = k.Error
+		return fmt.Errorf("ignored")
+	})
+
+	err := p.Execute(k)
+	if err == nil || err.Error() != "step error" {
+		t.Errorf("Execute should return step error, got %v", err)
+	}
+	if len(order) != 2 || order[1] != "finally" {
+		t.Errorf("Finally callback should run after failed step, got %v", order)
+	}
+	if finallyErr == nil || finallyErr.Error() != "step error" {
+		t.Errorf("Finally callback should see execution error, got %v", finallyErr)
+	}
+	if k.Error != nil {
+		t.Errorf("Finally callback should not leave the execution error on k.Error, got %v", k.Error)
+	}
+
+	// Replace 保持收尾属性
+	p.Remove("step")
+	order = nil
+	p.Replace("finally", func(k *Kubectl) error {
+		order = append(order, "finally-replaced")
+		return nil
+	})
+	if err = p.Execute(k); err != nil {
+		t.Errorf("Execute failed: %v", err)
+	}
+	if len(order) != 2 || order[0] != "skipped" || order[1] != "finally-replaced" {
+		t.Errorf("Finally replace failed, got %v", order)
+	}
+}
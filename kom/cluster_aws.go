@@ -109,10 +109,12 @@ func (c *ClusterInstances) RegisterAWSClusterWithID(config *aws.EKSAuthConfig, c
 						klog.V(3).Infof("Token expired for cluster %s, refreshing...", clusterID)
 						if token, _, err := authProvider.GetToken(tokenCtx); err != nil {
 							klog.Errorf("Failed to refresh token for cluster %s: %v", clusterID, err)
+							cluster.notifyTokenRefresh(err)
 						} else {
 							// 更新 rest.Config 中的 BearerToken
 							cluster.Config.BearerToken = token
 							klog.V(2).Infof("Successfully refreshed token for cluster %s", clusterID)
+							cluster.notifyTokenRefresh(nil)
 						}
 					}
 				}
@@ -178,3 +180,24 @@ func (ci *ClusterInst) StopTokenRefresh() {
 		ci.tokenRefreshCancel = nil
 	}
 }
+
+// OnTokenRefresh 注册 EKS token 刷新结果的监听函数，err 为 nil 表示刷新成功
+// 可用于统计刷新结果、告警等
+func (ci *ClusterInst) OnTokenRefresh(fn func(err error)) {
+	if fn == nil {
+		return
+	}
+	ci.tokenRefreshMu.Lock()
+	defer ci.tokenRefreshMu.Unlock()
+	ci.tokenRefreshListeners = append(ci.tokenRefreshListeners, fn)
+}
+
+// notifyTokenRefresh 通知 token 刷新结果
+func (ci *ClusterInst) notifyTokenRefresh(err error) {
+	ci.tokenRefreshMu.Lock()
+	listeners := append([]func(err error){}, ci.tokenRefreshListeners...)
+	ci.tokenRefreshMu.Unlock()
+	for _, fn := range listeners {
+		fn(err)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
@@ -39,6 +40,7 @@ type ClusterInst struct {
 	Client             kubernetes.Interface         // kubernetes 客户端
 	Config             *rest.Config                 // rest config
 	DynamicClient      dynamic.Interface            // 动态客户端
+	MetadataClient     metadata.Interface           // 元数据客户端，只获取 metadata
 	apiResources       []*metav1.APIResource        // 当前k8s已注册资源
 	crdList            []*unstructured.Unstructured // 当前k8s已注册资源 //TODO 定时更新或者Watch更新
 	callbacks          *callbacks                   // 回调
@@ -48,11 +50,18 @@ type ClusterInst struct {
 	Cache              *ristretto.Cache[string, any]
 	openAPISchema      *openapi_v2.Document // openapi
 	watchCRDCancelFunc context.CancelFunc   // CRD取消方法，用于断开连接的时候停止
+	guard              *guard               // 注册时设置的访问限制，如只读、限定命名空间
+	redactPolicy       *SecretRedactPolicy  // Secret 脱敏策略
+	portForwards       sync.Map             // 运行中的端口转发会话 map[string]*PortForwardSession
+	debugPods          sync.Map             // 等待到期删除的调试 Pod map[namespace/name]*time.Timer
 
 	// AWS EKS 特定字段
 	AWSAuthProvider    *aws.AuthProvider  // AWS 认证提供者
 	IsEKS              bool               // 是否为 EKS 集群
 	tokenRefreshCancel context.CancelFunc // token 刷新取消函数
+	// token 刷新结果监听
+	tokenRefreshMu        sync.Mutex
+	tokenRefreshListeners []func(err error)
 }
 
 // Clusters 集群实例管理器
@@ -214,8 +223,13 @@ func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string
 	if err != nil {
 		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
 	}
-	cluster.Client = client               // kubernetes 客户端
-	cluster.DynamicClient = dynamicClient // 动态客户端
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
+	cluster.Client = client                 // kubernetes 客户端
+	cluster.DynamicClient = dynamicClient   // 动态客户端
+	cluster.MetadataClient = metadataClient // 元数据客户端
 	// 缓存
 	cluster.crdList = k.initializeCRDList(time.Minute * 10) // CRD列表,10分钟缓存
 	cluster.callbacks = k.initializeCallbacks()             // 回调
@@ -226,6 +240,12 @@ func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string
 	if c.callbackRegisterFunc != nil {                 // 注册回调方法
 		c.callbackRegisterFunc(cluster)
 	}
+	// 访问限制，在变更类处理器最前面注册守卫回调
+	cluster.guard = newGuard(params)
+	cluster.redactPolicy = params.RedactSecrets
+	if err = registerGuardCallbacks(cluster.callbacks, cluster.guard); err != nil {
+		return nil, fmt.Errorf("RegisterByConfigWithID Error %s %v", id, err)
+	}
 
 	cacheCfg := params.CacheConfig
 	if cacheCfg == nil {
@@ -235,6 +255,12 @@ func (c *ClusterInstances) RegisterByConfigWithID(config *rest.Config, id string
 			BufferItems: 64,      // number of keys per Get buffer.
 		}
 	}
+	if params.CacheMetrics && !cacheCfg.Metrics {
+		// 记录命中率等统计信息，不修改调用方传入的配置
+		withMetrics := *cacheCfg
+		withMetrics.Metrics = true
+		cacheCfg = &withMetrics
+	}
 	cache, err := ristretto.NewCache(cacheCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cache: %w", err)
@@ -267,6 +293,11 @@ func (c *ClusterInstances) RemoveClusterById(id string) {
 	if value, exists := c.clusters.Load(id); exists {
 		cluster := value.(*ClusterInst)
 
+		// 停止该集群上运行中的端口转发
+		cluster.stopPortForwards()
+		// 取消调试 Pod 的定时删除
+		cluster.stopDebugPodTimers()
+
 		// 如果是 EKS 集群，停止 token 刷新
 		if cluster.IsEKS {
 			if cluster.tokenRefreshCancel != nil {
@@ -285,6 +316,7 @@ func (c *ClusterInstances) RemoveClusterById(id string) {
 		// 释放其他成员（如有需要，可扩展）
 		cluster.Client = nil
 		cluster.DynamicClient = nil
+		cluster.MetadataClient = nil
 		cluster.apiResources = nil
 		cluster.crdList = nil
 		cluster.callbacks = nil
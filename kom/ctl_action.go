@@ -0,0 +1,48 @@
+package kom
+
+import "fmt"
+
+// Ctl 高层操作对应的处理器名称
+var ctlProcessors = []string{"drain", "cordon", "taint", "rollout", "scale", "image", "node-shell", "terminal", "debug"}
+
+// CtlAction Ctl 高层操作的意图及参数
+// 执行 Drain、Undo、Stop 等高层操作时，会通过同名处理器执行，并在 Statement.CtlAction 中携带意图，
+// 回调可据此将 "drain node X" 作为一个整体操作进行拦截、审计。
+// 高层操作内部的 Patch、Update 等底层操作执行时，Statement.CtlAction 同样可见。
+type CtlAction struct {
+	Processor string            `json:"processor"`        // 处理器名称，如 drain、rollout
+	Action    string            `json:"action"`           // 具体动作，如 uncordon、undo
+	Params    map[string]string `json:"params,omitempty"` // 操作参数，如 replicas、taint
+	run       func() error      // 实际执行的操作
+}
+
+// registerCtlHandlers 为 Ctl 高层操作的处理器注册默认回调，名称为 kom:<处理器名称>
+func (cs *callbacks) registerCtlHandlers() {
+	for _, name := range ctlProcessors {
+		_ = cs.processors[name].Register("kom:"+name, runCtlAction)
+	}
+}
+
+// runCtlAction 执行 Statement 中携带的高层操作
+func runCtlAction(k *Kubectl) error {
+	action := k.Statement.CtlAction
+	if action == nil || action.run == nil {
+		return fmt.Errorf("ctl action is not set")
+	}
+	return action.run()
+}
+
+// execCtl 通过处理器执行高层操作
+// 执行期间 Statement.CtlAction 为本次操作，结束后恢复，以支持 Drain 内部调用 Cordon 等嵌套操作
+func (k *Kubectl) execCtl(processor, action string, params map[string]string, fn func() error) error {
+	stmt := k.Statement
+	prev := stmt.CtlAction
+	stmt.CtlAction = &CtlAction{
+		Processor: processor,
+		Action:    action,
+		Params:    params,
+		run:       fn,
+	}
+	defer func() { stmt.CtlAction = prev }()
+	return k.Callback().Processor(processor).Execute(k)
+}
@@ -0,0 +1,58 @@
+package kom_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/weibaohui/kom/audit"
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/metrics"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// Ctl 高层操作内部的 get、patch 与外层共用一个 Statement，内外层的耗时应分别记录
+func TestCtlActionNestedDuration(t *testing.T) {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "nested-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(2))},
+	}
+	k := kom.RegisterFakeCluster("ctl-action-nested-cluster", deploy)
+
+	buf := audit.NewRingBuffer(10)
+	if _, err := audit.Register(k, audit.WithSink(buf), audit.WithVerbs(audit.VerbScale, audit.VerbPatch)); err != nil {
+		t.Fatalf("register auditor failed: %v", err)
+	}
+	m := metrics.New(metrics.WithVerbs(metrics.VerbGet, metrics.VerbPatch, metrics.VerbScale))
+	if err := m.Register(k); err != nil {
+		t.Fatalf("register metrics failed: %v", err)
+	}
+	_ = k.Callback().Patch().Before("fake:patch").Register("test:slow", func(k *kom.Kubectl) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	if err := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("nested-deploy").Ctl().Scaler().Stop(); err != nil {
+		t.Fatalf("stop failed: %v", err)
+	}
+
+	events := map[string]*audit.Event{}
+	for _, e := range buf.Events() {
+		events[e.Verb] = &e
+	}
+	scale, patch := events[audit.VerbScale], events[audit.VerbPatch]
+	if scale == nil || patch == nil {
+		t.Fatalf("expected scale and patch events, got %v", events)
+	}
+	if scale.Duration < 10*time.Millisecond || scale.Duration < patch.Duration {
+		t.Errorf("scale duration should include the nested patch, scale=%v patch=%v", scale.Duration, patch.Duration)
+	}
+	if scale.Time.After(patch.Time) {
+		t.Errorf("scale should start before the nested patch, scale=%v patch=%v", scale.Time, patch.Time)
+	}
+	if n := testutil.CollectAndCount(m, "kom_operation_duration_seconds"); n != 3 {
+		t.Errorf("expected duration series for get, patch and scale, got %d", n)
+	}
+}
@@ -0,0 +1,88 @@
+package kom
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestCtlActionProcessors(t *testing.T) {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "ctl-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+	}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "ctl-node"}}
+	k := RegisterFakeCluster("ctl-action-cluster", deploy, node)
+
+	// 记录高层操作，以及底层 patch 操作所属的高层操作
+	var actions []string
+	_ = k.Callback().Scale().Before("kom:scale").Register("test:record", func(k *Kubectl) error {
+		a := k.Statement.CtlAction
+		actions = append(actions, fmt.Sprintf("%s/%s/%s/%v", a.Processor, a.Action, k.Statement.Name, a.Params))
+		return nil
+	})
+	var patchActions []string
+	_ = k.Callback().Patch().Before("fake:patch").Register("test:record", func(k *Kubectl) error {
+		if a := k.Statement.CtlAction; a != nil {
+			patchActions = append(patchActions, a.Action)
+		}
+		return nil
+	})
+
+	err := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("ctl-deploy").Ctl().Deployment().Scale(3)
+	if err != nil {
+		t.Fatalf("scale failed: %v", err)
+	}
+	if len(actions) != 1 || actions[0] != "scale/scale/ctl-deploy/map[replicas:3]" {
+		t.Errorf("unexpected scale actions %v", actions)
+	}
+	if len(patchActions) != 1 || patchActions[0] != "scale" {
+		t.Errorf("patch should carry the ctl action, got %v", patchActions)
+	}
+
+	// 拦截高层操作：禁止 cordon 指定节点
+	_ = k.Callback().Cordon().Before("kom:cordon").Register("test:deny", func(k *Kubectl) error {
+		if k.Statement.CtlAction.Action == "cordon" && k.Statement.Name == "ctl-node" {
+			return fmt.Errorf("cordon node %s is not allowed", k.Statement.Name)
+		}
+		return nil
+	})
+	err = k.Resource(&v1.Node{}).Name("ctl-node").Ctl().Node().Cordon()
+	if err == nil {
+		t.Fatalf("cordon should be denied")
+	}
+	var current v1.Node
+	if err = k.Resource(&current).Name("ctl-node").Get(&current).Error; err != nil {
+		t.Fatalf("get node failed: %v", err)
+	}
+	if current.Spec.Unschedulable {
+		t.Errorf("node should not be cordoned")
+	}
+	// uncordon 不受影响
+	if err = k.Resource(&v1.Node{}).Name("ctl-node").Ctl().Node().UnCordon(); err != nil {
+		t.Errorf("uncordon failed: %v", err)
+	}
+
+	// 执行结束后 CtlAction 恢复
+	tx := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("ctl-deploy")
+	_ = tx.Ctl().Scaler().Stop()
+	if tx.Statement.CtlAction != nil {
+		t.Errorf("ctl action should be cleared after execution")
+	}
+}
+
+func TestCtlActionReplace(t *testing.T) {
+	k := RegisterFakeCluster("ctl-action-replace-cluster", &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n1"}})
+	called := false
+	_ = k.Callback().Drain().Replace("kom:drain", func(k *Kubectl) error {
+		called = true
+		return nil
+	})
+	if err := k.Resource(&v1.Node{}).Name("n1").Ctl().Node().Drain(); err != nil || !called {
+		t.Errorf("replaced drain handler should be called, err=%v called=%v", err, called)
+	}
+}
@@ -110,22 +110,29 @@ func (d *deploy) ManagedLatestReplicaSet() (*v1.ReplicaSet, error) {
 	return nil, fmt.Errorf("未发现Deployment[%s]下的最新的RS", item.GetName())
 }
 
-func (d *deploy) ReplaceImageTag(targetContainerName string, tag string) (*v1.Deployment, error) {
+func (d *deploy) ReplaceImageTag(targetContainerName string, tag string) (item *v1.Deployment, err error) {
+	params := map[string]string{"container": targetContainerName, "tag": tag}
+	err = d.kubectl.execCtl("image", "replace-image-tag", params, func() (e error) {
+		item, e = d.replaceContainerImageTag(targetContainerName, tag)
+		return e
+	})
+	return
+}
+func (d *deploy) replaceContainerImageTag(targetContainerName string, tag string) (*v1.Deployment, error) {
 	var item v1.Deployment
-	err := d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&item).Get(&item).Error
-
+	err := d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&item).UpdateWithRetry(&item, func(obj interface{}) error {
+		for i := range item.Spec.Template.Spec.Containers {
+			c := &item.Spec.Template.Spec.Containers[i]
+			if c.Name == targetContainerName {
+				c.Image = replaceImageTag(c.Image, tag)
+			}
+		}
+		return nil
+	}).Error
 	if err != nil {
 		return nil, err
 	}
-
-	for i := range item.Spec.Template.Spec.Containers {
-		c := &item.Spec.Template.Spec.Containers[i]
-		if c.Name == targetContainerName {
-			c.Image = replaceImageTag(c.Image, tag)
-		}
-	}
-	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&item).Update(&item).Error
-	return &item, err
+	return &item, nil
 }
 
 // replaceImageTag 替换镜像的 tag
@@ -16,6 +16,9 @@ func (d *daemonSet) Restart() error {
 	return d.kubectl.Ctl().Rollout().Restart()
 }
 func (d *daemonSet) Stop() error {
+	return d.kubectl.execCtl("scale", "stop", nil, d.stop)
+}
+func (d *daemonSet) stop() error {
 
 	patchData := `{
   "spec": {
@@ -37,6 +40,9 @@ func (d *daemonSet) Stop() error {
 	return nil
 }
 func (d *daemonSet) Restore() error {
+	return d.kubectl.execCtl("scale", "restore", nil, d.restore)
+}
+func (d *daemonSet) restore() error {
 
 	patchData := `{
   "spec": {
@@ -14,6 +14,8 @@ import (
 	v1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
@@ -26,6 +28,9 @@ type node struct {
 // Cordon node
 // cordon 命令的核心功能是将节点标记为 Unschedulable。在此状态下，调度器（Scheduler）将不会向该节点分配新的 Pod。
 func (d *node) Cordon() error {
+	return d.kubectl.execCtl("cordon", "cordon", nil, d.cordon)
+}
+func (d *node) cordon() error {
 	var item interface{}
 	patchData := `{"spec":{"unschedulable":true}}`
 	err := d.kubectl.Patch(&item, types.StrategicMergePatchType, patchData).Error
@@ -35,6 +40,9 @@ func (d *node) Cordon() error {
 // UnCordon node
 // uncordon 命令是 cordon 的逆操作，用于将节点从不可调度状态恢复为可调度状态。
 func (d *node) UnCordon() error {
+	return d.kubectl.execCtl("cordon", "uncordon", nil, d.unCordon)
+}
+func (d *node) unCordon() error {
 	var item interface{}
 	patchData := `{"spec":{"unschedulable":null}}`
 	err := d.kubectl.Patch(&item, types.StrategicMergePatchType, patchData).Error
@@ -51,48 +59,57 @@ func (d *node) UnCordon() error {
 // Taint("dedicated2=special-user:NoSchedule")
 // Taint("dedicated2:NoSchedule")
 func (d *node) Taint(str string) error {
+	return d.kubectl.execCtl("taint", "taint", map[string]string{"taint": str}, func() error {
+		return d.taint(str)
+	})
+}
+func (d *node) taint(str string) error {
 	taint, err := parseTaint(str)
 	if err != nil {
 		return err
 	}
-	var original *corev1.Node
-	err = d.kubectl.Get(&original).Error
-	if err != nil {
-		return err
-	}
-	taints := original.Spec.Taints
-	if taints == nil || len(taints) == 0 {
-		taints = []corev1.Taint{*taint}
-	} else {
-		taints = append(taints, *taint)
-	}
-
 	var item interface{}
-	patchData := fmt.Sprintf(`{"spec":{"taints":%s}}`, utils.ToJSON(taints))
-	err = d.kubectl.Patch(&item, types.StrategicMergePatchType, patchData).Error
+	err = d.kubectl.PatchWithRetry(&item, types.StrategicMergePatchType, func(current *unstructured.Unstructured) (string, error) {
+		var original corev1.Node
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(current.Object, &original); err != nil {
+			return "", err
+		}
+		taints := original.Spec.Taints
+		if taints == nil || len(taints) == 0 {
+			taints = []corev1.Taint{*taint}
+		} else {
+			taints = append(taints, *taint)
+		}
+		return fmt.Sprintf(`{"spec":{"taints":%s}}`, utils.ToJSON(taints)), nil
+	}).Error
 	return err
 }
 func (d *node) UnTaint(str string) error {
+	return d.kubectl.execCtl("taint", "untaint", map[string]string{"taint": str}, func() error {
+		return d.unTaint(str)
+	})
+}
+func (d *node) unTaint(str string) error {
 	taint, err := parseTaint(str)
 	if err != nil {
 		return err
 	}
-	var original *corev1.Node
-	err = d.kubectl.Get(&original).Error
-	if err != nil {
-		return err
-	}
-	taints := original.Spec.Taints
-	if taints == nil || len(taints) == 0 {
-		return fmt.Errorf("taint %s not found", str)
-	}
-
-	taints = slice.Filter(taints, func(index int, item corev1.Taint) bool {
-		return item.Key != taint.Key
-	})
 	var item interface{}
-	patchData := fmt.Sprintf(`{"spec":{"taints":%s}}`, utils.ToJSON(taints))
-	err = d.kubectl.Patch(&item, types.StrategicMergePatchType, patchData).Error
+	err = d.kubectl.PatchWithRetry(&item, types.StrategicMergePatchType, func(current *unstructured.Unstructured) (string, error) {
+		var original corev1.Node
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(current.Object, &original); err != nil {
+			return "", err
+		}
+		taints := original.Spec.Taints
+		if taints == nil || len(taints) == 0 {
+			return "", fmt.Errorf("taint %s not found", str)
+		}
+
+		taints = slice.Filter(taints, func(index int, item corev1.Taint) bool {
+			return item.Key != taint.Key
+		})
+		return fmt.Sprintf(`{"spec":{"taints":%s}}`, utils.ToJSON(taints)), nil
+	}).Error
 	return err
 }
 
@@ -117,6 +134,9 @@ func (d *node) AllNodeLabels() (map[string]string, error) {
 // Drain node
 // drain 通常在节点需要进行维护时使用。它不仅会标记节点为不可调度，还会逐一驱逐（Evict）该节点上的所有 Pod。
 func (d *node) Drain() error {
+	return d.kubectl.execCtl("drain", "drain", nil, d.drain)
+}
+func (d *node) drain() error {
 	// todo 增加--force的处理，也就强制驱逐所有pod，即便是不满足PDB
 	name := d.kubectl.Statement.Name
 
@@ -195,63 +215,42 @@ func (d *node) Drain() error {
 // CreateNodeShell 获取节点NodeShell
 // 要求容器内必须含有nsenter
 func (d *node) CreateNodeShell(image ...string) (namespace, podName, containerName string, err error) {
-	// 获取节点
+	params := map[string]string{}
+	if len(image) > 0 {
+		params["image"] = image[0]
+	}
+	err = d.kubectl.execCtl("node-shell", "node-shell", params, func() (e error) {
+		namespace, podName, containerName, e = d.createNodeShell(image...)
+		return e
+	})
+	return
+}
+// createNodeShell 使用节点调试 Pod 创建 NodeShell，容器通过 nsenter 进入节点的命名空间
+func (d *node) createNodeShell(image ...string) (namespace, podName, containerName string, err error) {
 	runImage := "alpine:latest"
 	if len(image) > 0 {
 		runImage = image[0]
 	}
-	namespace = "kube-system"
-	containerName = "shell"
-	podName = fmt.Sprintf("node-shell-%s", strings.ToLower(random.RandString(8)))
-	var yaml = `
-apiVersion: v1
-kind: Pod
-metadata:
-  name: %s
-  namespace: %s
-spec:
-  containers:
-  - args:
-    - -t
-    - "1"
-    - -m
-    - -u
-    - -i
-    - -n
-    - sleep
-    - "14000"
-    command:
-    - nsenter
-    image: %s
-    name: %s
-    imagePullPolicy: IfNotPresent
-    securityContext:
-      privileged: true
-  hostIPC: true
-  hostNetwork: true
-  hostPID: true
-  restartPolicy: Never
-  nodeName: %s	
-  tolerations:
-  - operator: Exists
-`
-	yaml = fmt.Sprintf(yaml, podName, namespace, runImage, containerName, d.kubectl.Statement.Name)
-
-	ret := d.kubectl.Applier().Apply(yaml)
-	// [Pod/node-shell-xqrbqqvt created]
-	// 检查是否包含 created
-	klog.V(6).Infof("%s Node Shell 创建 结果 %s", d.kubectl.Statement.Name, ret)
+	options := &debugOptions{
+		name:        "shell",
+		podName:     fmt.Sprintf("node-shell-%s", strings.ToLower(random.RandString(8))),
+		namespace:   "kube-system",
+		command:     []string{"nsenter", "-t", "1", "-m", "-u", "-i", "-n", "sleep", "14000"},
+		pullPolicy:  v1.PullIfNotPresent,
+		tolerations: []v1.Toleration{{Operator: v1.TolerationOpExists}},
+	}
+	item := nodeDebugPod(d.kubectl.Statement.Name, runImage, options)
 
-	// 创建成功
-	if len(ret) > 0 && strings.Contains(ret[0], "created") {
-		// 等待启动或者超时,超时采用默认的超时时间
-		err = d.waitPodReady(namespace, podName, d.kubectl.Statement.CacheTTL)
+	err = d.kubectl.newInstance().WithContext(d.kubectl.Statement.Context).Resource(item).Create(item).Error
+	if err != nil {
+		err = fmt.Errorf("node shell 创建失败 %w", err)
 		return
 	}
+	klog.V(6).Infof("%s Node Shell %s/%s 已创建", d.kubectl.Statement.Name, options.namespace, options.podName)
 
-	// 创建失败
-	err = fmt.Errorf("node shell 创建失败 %s", ret)
-	return
+	// 等待启动或者超时,超时采用默认的超时时间
+	err = d.waitPodReady(options.namespace, options.podName, d.kubectl.Statement.CacheTTL)
+	return options.namespace, options.podName, options.name, err
 }
 func (d *node) waitPodReady(ns, podName string, ttl time.Duration) error {
 	if ttl < time.Second {
@@ -316,6 +315,18 @@ func (d *node) waitPodReady(ns, podName string, ttl time.Duration) error {
 // 要求容器内必须含有nsenter
 // CreateKubectlShell 创建一个用于运行 kubectl 的 Pod，并传入 kubeconfig 配置内容
 func (d *node) CreateKubectlShell(kubeconfig string, image ...string) (namespace, podName, containerName string, err error) {
+	// kubeconfig 属于敏感信息，不放入参数
+	params := map[string]string{}
+	if len(image) > 0 {
+		params["image"] = image[0]
+	}
+	err = d.kubectl.execCtl("node-shell", "kubectl-shell", params, func() (e error) {
+		namespace, podName, containerName, e = d.createKubectlShell(kubeconfig, image...)
+		return e
+	})
+	return
+}
+func (d *node) createKubectlShell(kubeconfig string, image ...string) (namespace, podName, containerName string, err error) {
 	// 默认的 kubectl 镜像
 	runImage := "bitnami/kubectl:latest"
 	if len(image) > 0 {
@@ -426,12 +437,21 @@ func isMirrorPod(pod *corev1.Pod) bool {
 // 驱逐 Pod
 func (d *node) evictPod(pod *corev1.Pod) error {
 	klog.V(8).Infof("evicting pod %s/%s \n", pod.Namespace, pod.Name)
+	// 驱逐直接调用 client，不经过 callback，需单独校验访问限制
+	if cluster := d.kubectl.parentCluster(); cluster != nil {
+		if err := cluster.guard.check(d.kubectl.ID, "evict", "Pod", pod.Namespace, pod.Name); err != nil {
+			return err
+		}
+	}
 	eviction := &policyv1.Eviction{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      pod.Name,
 			Namespace: pod.Namespace,
 		},
 	}
+	if d.kubectl.Statement.DryRun {
+		eviction.DeleteOptions = &metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}
+	}
 	err := d.kubectl.Client().PolicyV1().Evictions(pod.Namespace).Evict(d.kubectl.Statement.Context, eviction)
 
 	// err := d.kubectl.newInstance().Resource(eviction).Create(eviction).Error
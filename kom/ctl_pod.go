@@ -10,12 +10,13 @@ import (
 type pod struct {
 	kubectl *Kubectl
 	Error   error
+	debug   *debugRoute // 文件操作通过调试容器执行，见 DebugFiles
 }
 
 func (p *pod) ContainerName(c string) *pod {
 	tx := p.kubectl.getInstance()
 	tx.Statement.ContainerName = c
-	return &pod{kubectl: tx, Error: p.Error}
+	return &pod{kubectl: tx, Error: p.Error, debug: p.debug}
 }
 
 func (p *pod) Command(command string, args ...string) *pod {
@@ -62,6 +63,7 @@ func (p *pod) GetLogs(requestPtr interface{}, opt *v1.PodLogOptions) *pod {
 	tx.Error = tx.Callback().Logs().Execute(tx)
 	return &pod{kubectl: tx, Error: tx.Error}
 }
+// PortForward 将本地 localPort 端口转发到 Pod 的 podPort 端口，只监听 localhost，阻塞直到 stopCh 关闭或连接中断
 func (p *pod) PortForward(localPort, podPort string, stopCh chan struct{}) *Kubectl {
 	tx := p.kubectl.getInstance()
 	tx.Statement.PortForwardLocalPort = localPort
@@ -0,0 +1,348 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/random"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// defaultDebugFilesImage DebugFiles 未指定镜像时使用的镜像，需包含 find、stat、tar、sha256sum
+	defaultDebugFilesImage = "busybox:1.36"
+	// debugFilesPrefix 文件操作使用的调试容器名称前缀，用于复用已有的调试容器
+	debugFilesPrefix = "kom-files-"
+	// debugTargetRoot 共享目标容器进程命名空间后，目标容器根目录在调试容器中的路径
+	debugTargetRoot = "/proc/1/root"
+	// debugTargetCwd 目标容器工作目录在调试容器中的路径
+	debugTargetCwd = "/proc/1/cwd"
+)
+
+// DebugOption 调试容器的配置项
+type DebugOption func(*debugOptions)
+
+type debugOptions struct {
+	name         string
+	target       string
+	command      []string
+	stdin        bool
+	tty          bool
+	pullPolicy   v1.PullPolicy
+	capabilities []v1.Capability
+	timeout      time.Duration
+
+	// DebugCopy、DebugNode 创建 Pod 时使用
+	podName        string
+	namespace      string
+	images         map[string]string
+	shareProcesses bool
+	tolerations    []v1.Toleration
+	pullSecrets    []v1.LocalObjectReference
+	ttl            time.Duration
+}
+
+// DebugContainerName 设置调试容器名称，默认为 debugger-xxxxx
+func DebugContainerName(name string) DebugOption {
+	return func(o *debugOptions) {
+		o.name = name
+	}
+}
+
+// DebugTargetContainer 设置目标容器，调试容器与其共享进程命名空间，默认为 ContainerName() 设置的容器
+func DebugTargetContainer(name string) DebugOption {
+	return func(o *debugOptions) {
+		o.target = name
+	}
+}
+
+// DebugCommand 设置调试容器的启动命令，默认使用镜像的启动命令
+func DebugCommand(command string, args ...string) DebugOption {
+	return func(o *debugOptions) {
+		o.command = append([]string{command}, args...)
+	}
+}
+
+// DebugTTY 是否分配 TTY，默认分配，便于 attach 后交互
+func DebugTTY(tty bool) DebugOption {
+	return func(o *debugOptions) {
+		o.tty = tty
+	}
+}
+
+// DebugImagePullPolicy 设置镜像拉取策略
+func DebugImagePullPolicy(policy v1.PullPolicy) DebugOption {
+	return func(o *debugOptions) {
+		o.pullPolicy = policy
+	}
+}
+
+// DebugCapabilities 为调试容器添加 Linux capabilities，如 SYS_PTRACE
+// 目标容器以非 root 用户运行时，需要 SYS_PTRACE 才能访问目标容器的文件系统
+func DebugCapabilities(capabilities ...string) DebugOption {
+	return func(o *debugOptions) {
+		for _, c := range capabilities {
+			o.capabilities = append(o.capabilities, v1.Capability(c))
+		}
+	}
+}
+
+// DebugTimeout 设置等待调试容器运行的超时时间，默认 2 分钟
+func DebugTimeout(d time.Duration) DebugOption {
+	return func(o *debugOptions) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// Debug 通过 ephemeralcontainers 子资源为 Pod 添加临时调试容器，等待其运行后返回容器名称，等同于 kubectl debug
+// 调试容器与目标容器共享进程命名空间，可以看到目标容器的进程，并通过 /proc/1/root 访问目标容器的文件系统。
+// 适用于不含 shell 的 distroless 镜像，临时容器添加后不能删除，会随 Pod 一起删除。
+// 执行时会触发 Debug() 类型的 callbacks。
+//
+// Example:
+//
+//	name, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Debug("busybox:1.36")
+//	result, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName(name).Command("ps").ExecWithResult()
+func (p *pod) Debug(image string, opts ...DebugOption) (string, error) {
+	if p.Error != nil {
+		return "", p.Error
+	}
+	if image == "" {
+		return "", fmt.Errorf("debug image is required")
+	}
+	options := &debugOptions{
+		name:    "debugger-" + strings.ToLower(random.RandString(5)),
+		target:  p.kubectl.Statement.ContainerName,
+		stdin:   true,
+		tty:     true,
+		timeout: 2 * time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	params := map[string]string{"image": image, "container": options.name, "target": options.target}
+	err := p.kubectl.execCtl("debug", "ephemeral-container", params, func() error {
+		return p.addDebugContainer(image, options)
+	})
+	if err != nil {
+		return "", err
+	}
+	return options.name, nil
+}
+
+// addDebugContainer 添加临时容器并等待其运行
+func (p *pod) addDebugContainer(image string, options *debugOptions) error {
+	stmt := p.kubectl.Statement
+	ctx := stmt.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pods := p.kubectl.Client().CoreV1().Pods(stmt.Namespace)
+	item, err := pods.Get(ctx, stmt.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	container := v1.EphemeralContainer{
+		TargetContainerName: options.target,
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:                     options.name,
+			Image:                    image,
+			ImagePullPolicy:          options.pullPolicy,
+			Command:                  options.command,
+			Stdin:                    options.stdin,
+			TTY:                      options.tty,
+			TerminationMessagePolicy: v1.TerminationMessageReadFile,
+		},
+	}
+	if len(options.capabilities) > 0 {
+		container.SecurityContext = &v1.SecurityContext{Capabilities: &v1.Capabilities{Add: options.capabilities}}
+	}
+	item.Spec.EphemeralContainers = append(item.Spec.EphemeralContainers, container)
+	if _, err = pods.UpdateEphemeralContainers(ctx, stmt.Name, item, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("add ephemeral container %s to pod %s/%s failed: %w", options.name, stmt.Namespace, stmt.Name, err)
+	}
+	klog.V(6).Infof("ephemeral container %s added to pod %s/%s, waiting for running", options.name, stmt.Namespace, stmt.Name)
+	return p.waitDebugContainer(ctx, options.name, options.timeout)
+}
+
+// waitDebugContainer 等待调试容器运行，容器退出或镜像名称错误时返回错误
+func (p *pod) waitDebugContainer(ctx context.Context, name string, timeout time.Duration) error {
+	stmt := p.kubectl.Statement
+	var reason string
+	err := wait.PollUntilContextTimeout(ctx, 500*time.Millisecond, timeout, true, func(ctx context.Context) (bool, error) {
+		item, err := p.kubectl.Client().CoreV1().Pods(stmt.Namespace).Get(ctx, stmt.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range item.Status.EphemeralContainerStatuses {
+			if status.Name != name {
+				continue
+			}
+			var done bool
+			done, reason, err = debugContainerState(status)
+			return done, err
+		}
+		return false, nil
+	})
+	if err != nil && reason != "" && wait.Interrupted(err) {
+		return fmt.Errorf("timeout waiting for ephemeral container %s to run, last reason %s: %w", name, reason, err)
+	}
+	return err
+}
+
+// debugContainerState 检查调试容器的状态，返回是否已运行及等待的原因，容器退出或无法启动时返回错误
+func debugContainerState(status v1.ContainerStatus) (bool, string, error) {
+	switch {
+	case status.State.Running != nil:
+		return true, "", nil
+	case status.State.Terminated != nil:
+		t := status.State.Terminated
+		return false, t.Reason, fmt.Errorf("container %s terminated, reason %s, exit code %d: %s", status.Name, t.Reason, t.ExitCode, t.Message)
+	case status.State.Waiting != nil:
+		w := status.State.Waiting
+		switch w.Reason {
+		case "InvalidImageName", "CreateContainerConfigError", "CreateContainerError":
+			return false, w.Reason, fmt.Errorf("container %s failed to start, reason %s: %s", status.Name, w.Reason, w.Message)
+		}
+		return false, w.Reason, nil
+	}
+	return false, "", nil
+}
+
+// debugRoute 文件操作通过调试容器执行时的路由信息
+type debugRoute struct {
+	image     string
+	opts      []DebugOption
+	once      sync.Once
+	container string
+	err       error
+}
+
+// DebugFiles 文件操作（ListFiles、StatFile、DownloadFile、UploadFile 等）通过临时调试容器执行，
+// 适用于不含 find、stat、tar 等命令的 distroless 镜像。
+// 首次执行文件操作时自动添加调试容器，已存在由 DebugFiles 创建、镜像相同且运行中的调试容器时直接复用。
+// 调试容器通过 /proc/1/root 访问目标容器的文件系统，目标容器内的绝对路径符号链接会按调试容器的根目录解析。
+// image 为空时使用 busybox:1.36。
+//
+// Example:
+//
+//	files, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").
+//		DebugFiles("").ListFiles("/app")
+func (p *pod) DebugFiles(image string, opts ...DebugOption) *pod {
+	if image == "" {
+		image = defaultDebugFilesImage
+	}
+	return &pod{kubectl: p.kubectl, Error: p.Error, debug: &debugRoute{image: image, opts: opts}}
+}
+
+// debugContainer 获取文件操作使用的调试容器，不存在时创建
+func (p *pod) debugContainer() (string, error) {
+	r := p.debug
+	r.once.Do(func() {
+		r.container, r.err = p.ensureDebugFilesContainer(r)
+	})
+	return r.container, r.err
+}
+
+func (p *pod) ensureDebugFilesContainer(r *debugRoute) (string, error) {
+	stmt := p.kubectl.Statement
+	ctx := stmt.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	item, err := p.kubectl.Client().CoreV1().Pods(stmt.Namespace).Get(ctx, stmt.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if item.Spec.ShareProcessNamespace != nil && *item.Spec.ShareProcessNamespace {
+		// 共享进程命名空间时 1 号进程为 pause 容器，无法定位目标容器的文件系统
+		return "", fmt.Errorf("pod %s/%s shares process namespace, debug files is not supported", stmt.Namespace, stmt.Name)
+	}
+	target := stmt.ContainerName
+	if target == "" {
+		if len(item.Spec.Containers) != 1 {
+			return "", fmt.Errorf("请先设置ContainerName")
+		}
+		target = item.Spec.Containers[0].Name
+	}
+
+	running := map[string]bool{}
+	for _, status := range item.Status.EphemeralContainerStatuses {
+		running[status.Name] = status.State.Running != nil
+	}
+	for _, c := range item.Spec.EphemeralContainers {
+		if strings.HasPrefix(c.Name, debugFilesPrefix) && c.Image == r.image && c.TargetContainerName == target && running[c.Name] {
+			klog.V(6).Infof("reuse debug container %s in pod %s/%s", c.Name, stmt.Namespace, stmt.Name)
+			return c.Name, nil
+		}
+	}
+
+	// 保持标准输入打开，镜像默认的 shell 会持续运行
+	opts := append([]DebugOption{
+		DebugContainerName(debugFilesPrefix + strings.ToLower(random.RandString(5))),
+		DebugTTY(false),
+	}, r.opts...)
+	opts = append(opts, DebugTargetContainer(target))
+	return p.Debug(r.image, opts...)
+}
+
+// fileExec 执行文件操作的命令，设置了 DebugFiles 时在调试容器中执行
+func (p *pod) fileExec(opts []ExecOption, command string, args ...string) (*ExecResult, error) {
+	if p.debug == nil {
+		return p.Command(command, args...).ExecWithResult(opts...)
+	}
+	name, err := p.debugContainer()
+	if err != nil {
+		return nil, err
+	}
+	// 复制 Statement，不改变原有的容器名称
+	statement := *p.kubectl.getInstance().Statement
+	statement.ContainerName = name
+	tx := &Kubectl{ID: p.kubectl.ID, Statement: &statement, Error: p.kubectl.Error}
+	return (&pod{kubectl: tx, Error: p.Error}).Command(command, args...).ExecWithResult(opts...)
+}
+
+// fileRoot 目标容器根目录在执行命令的容器中的路径，未设置 DebugFiles 时为空
+func (p *pod) fileRoot() string {
+	if p.debug == nil {
+		return ""
+	}
+	return debugTargetRoot
+}
+
+// podFilePath 规范化路径，设置了 DebugFiles 时转换为调试容器中访问目标容器文件的路径
+func (p *pod) podFilePath(filePath string) (string, error) {
+	filePath, err := cleanPodPath(filePath)
+	if err != nil || p.debug == nil {
+		return filePath, err
+	}
+	if strings.HasPrefix(filePath, "/") {
+		return debugTargetRoot + strings.TrimSuffix(filePath, "/"), nil
+	}
+	return debugTargetCwd + strings.TrimPrefix(filePath, "."), nil
+}
+
+// displayFilePath 将执行命令的容器中的路径转换为目标容器中的路径
+func (p *pod) displayFilePath(filePath string) string {
+	root := p.fileRoot()
+	if root == "" {
+		return filePath
+	}
+	if filePath == root {
+		return "/"
+	}
+	if strings.HasPrefix(filePath, root+"/") {
+		return strings.TrimPrefix(filePath, root)
+	}
+	return filePath
+}
@@ -0,0 +1,402 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/duke-git/lancet/v2/random"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+// debugSourceAnnotation 调试 Pod 上记录来源的注解，复制的 Pod 为源 Pod 的 namespace/name，节点调试 Pod 为节点名称
+const debugSourceAnnotation = "kom.io/debug-source"
+
+// DebugPodName 设置 DebugCopy、DebugNode 创建的 Pod 名称，默认为 <pod>-debug-xxxxx 或 node-debugger-<node>-xxxxx
+func DebugPodName(name string) DebugOption {
+	return func(o *debugOptions) {
+		o.podName = name
+	}
+}
+
+// DebugNamespace 设置 DebugCopy、DebugNode 创建的 Pod 所在的命名空间，默认与源 Pod 相同
+func DebugNamespace(ns string) DebugOption {
+	return func(o *debugOptions) {
+		o.namespace = ns
+	}
+}
+
+// DebugSetImage 复制 Pod 时替换容器的镜像，container 为 * 时替换所有容器
+func DebugSetImage(container, image string) DebugOption {
+	return func(o *debugOptions) {
+		if o.images == nil {
+			o.images = map[string]string{}
+		}
+		o.images[container] = image
+	}
+}
+
+// DebugShareProcesses 复制 Pod 时开启 shareProcessNamespace，容器之间可以看到彼此的进程
+func DebugShareProcesses() DebugOption {
+	return func(o *debugOptions) {
+		o.shareProcesses = true
+	}
+}
+
+// DebugTolerations 为 DebugCopy、DebugNode 创建的 Pod 添加容忍
+// DebugNode 未设置时容忍所有污点
+func DebugTolerations(tolerations ...v1.Toleration) DebugOption {
+	return func(o *debugOptions) {
+		o.tolerations = append(o.tolerations, tolerations...)
+	}
+}
+
+// DebugImagePullSecrets 为 DebugCopy、DebugNode 创建的 Pod 添加镜像拉取凭证
+func DebugImagePullSecrets(names ...string) DebugOption {
+	return func(o *debugOptions) {
+		for _, name := range names {
+			o.pullSecrets = append(o.pullSecrets, v1.LocalObjectReference{Name: name})
+		}
+	}
+}
+
+// DebugTTL 设置 DebugCopy、DebugNode 创建的 Pod 的存活时间，到期后由 kom 删除，默认不删除
+// 同时设置 Pod 的 activeDeadlineSeconds，kom 进程提前退出时 Pod 也会在到期后停止运行
+func DebugTTL(ttl time.Duration) DebugOption {
+	return func(o *debugOptions) {
+		o.ttl = ttl
+	}
+}
+
+// DebugCopy 复制 Pod 用于调试，等同于 kubectl debug --copy-to，等待复制的 Pod 运行后返回其命名空间、名称及调试使用的容器名称。
+// 复制的 Pod 去掉了所有的 label，不会被 Service 选中，也不会被控制器接管；同时去掉了探针，修改启动命令后不会因探针失败被重启。
+// image 不为空时在复制的 Pod 中添加一个调试容器；image 为空时 DebugCommand 替换目标容器（ContainerName 设置的容器）的启动命令。
+// 可通过 DebugSetImage 替换容器镜像，DebugShareProcesses 开启进程命名空间共享。
+// 执行时会触发 Debug() 类型的 callbacks。
+//
+// Example:
+//
+//	ns, name, container, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").
+//		DebugCopy("", kom.DebugCommand("sleep", "infinity"), kom.DebugTTL(time.Hour))
+func (p *pod) DebugCopy(image string, opts ...DebugOption) (namespace, podName, containerName string, err error) {
+	if p.Error != nil {
+		return "", "", "", p.Error
+	}
+	stmt := p.kubectl.Statement
+	options := &debugOptions{
+		name:    "debugger-" + strings.ToLower(random.RandString(5)),
+		target:  stmt.ContainerName,
+		podName: fmt.Sprintf("%s-debug-%s", stmt.Name, strings.ToLower(random.RandString(5))),
+		stdin:   true,
+		tty:     true,
+		timeout: 2 * time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	if options.namespace == "" {
+		options.namespace = stmt.Namespace
+	}
+	params := map[string]string{"image": image, "namespace": options.namespace, "pod": options.podName}
+	err = p.kubectl.execCtl("debug", "copy-pod", params, func() (e error) {
+		containerName, e = p.debugCopy(image, options)
+		return e
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	return options.namespace, options.podName, containerName, nil
+}
+
+func (p *pod) debugCopy(image string, options *debugOptions) (string, error) {
+	stmt := p.kubectl.Statement
+	if options.namespace != stmt.Namespace {
+		// 复制到其他命名空间时，同时校验目标命名空间
+		statement := *stmt
+		statement.Namespace = options.namespace
+		if err := (&Kubectl{ID: p.kubectl.ID, Statement: &statement}).guardCheck("debug"); err != nil {
+			return "", err
+		}
+	}
+	ctx := stmt.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	source, err := p.kubectl.Client().CoreV1().Pods(stmt.Namespace).Get(ctx, stmt.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	item := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        options.podName,
+			Namespace:   options.namespace,
+			Annotations: map[string]string{debugSourceAnnotation: stmt.Namespace + "/" + stmt.Name},
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	spec := &item.Spec
+	// 交由调度器重新调度，去掉临时容器及 readiness gate
+	spec.NodeName = ""
+	spec.EphemeralContainers = nil
+	spec.ReadinessGates = nil
+	for i := range spec.InitContainers {
+		removeProbes(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		c := &spec.Containers[i]
+		removeProbes(c)
+		if img, ok := options.images[c.Name]; ok {
+			c.Image = img
+		} else if img, ok = options.images["*"]; ok {
+			c.Image = img
+		}
+	}
+	if options.shareProcesses {
+		spec.ShareProcessNamespace = ptr.To(true)
+	}
+
+	container := options.name
+	if image != "" {
+		spec.Containers = append(spec.Containers, v1.Container{
+			Name:                     options.name,
+			Image:                    image,
+			ImagePullPolicy:          options.pullPolicy,
+			Command:                  options.command,
+			Stdin:                    options.stdin,
+			TTY:                      options.tty,
+			SecurityContext:          debugSecurityContext(options, false),
+			TerminationMessagePolicy: v1.TerminationMessageReadFile,
+		})
+	} else {
+		container = options.target
+		if container == "" {
+			if len(spec.Containers) != 1 {
+				return "", fmt.Errorf("请先设置ContainerName")
+			}
+			container = spec.Containers[0].Name
+		}
+		var target *v1.Container
+		for i := range spec.Containers {
+			if spec.Containers[i].Name == container {
+				target = &spec.Containers[i]
+			}
+		}
+		if target == nil {
+			return "", fmt.Errorf("container %s not found in pod %s/%s", container, stmt.Namespace, stmt.Name)
+		}
+		if len(options.command) > 0 {
+			target.Command = options.command
+			target.Args = nil
+			target.Stdin = options.stdin
+			target.TTY = options.tty
+		}
+	}
+	applyDebugPodOptions(item, options)
+	return container, p.createDebugPod(ctx, item, container, options)
+}
+
+// DebugNode 在节点上创建调试 Pod，等同于 kubectl debug node/<node>，等待其运行后返回命名空间、Pod 名称及容器名称。
+// 调试 Pod 使用节点的网络、PID、IPC 命名空间，以特权模式运行，节点的根目录挂载在 /host，可通过 chroot /host 操作节点。
+// 未设置 DebugTolerations 时容忍所有污点；命名空间默认与 Namespace() 设置的相同，未设置时为 default。
+// 执行时会触发 Debug() 类型的 callbacks。
+//
+// Example:
+//
+//	ns, name, container, err := kom.DefaultCluster().Ctl().Pod().DebugNode("node-1", "busybox:1.36", kom.DebugTTL(30*time.Minute))
+//	result, err := kom.DefaultCluster().Namespace(ns).Name(name).Ctl().Pod().ContainerName(container).
+//		Command("chroot", "/host", "journalctl", "-u", "kubelet", "-n", "100").ExecWithResult()
+func (p *pod) DebugNode(nodeName, image string, opts ...DebugOption) (namespace, podName, containerName string, err error) {
+	if p.Error != nil {
+		return "", "", "", p.Error
+	}
+	if nodeName == "" || image == "" {
+		return "", "", "", fmt.Errorf("node name and debug image are required")
+	}
+	options := &debugOptions{
+		name:    "debugger",
+		podName: fmt.Sprintf("node-debugger-%s-%s", nodeName, strings.ToLower(random.RandString(5))),
+		stdin:   true,
+		tty:     true,
+		timeout: 2 * time.Minute,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	if options.namespace == "" {
+		options.namespace = p.kubectl.Statement.Namespace
+	}
+	if options.namespace == "" {
+		options.namespace = metav1.NamespaceDefault
+	}
+	if len(options.tolerations) == 0 {
+		options.tolerations = []v1.Toleration{{Operator: v1.TolerationOpExists}}
+	}
+
+	// 在调试 Pod 所在的命名空间上执行，访问限制按该命名空间校验
+	statement := *p.kubectl.getInstance().Statement
+	statement.Namespace = options.namespace
+	statement.Name = options.podName
+	tx := &Kubectl{ID: p.kubectl.ID, Statement: &statement}
+	params := map[string]string{"image": image, "node": nodeName, "namespace": options.namespace, "pod": options.podName}
+	err = tx.execCtl("debug", "node", params, func() error {
+		ctx := statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		item := nodeDebugPod(nodeName, image, options)
+		return (&pod{kubectl: tx}).createDebugPod(ctx, item, options.name, options)
+	})
+	if err != nil {
+		return "", "", "", err
+	}
+	return options.namespace, options.podName, options.name, nil
+}
+
+// nodeDebugPod 生成节点调试 Pod，使用节点的网络、PID、IPC 命名空间，以特权模式运行，节点的根目录挂载在 /host
+// DebugNode 与 NodeShell 共用
+func nodeDebugPod(nodeName, image string, options *debugOptions) *v1.Pod {
+	item := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        options.podName,
+			Namespace:   options.namespace,
+			Annotations: map[string]string{debugSourceAnnotation: nodeName},
+		},
+		Spec: v1.PodSpec{
+			NodeName:      nodeName,
+			HostNetwork:   true,
+			HostPID:       true,
+			HostIPC:       true,
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{{
+				Name:                     options.name,
+				Image:                    image,
+				ImagePullPolicy:          options.pullPolicy,
+				Command:                  options.command,
+				Stdin:                    options.stdin,
+				TTY:                      options.tty,
+				SecurityContext:          debugSecurityContext(options, true),
+				TerminationMessagePolicy: v1.TerminationMessageReadFile,
+				VolumeMounts:             []v1.VolumeMount{{Name: "host-root", MountPath: "/host"}},
+			}},
+			Volumes: []v1.Volume{{
+				Name:         "host-root",
+				VolumeSource: v1.VolumeSource{HostPath: &v1.HostPathVolumeSource{Path: "/"}},
+			}},
+		},
+	}
+	applyDebugPodOptions(item, options)
+	return item
+}
+
+// removeProbes 去掉容器的探针
+func removeProbes(c *v1.Container) {
+	c.LivenessProbe = nil
+	c.ReadinessProbe = nil
+	c.StartupProbe = nil
+}
+
+// debugSecurityContext 调试容器的安全配置
+func debugSecurityContext(options *debugOptions, privileged bool) *v1.SecurityContext {
+	if !privileged && len(options.capabilities) == 0 {
+		return nil
+	}
+	sc := &v1.SecurityContext{}
+	if privileged {
+		sc.Privileged = ptr.To(true)
+	}
+	if len(options.capabilities) > 0 {
+		sc.Capabilities = &v1.Capabilities{Add: options.capabilities}
+	}
+	return sc
+}
+
+// applyDebugPodOptions 设置调试 Pod 的容忍、镜像拉取凭证及存活时间
+func applyDebugPodOptions(item *v1.Pod, options *debugOptions) {
+	item.Spec.Tolerations = append(item.Spec.Tolerations, options.tolerations...)
+	item.Spec.ImagePullSecrets = append(item.Spec.ImagePullSecrets, options.pullSecrets...)
+	if options.ttl > 0 {
+		seconds := int64(options.ttl / time.Second)
+		if seconds < 1 {
+			seconds = 1
+		}
+		item.Spec.ActiveDeadlineSeconds = &seconds
+	}
+}
+
+// createDebugPod 创建调试 Pod，等待容器运行，设置了存活时间时到期后删除
+func (p *pod) createDebugPod(ctx context.Context, item *v1.Pod, container string, options *debugOptions) error {
+	pods := p.kubectl.Client().CoreV1().Pods(item.Namespace)
+	if _, err := pods.Create(ctx, item, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("create debug pod %s/%s failed: %w", item.Namespace, item.Name, err)
+	}
+	klog.V(6).Infof("debug pod %s/%s created, waiting for container %s running", item.Namespace, item.Name, container)
+	if options.ttl > 0 {
+		p.kubectl.scheduleDebugPodDeletion(item.Namespace, item.Name, options.ttl)
+	}
+	return p.waitDebugPod(ctx, item.Namespace, item.Name, container, options.timeout)
+}
+
+// waitDebugPod 等待调试 Pod 中的容器运行，Pod 结束或容器无法启动时返回错误
+func (p *pod) waitDebugPod(ctx context.Context, ns, name, container string, timeout time.Duration) error {
+	var reason string
+	err := wait.PollUntilContextTimeout(ctx, 500*time.Millisecond, timeout, true, func(ctx context.Context) (bool, error) {
+		item, err := p.kubectl.Client().CoreV1().Pods(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if item.Status.Phase == v1.PodFailed || item.Status.Phase == v1.PodSucceeded {
+			return false, fmt.Errorf("debug pod %s/%s is %s: %s", ns, name, item.Status.Phase, item.Status.Message)
+		}
+		for _, status := range item.Status.ContainerStatuses {
+			if status.Name != container {
+				continue
+			}
+			var done bool
+			done, reason, err = debugContainerState(status)
+			return done, err
+		}
+		return false, nil
+	})
+	if err != nil && reason != "" && wait.Interrupted(err) {
+		return fmt.Errorf("timeout waiting for container %s in debug pod %s/%s to run, last reason %s: %w", container, ns, name, reason, err)
+	}
+	return err
+}
+
+// scheduleDebugPodDeletion 到期后删除调试 Pod，集群被移除时取消
+func (k *Kubectl) scheduleDebugPodDeletion(ns, name string, ttl time.Duration) {
+	cluster := k.parentCluster()
+	if cluster == nil {
+		return
+	}
+	key := ns + "/" + name
+	timer := time.AfterFunc(ttl, func() {
+		cluster.debugPods.Delete(key)
+		err := cluster.Client.CoreV1().Pods(ns).Delete(context.Background(), name, metav1.DeleteOptions{})
+		if err != nil {
+			klog.V(2).Infof("delete expired debug pod %s failed: %v", key, err)
+			return
+		}
+		klog.V(6).Infof("expired debug pod %s deleted", key)
+	})
+	cluster.debugPods.Store(key, timer)
+}
+
+// stopDebugPodTimers 取消集群上调试 Pod 的定时删除
+func (c *ClusterInst) stopDebugPodTimers() {
+	c.debugPods.Range(func(key, value any) bool {
+		value.(*time.Timer).Stop()
+		c.debugPods.Delete(key)
+		return true
+	})
+}
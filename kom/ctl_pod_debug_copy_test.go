@@ -0,0 +1,208 @@
+package kom
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// fakeDebugPodRunning 模拟调试 Pod 创建后所有容器运行
+func fakeDebugPodRunning(t *testing.T, k *Kubectl) {
+	client, ok := k.Client().(*k8sfake.Clientset)
+	if !ok {
+		t.Fatalf("unexpected client %T", k.Client())
+	}
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		item := action.(k8stesting.CreateAction).GetObject().(*v1.Pod)
+		item.Status.Phase = v1.PodRunning
+		for _, c := range item.Spec.Containers {
+			item.Status.ContainerStatuses = append(item.Status.ContainerStatuses, v1.ContainerStatus{Name: c.Name, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}})
+		}
+		return false, nil, nil
+	})
+}
+
+func debugSourcePod() *v1.Pod {
+	probe := &v1.Probe{ProbeHandler: v1.ProbeHandler{Exec: &v1.ExecAction{Command: []string{"true"}}}}
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web-0",
+			Namespace:       "default",
+			Labels:          map[string]string{"app": "web"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "web"}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: "node-1",
+			Containers: []v1.Container{
+				{Name: "app", Image: "web:1.0", Command: []string{"/web"}, Args: []string{"--port", "80"}, LivenessProbe: probe, ReadinessProbe: probe},
+				{Name: "sidecar", Image: "proxy:1.0", StartupProbe: probe},
+			},
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+		},
+	}
+}
+
+func TestPodDebugCopy(t *testing.T) {
+	k := RegisterFakeCluster("pod-debug-copy-cluster", debugSourcePod())
+	fakeDebugPodRunning(t, k)
+	_, _ = k.Client().CoreV1().Pods("default").Create(context.TODO(), debugSourcePod(), metav1.CreateOptions{})
+
+	ns, name, container, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").
+		DebugCopy("", DebugCommand("sleep", "infinity"), DebugSetImage("sidecar", "proxy:debug"), DebugShareProcesses(),
+			DebugTolerations(v1.Toleration{Key: "dedicated", Operator: v1.TolerationOpExists}), DebugImagePullSecrets("debug-registry"))
+	if err != nil {
+		t.Fatalf("debug copy failed: %v", err)
+	}
+	if ns != "default" || container != "app" {
+		t.Errorf("unexpected result %s %s %s", ns, name, container)
+	}
+	item, err := k.Client().CoreV1().Pods(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get copied pod failed: %v", err)
+	}
+	if len(item.Labels) != 0 || len(item.OwnerReferences) != 0 || item.Spec.NodeName != "" || item.Annotations[debugSourceAnnotation] != "default/web-0" {
+		t.Errorf("unexpected copied pod meta %+v %s", item.ObjectMeta, item.Spec.NodeName)
+	}
+	app, sidecar := item.Spec.Containers[0], item.Spec.Containers[1]
+	if app.LivenessProbe != nil || app.ReadinessProbe != nil || sidecar.StartupProbe != nil {
+		t.Errorf("probes should be removed")
+	}
+	if app.Command[0] != "sleep" || app.Args != nil || !app.TTY || app.Image != "web:1.0" || sidecar.Image != "proxy:debug" {
+		t.Errorf("unexpected containers %+v %+v", app, sidecar)
+	}
+	if item.Spec.ShareProcessNamespace == nil || !*item.Spec.ShareProcessNamespace || item.Spec.ActiveDeadlineSeconds != nil {
+		t.Errorf("unexpected pod spec %+v", item.Spec)
+	}
+	if len(item.Spec.Tolerations) != 1 || len(item.Spec.ImagePullSecrets) != 2 {
+		t.Errorf("unexpected tolerations or pull secrets %v %v", item.Spec.Tolerations, item.Spec.ImagePullSecrets)
+	}
+
+	// 添加调试容器，复制到其他命名空间
+	ns, name, container, err = k.Namespace("default").Name("web-0").Ctl().Pod().
+		DebugCopy("busybox:1.36", DebugNamespace("debug"), DebugPodName("web-0-copy"), DebugContainerName("shell"))
+	if err != nil {
+		t.Fatalf("debug copy with image failed: %v", err)
+	}
+	if ns != "debug" || name != "web-0-copy" || container != "shell" {
+		t.Errorf("unexpected result %s %s %s", ns, name, container)
+	}
+	item, err = k.Client().CoreV1().Pods("debug").Get(context.TODO(), "web-0-copy", metav1.GetOptions{})
+	if err != nil || len(item.Spec.Containers) != 3 || item.Spec.Containers[2].Image != "busybox:1.36" || item.Spec.Containers[0].Command[0] != "/web" {
+		t.Errorf("unexpected copied pod %+v %v", item, err)
+	}
+
+	if _, _, _, err = k.Namespace("default").Name("web-0").Ctl().Pod().DebugCopy(""); err == nil {
+		t.Errorf("expected error without container name for multi-container pod")
+	}
+}
+
+func TestPodDebugCopyTTL(t *testing.T) {
+	k := RegisterFakeCluster("pod-debug-copy-ttl-cluster")
+	fakeDebugPodRunning(t, k)
+	_, _ = k.Client().CoreV1().Pods("default").Create(context.TODO(), debugSourcePod(), metav1.CreateOptions{})
+
+	ns, name, _, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").DebugCopy("", DebugTTL(200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("debug copy failed: %v", err)
+	}
+	item, err := k.Client().CoreV1().Pods(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil || item.Spec.ActiveDeadlineSeconds == nil || *item.Spec.ActiveDeadlineSeconds != 1 {
+		t.Fatalf("unexpected copied pod %v %v", item, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, err = k.Client().CoreV1().Pods(ns).Get(context.TODO(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("copied pod should be deleted after ttl, got %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err = k.Client().CoreV1().Pods("default").Get(context.TODO(), "web-0", metav1.GetOptions{}); err != nil {
+		t.Errorf("source pod should be kept: %v", err)
+	}
+
+	// 集群移除时取消定时删除
+	_, name, _, err = k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").DebugCopy("", DebugTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("debug copy failed: %v", err)
+	}
+	cluster := Clusters().GetClusterById(k.ID)
+	if _, ok := cluster.debugPods.Load("default/" + name); !ok {
+		t.Fatalf("deletion of %s should be scheduled", name)
+	}
+	cluster.stopDebugPodTimers()
+	if _, ok := cluster.debugPods.Load("default/" + name); ok {
+		t.Errorf("scheduled deletion should be cancelled")
+	}
+}
+
+func TestPodDebugNode(t *testing.T) {
+	k := RegisterFakeCluster("pod-debug-node-cluster")
+	fakeDebugPodRunning(t, k)
+	var action *CtlAction
+	_ = k.Callback().Debug().Before("kom:debug").Register("test:debug", func(k *Kubectl) error {
+		action = k.Statement.CtlAction
+		return nil
+	})
+
+	ns, name, container, err := k.Ctl().Pod().DebugNode("node-1", "busybox:1.36", DebugCommand("sh"), DebugImagePullSecrets("registry"))
+	if err != nil {
+		t.Fatalf("debug node failed: %v", err)
+	}
+	if ns != "default" || container != "debugger" || action == nil || action.Action != "node" || action.Params["node"] != "node-1" {
+		t.Errorf("unexpected result %s %s %s %+v", ns, name, container, action)
+	}
+	item, err := k.Client().CoreV1().Pods(ns).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get node debug pod failed: %v", err)
+	}
+	spec := item.Spec
+	if spec.NodeName != "node-1" || !spec.HostPID || !spec.HostNetwork || !spec.HostIPC || spec.RestartPolicy != v1.RestartPolicyNever {
+		t.Errorf("unexpected node debug pod spec %+v", spec)
+	}
+	c := spec.Containers[0]
+	if c.SecurityContext == nil || !*c.SecurityContext.Privileged || c.VolumeMounts[0].MountPath != "/host" || spec.Volumes[0].HostPath.Path != "/" {
+		t.Errorf("unexpected node debug container %+v", c)
+	}
+	if len(spec.Tolerations) != 1 || spec.Tolerations[0].Operator != v1.TolerationOpExists || spec.ImagePullSecrets[0].Name != "registry" {
+		t.Errorf("unexpected tolerations or pull secrets %v %v", spec.Tolerations, spec.ImagePullSecrets)
+	}
+
+	_, _, _, err = k.Ctl().Pod().DebugNode("node-1", "busybox", DebugNamespace("kube-system"),
+		DebugTolerations(v1.Toleration{Key: "gpu", Operator: v1.TolerationOpExists}))
+	if err != nil {
+		t.Fatalf("debug node failed: %v", err)
+	}
+	if _, _, _, err = k.Ctl().Pod().DebugNode("", "busybox"); err == nil {
+		t.Errorf("expected error without node name")
+	}
+}
+
+func TestPodDebugCopyGuard(t *testing.T) {
+	k := registerFakeGuardCluster(t, "pod-debug-copy-guard-cluster", &RegisterParams{AllowedNamespaces: []string{"default"}})
+	fakeDebugPodRunning(t, k)
+	_, _ = k.Client().CoreV1().Pods("default").Create(context.TODO(), debugSourcePod(), metav1.CreateOptions{})
+
+	if _, _, _, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").DebugCopy(""); err != nil {
+		t.Errorf("copy in allowed namespace failed: %v", err)
+	}
+	_, _, _, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").DebugCopy("", DebugNamespace("kube-system"))
+	if !errors.Is(err, ErrNamespaceNotAllowed) {
+		t.Errorf("copy to other namespace should be denied, got %v", err)
+	}
+	_, _, _, err = k.Namespace("default").Ctl().Pod().DebugNode("guard-node", "busybox", DebugNamespace("kube-system"))
+	if !errors.Is(err, ErrNamespaceNotAllowed) {
+		t.Errorf("node debug in other namespace should be denied, got %v", err)
+	}
+}
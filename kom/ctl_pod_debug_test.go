@@ -0,0 +1,181 @@
+package kom
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// fakeEphemeralContainers 模拟 kubelet 启动临时容器，state 返回容器的状态，返回添加临时容器的次数
+func fakeEphemeralContainers(t *testing.T, k *Kubectl, state func(c v1.EphemeralContainer) v1.ContainerState) *int32 {
+	client, ok := k.Client().(*k8sfake.Clientset)
+	if !ok {
+		t.Fatalf("unexpected client %T", k.Client())
+	}
+	var updates int32
+	client.PrependReactor("update", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "ephemeralcontainers" {
+			return false, nil, nil
+		}
+		atomic.AddInt32(&updates, 1)
+		item := action.(k8stesting.UpdateAction).GetObject().(*v1.Pod)
+		item.Status.EphemeralContainerStatuses = nil
+		for _, c := range item.Spec.EphemeralContainers {
+			item.Status.EphemeralContainerStatuses = append(item.Status.EphemeralContainerStatuses, v1.ContainerStatus{Name: c.Name, Image: c.Image, State: state(c)})
+		}
+		// 交给默认的 reactor 保存
+		return false, nil, nil
+	})
+	return &updates
+}
+
+func debugTargetPod() *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "distroless-0", Namespace: "default"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Image: "gcr.io/distroless/static"}}},
+	}
+}
+
+func runningState(v1.EphemeralContainer) v1.ContainerState {
+	return v1.ContainerState{Running: &v1.ContainerStateRunning{}}
+}
+
+func TestPodDebug(t *testing.T) {
+	k := RegisterFakeCluster("pod-debug-cluster", debugTargetPod())
+	fakeEphemeralContainers(t, k, runningState)
+	var action *CtlAction
+	_ = k.Callback().Debug().Before("kom:debug").Register("test:debug", func(k *Kubectl) error {
+		action = k.Statement.CtlAction
+		return nil
+	})
+
+	name, err := k.Namespace("default").Name("distroless-0").Ctl().Pod().ContainerName("app").
+		Debug("busybox:1.36", DebugCommand("sh"), DebugCapabilities("SYS_PTRACE"))
+	if err != nil {
+		t.Fatalf("debug failed: %v", err)
+	}
+	if !strings.HasPrefix(name, "debugger-") {
+		t.Errorf("unexpected container name %s", name)
+	}
+	if action == nil || action.Processor != "debug" || action.Params["image"] != "busybox:1.36" || action.Params["target"] != "app" {
+		t.Errorf("unexpected ctl action %+v", action)
+	}
+
+	item, err := k.Client().CoreV1().Pods("default").Get(context.TODO(), "distroless-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get pod failed: %v", err)
+	}
+	if len(item.Spec.EphemeralContainers) != 1 {
+		t.Fatalf("expected 1 ephemeral container, got %d", len(item.Spec.EphemeralContainers))
+	}
+	c := item.Spec.EphemeralContainers[0]
+	if c.Name != name || c.TargetContainerName != "app" || !c.Stdin || !c.TTY || c.Command[0] != "sh" ||
+		c.SecurityContext.Capabilities.Add[0] != "SYS_PTRACE" {
+		t.Errorf("unexpected ephemeral container %+v", c)
+	}
+
+	if _, err = k.Namespace("default").Name("distroless-0").Ctl().Pod().Debug(""); err == nil {
+		t.Errorf("expected error without image")
+	}
+}
+
+func TestPodDebugFailed(t *testing.T) {
+	k := RegisterFakeCluster("pod-debug-failed-cluster", debugTargetPod())
+	fakeEphemeralContainers(t, k, func(c v1.EphemeralContainer) v1.ContainerState {
+		if c.Image == "slow:latest" {
+			return v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}
+		}
+		return v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "InvalidImageName", Message: "invalid reference format"}}
+	})
+	p := k.Namespace("default").Name("distroless-0").Ctl().Pod().ContainerName("app")
+
+	if _, err := p.Debug("Bad Image"); err == nil || !strings.Contains(err.Error(), "InvalidImageName") {
+		t.Errorf("expected invalid image error, got %v", err)
+	}
+	_, err := p.Debug("slow:latest", DebugTimeout(100*time.Millisecond))
+	if err == nil || !strings.Contains(err.Error(), "ImagePullBackOff") {
+		t.Errorf("expected timeout with last reason, got %v", err)
+	}
+	if _, err = k.Namespace("default").Name("missing").Ctl().Pod().Debug("busybox"); err == nil {
+		t.Errorf("expected error for missing pod")
+	}
+}
+
+func TestPodDebugReadOnly(t *testing.T) {
+	k := registerFakeGuardCluster(t, "pod-debug-readonly-cluster", &RegisterParams{ReadOnly: true})
+	_, err := k.Namespace("default").Name("guard-pod").Ctl().Pod().Debug("busybox")
+	if !errors.Is(err, ErrClusterReadOnly) {
+		t.Errorf("debug should be denied, got %v", err)
+	}
+}
+
+func TestPodDebugFiles(t *testing.T) {
+	k := RegisterFakeCluster("pod-debug-files-cluster", debugTargetPod())
+	updates := fakeEphemeralContainers(t, k, runningState)
+	var containers []string
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		containers = append(containers, k.Statement.ContainerName)
+		args := strings.Join(k.Statement.Args, " ")
+		switch k.Statement.Command {
+		case "stat":
+			if !strings.HasSuffix(args, "-- /proc/1/root/etc/app.conf") {
+				t.Errorf("unexpected stat args %s", args)
+			}
+			_, _ = opts.Stdout.Write([]byte("81a4 4 1700000000 0 0 root root /proc/1/root/etc/app.conf\n"))
+		case "tar":
+			if args != "-c -h -f - -- /proc/1/root/etc/app.conf" {
+				t.Errorf("unexpected tar args %s", args)
+			}
+			tw := tar.NewWriter(opts.Stdout)
+			_ = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "proc/1/root/etc/app.conf", Size: 4, Mode: 0644})
+			_, _ = tw.Write([]byte("a=1\n"))
+			_ = tw.Close()
+		default:
+			t.Errorf("unexpected command %s", k.Statement.Command)
+		}
+		return nil
+	})
+
+	p := k.Namespace("default").Name("distroless-0").Ctl().Pod().ContainerName("app").DebugFiles("")
+	info, err := p.StatFile("/etc/app.conf")
+	if err != nil {
+		t.Fatalf("stat file failed: %v", err)
+	}
+	if info.Path != "/etc/app.conf" || info.Size != 4 || info.Permissions != "-rw-r--r--" {
+		t.Errorf("unexpected file info %+v", info)
+	}
+	var buf bytes.Buffer
+	if err = p.DownloadFileTo("/etc/app.conf", &buf); err != nil || buf.String() != "a=1\n" {
+		t.Errorf("unexpected download result %q %v", buf.String(), err)
+	}
+
+	// 新的 pod 对象复用已运行的调试容器
+	if _, err = k.Namespace("default").Name("distroless-0").Ctl().Pod().DebugFiles("").StatFile("/etc/app.conf"); err != nil {
+		t.Fatalf("stat file failed: %v", err)
+	}
+	if *updates != 1 {
+		t.Errorf("debug container should be created once, got %d", *updates)
+	}
+	for _, c := range containers {
+		if !strings.HasPrefix(c, debugFilesPrefix) || c != containers[0] {
+			t.Errorf("file commands should run in the same debug container, got %v", containers)
+			break
+		}
+	}
+	item, _ := k.Client().CoreV1().Pods("default").Get(context.TODO(), "distroless-0", metav1.GetOptions{})
+	if c := item.Spec.EphemeralContainers[0]; c.Image != defaultDebugFilesImage || c.TargetContainerName != "app" || c.TTY || !c.Stdin {
+		t.Errorf("unexpected debug files container %+v", c)
+	}
+}
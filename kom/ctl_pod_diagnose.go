@@ -0,0 +1,701 @@
+package kom
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DiagnoseSeverity 诊断结果的严重程度
+type DiagnoseSeverity string
+
+const (
+	// DiagnoseCritical Pod 无法运行或持续失败
+	DiagnoseCritical DiagnoseSeverity = "critical"
+	// DiagnoseWarning Pod 可以运行，但存在影响可用性的问题
+	DiagnoseWarning DiagnoseSeverity = "warning"
+	// DiagnoseInfo 提示信息
+	DiagnoseInfo DiagnoseSeverity = "info"
+)
+
+// 诊断结果的类型
+const (
+	FindingCrashLoopBackOff   = "CrashLoopBackOff"
+	FindingOOMKilled          = "OOMKilled"
+	FindingImagePull          = "ImagePullError"
+	FindingUnschedulable      = "Unschedulable"
+	FindingProbeFailed        = "ProbeFailed"
+	FindingVolumeMount        = "VolumeMountFailed"
+	FindingContainerConfig    = "ContainerConfigError"
+	FindingContainerNotReady  = "ContainerNotReady"
+	FindingEvicted            = "Evicted"
+	FindingNodeProblem        = "NodeProblem"
+	FindingTerminatedWithFail = "ContainerFailed"
+)
+
+// DiagnoseFinding 一条诊断结果
+type DiagnoseFinding struct {
+	Type       string            `json:"type"`                // 类型，如 CrashLoopBackOff、OOMKilled
+	Severity   DiagnoseSeverity  `json:"severity"`            // 严重程度
+	Container  string            `json:"container,omitempty"` // 相关的容器，与容器无关时为空
+	Message    string            `json:"message"`             // 问题描述
+	Details    map[string]string `json:"details,omitempty"`   // 退出码、内存限制、镜像仓库错误等明细
+	Suggestion string            `json:"suggestion"`          // 建议的处理方法
+}
+
+// DiagnoseReport Pod 诊断报告
+type DiagnoseReport struct {
+	Namespace string             `json:"namespace"`
+	Name      string             `json:"name"`
+	Phase     v1.PodPhase        `json:"phase"`
+	NodeName  string             `json:"nodeName,omitempty"`
+	Healthy   bool               `json:"healthy"`  // 没有 critical 及 warning 级别的诊断结果
+	Findings  []*DiagnoseFinding `json:"findings"` // 按严重程度排序
+}
+
+// Diagnose 根据 Pod 状态、容器状态、事件及所在节点的状态诊断 Pod 的异常，返回诊断结果及处理建议。
+// 覆盖 CrashLoopBackOff、OOMKilled、镜像拉取失败、无法调度（资源不足、污点、亲和性等）、探针失败、存储卷挂载失败及节点异常。
+//
+// Example:
+//
+//	report, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().Diagnose()
+//	for _, f := range report.Findings {
+//		fmt.Println(f.Severity, f.Type, f.Container, f.Message, f.Suggestion)
+//	}
+func (p *pod) Diagnose() (*DiagnoseReport, error) {
+	if p.Error != nil {
+		return nil, p.Error
+	}
+	stmt := p.kubectl.Statement
+	var item *v1.Pod
+	err := p.kubectl.newInstance().WithContext(stmt.Context).
+		Resource(&v1.Pod{}).Namespace(stmt.Namespace).Name(stmt.Name).
+		Get(&item).Error
+	if err != nil {
+		return nil, fmt.Errorf("get pod %s/%s error %v", stmt.Namespace, stmt.Name, err)
+	}
+	if item == nil {
+		return nil, fmt.Errorf("get pod %s/%s error pod is nil", stmt.Namespace, stmt.Name)
+	}
+
+	var events []*v1.Event
+	err = p.kubectl.newInstance().WithContext(stmt.Context).
+		Resource(&v1.Event{}).Namespace(item.Namespace).
+		WithFieldSelector(fmt.Sprintf("involvedObject.name=%s,involvedObject.kind=Pod", item.Name)).
+		RemoveManagedFields().
+		List(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("list events of pod %s/%s error %v", item.Namespace, item.Name, err)
+	}
+	events = podEvents(item, events)
+
+	var node *v1.Node
+	var nodeErr error
+	if item.Spec.NodeName != "" {
+		// 节点不存在时同样作为诊断结果
+		nodeErr = p.kubectl.newInstance().WithContext(stmt.Context).
+			Resource(&v1.Node{}).Name(item.Spec.NodeName).
+			Get(&node).Error
+	}
+	return diagnosePod(item, events, node, nodeErr), nil
+}
+
+// podEvents 过滤出与 Pod 相关的事件，按最后发生时间排序
+func podEvents(item *v1.Pod, events []*v1.Event) []*v1.Event {
+	var result []*v1.Event
+	for _, e := range events {
+		obj := e.InvolvedObject
+		if obj.Kind != "Pod" || obj.Name != item.Name {
+			continue
+		}
+		if obj.UID != "" && item.UID != "" && obj.UID != item.UID {
+			// 同名 Pod 重建前的事件
+			continue
+		}
+		result = append(result, e)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return eventTime(result[i]).Before(eventTime(result[j]))
+	})
+	return result
+}
+
+func eventTime(e *v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
+
+// eventContainer 从事件的 fieldPath 中解析容器名称，如 spec.containers{app}
+func eventContainer(e *v1.Event) string {
+	path := e.InvolvedObject.FieldPath
+	start, end := strings.Index(path, "{"), strings.LastIndex(path, "}")
+	if start < 0 || end <= start {
+		return ""
+	}
+	return path[start+1 : end]
+}
+
+// diagnosePod 根据 Pod、事件及节点计算诊断结果，nodeErr 为获取节点时的错误
+func diagnosePod(item *v1.Pod, events []*v1.Event, node *v1.Node, nodeErr error) *DiagnoseReport {
+	d := &podDiagnosis{pod: item, events: events}
+	d.checkEvicted()
+	d.checkScheduling()
+	statuses := append(append([]v1.ContainerStatus{}, item.Status.InitContainerStatuses...), item.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		d.checkContainer(status)
+	}
+	d.checkProbes(statuses)
+	d.checkVolumes()
+	d.checkNode(node, nodeErr)
+
+	order := map[DiagnoseSeverity]int{DiagnoseCritical: 0, DiagnoseWarning: 1, DiagnoseInfo: 2}
+	sort.SliceStable(d.findings, func(i, j int) bool {
+		return order[d.findings[i].Severity] < order[d.findings[j].Severity]
+	})
+	report := &DiagnoseReport{
+		Namespace: item.Namespace,
+		Name:      item.Name,
+		Phase:     item.Status.Phase,
+		NodeName:  item.Spec.NodeName,
+		Healthy:   true,
+		Findings:  d.findings,
+	}
+	for _, f := range d.findings {
+		if f.Severity != DiagnoseInfo {
+			report.Healthy = false
+		}
+	}
+	if report.Findings == nil {
+		report.Findings = []*DiagnoseFinding{}
+	}
+	return report
+}
+
+type podDiagnosis struct {
+	pod      *v1.Pod
+	events   []*v1.Event
+	findings []*DiagnoseFinding
+}
+
+func (d *podDiagnosis) add(f *DiagnoseFinding) {
+	d.findings = append(d.findings, f)
+}
+
+// lastEvent 最近一次满足 match 的事件，container 不为空时跳过其他容器的事件
+func (d *podDiagnosis) lastEvent(container string, match func(e *v1.Event) bool) *v1.Event {
+	for i := len(d.events) - 1; i >= 0; i-- {
+		e := d.events[i]
+		if container != "" && eventContainer(e) != "" && eventContainer(e) != container {
+			continue
+		}
+		if match(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (d *podDiagnosis) containerSpec(name string) *v1.Container {
+	for _, list := range [][]v1.Container{d.pod.Spec.InitContainers, d.pod.Spec.Containers} {
+		for i := range list {
+			if list[i].Name == name {
+				return &list[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (d *podDiagnosis) checkEvicted() {
+	if d.pod.Status.Phase != v1.PodFailed || d.pod.Status.Reason != "Evicted" {
+		return
+	}
+	d.add(&DiagnoseFinding{
+		Type:       FindingEvicted,
+		Severity:   DiagnoseCritical,
+		Message:    fmt.Sprintf("Pod 已被驱逐：%s", d.pod.Status.Message),
+		Suggestion: "节点资源（内存、磁盘等）不足导致驱逐，检查节点资源压力，为容器设置合理的 requests，驱逐的 Pod 可以直接删除",
+	})
+}
+
+var (
+	// schedulerCauseStart 调度器消息中每类原因的开始位置
+	schedulerCauseStart = regexp.MustCompile(`(?:^|,)\s*\d+\s`)
+	// unschedulableCause 调度器消息中的一类原因，如 "2 Insufficient cpu"
+	unschedulableCause = regexp.MustCompile(`^(\d+)\s+(.+)$`)
+)
+
+func (d *podDiagnosis) checkScheduling() {
+	var message string
+	for _, c := range d.pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason == v1.PodReasonUnschedulable {
+			message = c.Message
+		}
+	}
+	if message == "" && d.pod.Spec.NodeName == "" {
+		if e := d.lastEvent("", func(e *v1.Event) bool { return e.Reason == "FailedScheduling" }); e != nil {
+			message = e.Message
+		}
+	}
+	if message == "" {
+		return
+	}
+	causes := parseSchedulerMessage(message)
+	if len(causes) == 0 {
+		d.add(&DiagnoseFinding{
+			Type:       FindingUnschedulable,
+			Severity:   DiagnoseCritical,
+			Message:    "Pod 无法调度：" + message,
+			Suggestion: "根据调度器消息检查节点资源、污点、亲和性及存储卷配置",
+		})
+		return
+	}
+	for _, c := range causes {
+		f := &DiagnoseFinding{
+			Type:     FindingUnschedulable,
+			Severity: DiagnoseCritical,
+			Message:  fmt.Sprintf("Pod 无法调度：%s 个节点 %s", c.nodes, c.reason),
+			Details:  map[string]string{"nodes": c.nodes, "reason": c.reason, "schedulerMessage": message},
+		}
+		f.Details["category"], f.Suggestion = d.schedulingSuggestion(c.reason)
+		d.add(f)
+	}
+}
+
+type schedulerCause struct {
+	nodes  string
+	reason string
+}
+
+// parseSchedulerMessage 解析调度器消息，如
+// 0/3 nodes are available: 1 Insufficient cpu, 2 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }. preemption: ...
+func parseSchedulerMessage(message string) []schedulerCause {
+	_, rest, ok := strings.Cut(message, "nodes are available:")
+	if !ok {
+		return nil
+	}
+	// 去掉抢占相关的说明
+	if i := strings.Index(rest, "preemption:"); i >= 0 {
+		rest = rest[:i]
+	}
+	var causes []schedulerCause
+	// 污点等内容中可能含有逗号，按 ", <节点数> " 切分
+	idx := schedulerCauseStart.FindAllStringIndex(rest, -1)
+	for i, loc := range idx {
+		end := len(rest)
+		if i+1 < len(idx) {
+			end = idx[i+1][0]
+		}
+		segment := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest[loc[0]:end]), ","))
+		segment = strings.TrimSpace(strings.TrimSuffix(segment, "."))
+		m := unschedulableCause.FindStringSubmatch(segment)
+		if m == nil {
+			continue
+		}
+		causes = append(causes, schedulerCause{nodes: m[1], reason: m[2]})
+	}
+	return causes
+}
+
+// schedulingSuggestion 根据无法调度的原因给出分类及建议
+func (d *podDiagnosis) schedulingSuggestion(reason string) (string, string) {
+	lower := strings.ToLower(reason)
+	switch {
+	case strings.HasPrefix(lower, "insufficient "):
+		resource := strings.TrimSpace(reason[len("insufficient "):])
+		requests := d.totalRequests(v1.ResourceName(resource))
+		suggestion := fmt.Sprintf("节点可分配的 %s 不足，降低容器的 %s requests", resource, resource)
+		if requests != "" {
+			suggestion += fmt.Sprintf("（当前共 %s）", requests)
+		}
+		return "insufficient-" + resource, suggestion + "，或扩容节点、清理节点上不再使用的 Pod"
+	case strings.Contains(lower, "taint"):
+		return "taint", "节点存在 Pod 未容忍的污点，为 Pod 添加对应的 tolerations，或移除节点上的污点"
+	case strings.Contains(lower, "affinity") || strings.Contains(lower, "selector"):
+		if strings.Contains(lower, "volume node affinity") {
+			return "volume-affinity", "存储卷只能在特定的节点（可用区）上使用，检查 PV 的 nodeAffinity 与可调度节点是否一致"
+		}
+		if strings.Contains(lower, "anti-affinity") {
+			return "anti-affinity", "Pod 反亲和性规则无法满足，增加节点或放宽 podAntiAffinity（如改为 preferred）"
+		}
+		return "affinity", "没有节点满足 nodeSelector 或亲和性规则，检查节点标签与 Pod 的 nodeSelector、affinity 配置"
+	case strings.Contains(lower, "unschedulable"):
+		return "cordoned", "节点被设置为不可调度（cordon），执行 uncordon 恢复调度或扩容节点"
+	case strings.Contains(lower, "persistentvolumeclaim"):
+		return "pvc", "PVC 未绑定，检查 PVC 状态、StorageClass 及存储供应器是否正常"
+	case strings.Contains(lower, "free ports"):
+		return "host-port", "节点上的 hostPort 已被占用，修改 hostPort 或去掉 hostPort 配置"
+	case strings.Contains(lower, "too many pods"):
+		return "pod-count", "节点 Pod 数量已达上限，扩容节点或调整 kubelet 的 maxPods"
+	}
+	return "other", "根据调度器消息检查节点状态及 Pod 的调度配置"
+}
+
+// totalRequests 所有容器对某种资源的 requests 之和，均未设置时为空
+func (d *podDiagnosis) totalRequests(name v1.ResourceName) string {
+	var total resource.Quantity
+	found := false
+	for _, c := range d.pod.Spec.Containers {
+		if q, ok := c.Resources.Requests[name]; ok {
+			total.Add(q)
+			found = true
+		}
+	}
+	if !found {
+		return ""
+	}
+	return total.String()
+}
+
+func (d *podDiagnosis) checkContainer(status v1.ContainerStatus) {
+	spec := d.containerSpec(status.Name)
+	last := status.LastTerminationState.Terminated
+	current := status.State.Terminated
+
+	// OOMKilled
+	oom := last
+	if current != nil && current.Reason == "OOMKilled" {
+		oom = current
+	}
+	if oom != nil && oom.Reason == "OOMKilled" {
+		f := &DiagnoseFinding{
+			Type:      FindingOOMKilled,
+			Severity:  DiagnoseCritical,
+			Container: status.Name,
+			Details:   map[string]string{"exitCode": strconv.Itoa(int(oom.ExitCode)), "finishedAt": oom.FinishedAt.Format(time.RFC3339)},
+		}
+		limit := ""
+		if spec != nil {
+			if q, ok := spec.Resources.Limits[v1.ResourceMemory]; ok {
+				limit = q.String()
+			}
+		}
+		if limit != "" {
+			f.Details["memoryLimit"] = limit
+			f.Message = fmt.Sprintf("容器 %s 内存超过限制 %s 被 OOMKilled", status.Name, limit)
+			f.Suggestion = fmt.Sprintf("提高容器的内存 limits（当前 %s），或排查应用内存泄漏；JVM 等运行时需按容器限制设置堆大小", limit)
+		} else {
+			f.Message = fmt.Sprintf("容器 %s 未设置内存限制，因节点内存不足被 OOMKilled", status.Name)
+			f.Suggestion = "为容器设置合理的内存 requests 和 limits，并检查节点内存压力及应用内存使用"
+		}
+		d.add(f)
+	}
+
+	if status.State.Waiting == nil {
+		if current != nil && current.ExitCode != 0 && current.Reason != "OOMKilled" && d.pod.Spec.RestartPolicy == v1.RestartPolicyNever {
+			d.add(&DiagnoseFinding{
+				Type:       FindingTerminatedWithFail,
+				Severity:   DiagnoseCritical,
+				Container:  status.Name,
+				Message:    fmt.Sprintf("容器 %s 退出，退出码 %d，原因 %s", status.Name, current.ExitCode, current.Reason),
+				Details:    map[string]string{"exitCode": strconv.Itoa(int(current.ExitCode)), "reason": current.Reason},
+				Suggestion: exitCodeSuggestion(current.ExitCode),
+			})
+		}
+		return
+	}
+
+	waiting := status.State.Waiting
+	switch waiting.Reason {
+	case "CrashLoopBackOff":
+		f := &DiagnoseFinding{
+			Type:      FindingCrashLoopBackOff,
+			Severity:  DiagnoseCritical,
+			Container: status.Name,
+			Details:   map[string]string{"restartCount": strconv.Itoa(int(status.RestartCount))},
+		}
+		if last != nil {
+			f.Details["exitCode"] = strconv.Itoa(int(last.ExitCode))
+			f.Details["reason"] = last.Reason
+			f.Message = fmt.Sprintf("容器 %s 反复崩溃，已重启 %d 次，上次退出码 %d，原因 %s", status.Name, status.RestartCount, last.ExitCode, last.Reason)
+			if last.Message != "" {
+				f.Details["terminationMessage"] = last.Message
+			}
+			f.Suggestion = exitCodeSuggestion(last.ExitCode)
+		} else {
+			f.Message = fmt.Sprintf("容器 %s 反复崩溃，已重启 %d 次", status.Name, status.RestartCount)
+			f.Suggestion = "查看容器上一次运行的日志（previous）定位启动失败的原因"
+		}
+		d.add(f)
+	case "ImagePullBackOff", "ErrImagePull", "InvalidImageName", "ErrImageNeverPull":
+		image := status.Image
+		if spec != nil {
+			image = spec.Image
+		}
+		message := waiting.Message
+		if e := d.lastEvent(status.Name, func(e *v1.Event) bool {
+			return e.Reason == "Failed" && strings.Contains(e.Message, "pull")
+		}); e != nil {
+			message = e.Message
+		}
+		category, suggestion := imagePullSuggestion(waiting.Reason, message)
+		d.add(&DiagnoseFinding{
+			Type:       FindingImagePull,
+			Severity:   DiagnoseCritical,
+			Container:  status.Name,
+			Message:    fmt.Sprintf("容器 %s 镜像 %s 拉取失败（%s）", status.Name, image, waiting.Reason),
+			Details:    map[string]string{"image": image, "registry": imageRegistry(image), "error": message, "category": category},
+			Suggestion: suggestion,
+		})
+	case "CreateContainerConfigError", "CreateContainerError", "RunContainerError":
+		d.add(&DiagnoseFinding{
+			Type:       FindingContainerConfig,
+			Severity:   DiagnoseCritical,
+			Container:  status.Name,
+			Message:    fmt.Sprintf("容器 %s 无法创建（%s）：%s", status.Name, waiting.Reason, waiting.Message),
+			Details:    map[string]string{"reason": waiting.Reason, "error": waiting.Message},
+			Suggestion: "检查容器引用的 ConfigMap、Secret 及其中的 key 是否存在，启动命令、挂载路径及 securityContext 配置是否正确",
+		})
+	}
+}
+
+// exitCodeSuggestion 根据退出码给出建议
+func exitCodeSuggestion(code int32) string {
+	switch code {
+	case 0:
+		return "容器正常退出后被重启，长期运行的服务不应退出，检查启动命令是否为前台进程"
+	case 1, 2:
+		return "应用启动失败，查看容器上一次运行的日志（previous），检查配置文件、环境变量及依赖服务"
+	case 126:
+		return "启动命令无法执行，检查命令文件的执行权限"
+	case 127:
+		return "启动命令不存在，检查镜像中的 command、args 及 PATH"
+	case 134:
+		return "应用异常终止（SIGABRT），查看日志中的崩溃堆栈"
+	case 137:
+		return "容器被 SIGKILL 终止，通常为内存超限或存活探针失败后被 kubelet 杀死，检查内存限制及 livenessProbe"
+	case 139:
+		return "应用发生段错误（SIGSEGV），检查镜像架构与节点是否一致及应用的本地依赖"
+	case 143:
+		return "容器被 SIGTERM 终止，检查存活探针是否失败，或应用是否正确处理了退出信号"
+	}
+	return "查看容器上一次运行的日志（previous）定位退出原因"
+}
+
+// imagePullSuggestion 根据镜像仓库返回的错误给出分类及建议
+func imagePullSuggestion(reason, message string) (string, string) {
+	lower := strings.ToLower(message)
+	switch {
+	case reason == "InvalidImageName":
+		return "invalid-name", "镜像名称格式错误，检查镜像地址及 tag"
+	case reason == "ErrImageNeverPull":
+		return "never-pull", "imagePullPolicy 为 Never 且节点上不存在该镜像，预先加载镜像或修改拉取策略"
+	case strings.Contains(lower, "unauthorized") || strings.Contains(lower, "authentication required") ||
+		strings.Contains(lower, "denied") || strings.Contains(lower, "forbidden"):
+		return "auth", "镜像仓库认证失败，检查 imagePullSecrets 是否配置且凭证有效，ServiceAccount 是否关联了拉取凭证"
+	case strings.Contains(lower, "not found") || strings.Contains(lower, "manifest unknown") || strings.Contains(lower, "does not exist"):
+		return "not-found", "镜像或 tag 不存在，检查镜像地址、tag 是否正确，以及镜像是否已推送到仓库"
+	case strings.Contains(lower, "no such host") || strings.Contains(lower, "i/o timeout") || strings.Contains(lower, "connection refused") ||
+		strings.Contains(lower, "timeout") || strings.Contains(lower, "tls") || strings.Contains(lower, "x509"):
+		return "network", "节点无法访问镜像仓库，检查节点的 DNS、网络、代理及仓库证书配置"
+	case strings.Contains(lower, "toomanyrequests") || strings.Contains(lower, "rate limit"):
+		return "rate-limit", "镜像仓库限流，配置拉取凭证或使用镜像加速、私有仓库"
+	case strings.Contains(lower, "no matching manifest") || strings.Contains(lower, "platform"):
+		return "platform", "镜像不支持节点的 CPU 架构，使用多架构镜像或调度到对应架构的节点"
+	}
+	return "other", "查看节点上 kubelet 及容器运行时的日志，确认镜像能否在节点上手动拉取"
+}
+
+// imageRegistry 解析镜像所在的仓库，未指定时为 docker.io
+func imageRegistry(image string) string {
+	first, _, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
+
+// probeEvent 探针失败事件的消息前缀
+var probeEvent = regexp.MustCompile(`^(Liveness|Readiness|Startup) probe (failed|errored)`)
+
+func (d *podDiagnosis) checkProbes(statuses []v1.ContainerStatus) {
+	reported := map[string]bool{}
+	for i := len(d.events) - 1; i >= 0; i-- {
+		e := d.events[i]
+		if e.Reason != "Unhealthy" {
+			continue
+		}
+		m := probeEvent.FindStringSubmatch(e.Message)
+		if m == nil {
+			continue
+		}
+		container := eventContainer(e)
+		key := container + "/" + m[1]
+		if reported[key] {
+			continue
+		}
+		reported[key] = true
+		f := &DiagnoseFinding{
+			Type:      FindingProbeFailed,
+			Severity:  DiagnoseWarning,
+			Container: container,
+			Message:   fmt.Sprintf("容器 %s %s 探针失败，共 %d 次：%s", container, strings.ToLower(m[1]), max(e.Count, 1), e.Message),
+			Details:   map[string]string{"probe": strings.ToLower(m[1]), "count": strconv.Itoa(int(max(e.Count, 1))), "lastSeen": eventTime(e).Format(time.RFC3339)},
+		}
+		switch m[1] {
+		case "Liveness":
+			f.Suggestion = "存活探针失败会导致容器被重启，检查探针的路径、端口是否正确，适当增大 timeoutSeconds、failureThreshold，启动慢的应用配置 startupProbe"
+		case "Readiness":
+			f.Suggestion = "就绪探针失败时 Pod 不会接收 Service 流量，检查探针的路径、端口及应用依赖的服务是否可用"
+		default:
+			f.Suggestion = "启动探针失败，应用启动时间超过 failureThreshold*periodSeconds，适当增大阈值或排查启动缓慢的原因"
+		}
+		if spec := d.containerSpec(container); spec != nil {
+			if probe := probeOf(spec, m[1]); probe != nil {
+				f.Details["timeoutSeconds"] = strconv.Itoa(int(probe.TimeoutSeconds))
+				f.Details["failureThreshold"] = strconv.Itoa(int(probe.FailureThreshold))
+			}
+		}
+		d.add(f)
+	}
+
+	// 运行中但未就绪，且没有探针失败事件
+	for _, status := range statuses {
+		if status.State.Running == nil || status.Ready || reported[status.Name+"/Readiness"] {
+			continue
+		}
+		spec := d.containerSpec(status.Name)
+		isInit := slices.ContainsFunc(d.pod.Spec.InitContainers, func(c v1.Container) bool { return c.Name == status.Name })
+		if spec == nil || spec.ReadinessProbe == nil || isInit {
+			continue
+		}
+		d.add(&DiagnoseFinding{
+			Type:       FindingContainerNotReady,
+			Severity:   DiagnoseWarning,
+			Container:  status.Name,
+			Message:    fmt.Sprintf("容器 %s 运行中但未就绪", status.Name),
+			Suggestion: "就绪探针尚未通过，检查 initialDelaySeconds 是否过大，或应用是否仍在启动",
+		})
+	}
+}
+
+func probeOf(c *v1.Container, kind string) *v1.Probe {
+	switch kind {
+	case "Liveness":
+		return c.LivenessProbe
+	case "Readiness":
+		return c.ReadinessProbe
+	}
+	return c.StartupProbe
+}
+
+// volumeName 从挂载失败的事件消息中解析存储卷名称，如 MountVolume.SetUp failed for volume "config" : configmap "app" not found
+var volumeName = regexp.MustCompile(`volume "([^"]+)"`)
+
+func (d *podDiagnosis) checkVolumes() {
+	reported := map[string]bool{}
+	for i := len(d.events) - 1; i >= 0; i-- {
+		e := d.events[i]
+		switch e.Reason {
+		case "FailedMount", "FailedAttachVolume", "FailedMapVolume":
+		default:
+			continue
+		}
+		volume := ""
+		if m := volumeName.FindStringSubmatch(e.Message); m != nil {
+			volume = m[1]
+		}
+		if reported[e.Reason+"/"+volume] {
+			continue
+		}
+		reported[e.Reason+"/"+volume] = true
+		f := &DiagnoseFinding{
+			Type:     FindingVolumeMount,
+			Severity: DiagnoseCritical,
+			Message:  fmt.Sprintf("存储卷挂载失败（%s）：%s", e.Reason, e.Message),
+			Details:  map[string]string{"reason": e.Reason, "volume": volume},
+		}
+		lower := strings.ToLower(e.Message)
+		switch {
+		case strings.Contains(lower, "configmap") && strings.Contains(lower, "not found"):
+			f.Suggestion = "引用的 ConfigMap 不存在，创建 ConfigMap 或将 volume 设置为 optional"
+		case strings.Contains(lower, "secret") && strings.Contains(lower, "not found"):
+			f.Suggestion = "引用的 Secret 不存在，创建 Secret 或将 volume 设置为 optional"
+		case e.Reason == "FailedAttachVolume" || strings.Contains(lower, "multi-attach"):
+			f.Suggestion = "存储卷仍挂载在其他节点上（ReadWriteOnce），等待旧 Pod 所在节点释放，或检查 VolumeAttachment 是否残留"
+		case strings.Contains(lower, "timed out"):
+			f.Suggestion = "挂载超时，检查 CSI 驱动、存储后端及节点到存储的网络"
+		default:
+			f.Suggestion = "检查 PVC、PV 状态及 CSI 驱动日志"
+		}
+		if volume != "" {
+			for _, v := range d.pod.Spec.Volumes {
+				if v.Name == volume && v.PersistentVolumeClaim != nil {
+					f.Details["claimName"] = v.PersistentVolumeClaim.ClaimName
+				}
+			}
+		}
+		d.add(f)
+	}
+}
+
+func (d *podDiagnosis) checkNode(node *v1.Node, err error) {
+	nodeName := d.pod.Spec.NodeName
+	if nodeName == "" {
+		return
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		// 无权限或请求失败时无法判断节点状态，跳过节点检查
+		d.add(&DiagnoseFinding{
+			Type:       FindingNodeProblem,
+			Severity:   DiagnoseInfo,
+			Message:    fmt.Sprintf("无法获取节点 %s 的状态：%v", nodeName, err),
+			Details:    map[string]string{"node": nodeName},
+			Suggestion: "确认当前账号有读取节点的权限，或稍后重新诊断",
+		})
+		return
+	}
+	if node == nil {
+		d.add(&DiagnoseFinding{
+			Type:       FindingNodeProblem,
+			Severity:   DiagnoseCritical,
+			Message:    fmt.Sprintf("Pod 所在的节点 %s 不存在", nodeName),
+			Details:    map[string]string{"node": nodeName},
+			Suggestion: "节点已被删除，删除 Pod 使控制器在其他节点上重建",
+		})
+		return
+	}
+	for _, c := range node.Status.Conditions {
+		switch {
+		case c.Type == v1.NodeReady && c.Status != v1.ConditionTrue:
+			d.add(&DiagnoseFinding{
+				Type:       FindingNodeProblem,
+				Severity:   DiagnoseCritical,
+				Message:    fmt.Sprintf("节点 %s 未就绪：%s", nodeName, c.Message),
+				Details:    map[string]string{"node": nodeName, "condition": string(c.Type), "reason": c.Reason},
+				Suggestion: "检查节点上 kubelet、容器运行时及网络是否正常，节点无法恢复时驱逐 Pod",
+			})
+		case c.Type != v1.NodeReady && c.Status == v1.ConditionTrue &&
+			(c.Type == v1.NodeMemoryPressure || c.Type == v1.NodeDiskPressure || c.Type == v1.NodePIDPressure || c.Type == v1.NodeNetworkUnavailable):
+			d.add(&DiagnoseFinding{
+				Type:       FindingNodeProblem,
+				Severity:   DiagnoseWarning,
+				Message:    fmt.Sprintf("节点 %s 存在 %s：%s", nodeName, c.Type, c.Message),
+				Details:    map[string]string{"node": nodeName, "condition": string(c.Type), "reason": c.Reason},
+				Suggestion: "节点资源紧张可能导致 Pod 被驱逐，清理节点上的磁盘或迁移部分负载",
+			})
+		}
+	}
+	if node.Spec.Unschedulable {
+		d.add(&DiagnoseFinding{
+			Type:       FindingNodeProblem,
+			Severity:   DiagnoseInfo,
+			Message:    fmt.Sprintf("节点 %s 已被设置为不可调度（cordon）", nodeName),
+			Details:    map[string]string{"node": nodeName},
+			Suggestion: "节点可能正在维护，Pod 重建后会调度到其他节点",
+		})
+	}
+}
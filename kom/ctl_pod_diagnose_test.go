@@ -0,0 +1,240 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func podEvent(name, pod, container, reason, message string, count int32) *v1.Event {
+	e := &v1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+		InvolvedObject: v1.ObjectReference{Kind: "Pod", Namespace: "default", Name: pod},
+		Reason:         reason,
+		Message:        message,
+		Count:          count,
+		Type:           v1.EventTypeWarning,
+		LastTimestamp:  metav1.NewTime(time.Now()),
+	}
+	if container != "" {
+		e.InvolvedObject.FieldPath = "spec.containers{" + container + "}"
+	}
+	return e
+}
+
+func findingsByType(report *DiagnoseReport) map[string]*DiagnoseFinding {
+	result := map[string]*DiagnoseFinding{}
+	for _, f := range report.Findings {
+		result[f.Type] = f
+	}
+	return result
+}
+
+func TestPodDiagnoseContainers(t *testing.T) {
+	item := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "default"},
+		Spec: v1.PodSpec{
+			NodeName: "node-1",
+			Containers: []v1.Container{
+				{Name: "app", Image: "web:1.0", Resources: v1.ResourceRequirements{Limits: v1.ResourceList{v1.ResourceMemory: resource.MustParse("256Mi")}},
+					LivenessProbe: &v1.Probe{TimeoutSeconds: 1, FailureThreshold: 3}},
+				{Name: "proxy", Image: "registry.example.com/team/proxy:2.0"},
+			},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			ContainerStatuses: []v1.ContainerStatus{
+				{
+					Name:                 "app",
+					RestartCount:         5,
+					State:                v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"}},
+				},
+				{
+					Name:  "proxy",
+					State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+				},
+			},
+		},
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+			{Type: v1.NodeReady, Status: v1.ConditionTrue},
+			{Type: v1.NodeMemoryPressure, Status: v1.ConditionTrue, Message: "kubelet has insufficient memory available"},
+		}},
+	}
+	k := RegisterFakeCluster("pod-diagnose-containers-cluster", item, node,
+		podEvent("e1", "web-0", "proxy", "Failed", `Failed to pull image "registry.example.com/team/proxy:2.0": rpc error: code = Unknown desc = failed to authorize: 401 Unauthorized`, 3),
+		podEvent("e2", "web-0", "app", "Unhealthy", "Liveness probe failed: Get \"http://10.0.0.1:8080/healthz\": context deadline exceeded", 12),
+		podEvent("e3", "other-0", "app", "Unhealthy", "Readiness probe failed: connection refused", 1),
+	)
+
+	report, err := k.Namespace("default").Name("web-0").Ctl().Pod().Diagnose()
+	if err != nil {
+		t.Fatalf("diagnose failed: %v", err)
+	}
+	if report.Healthy || report.NodeName != "node-1" || len(report.Findings) != 5 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if report.Findings[0].Severity != DiagnoseCritical || report.Findings[len(report.Findings)-1].Severity != DiagnoseWarning {
+		t.Errorf("findings should be sorted by severity")
+	}
+	findings := findingsByType(report)
+	if f := findings[FindingCrashLoopBackOff]; f == nil || f.Container != "app" || f.Details["exitCode"] != "137" || f.Details["restartCount"] != "5" || f.Suggestion == "" {
+		t.Errorf("unexpected crash loop finding %+v", f)
+	}
+	if f := findings[FindingOOMKilled]; f == nil || f.Details["memoryLimit"] != "256Mi" {
+		t.Errorf("unexpected oom finding %+v", f)
+	}
+	if f := findings[FindingImagePull]; f == nil || f.Container != "proxy" || f.Details["registry"] != "registry.example.com" || f.Details["category"] != "auth" {
+		t.Errorf("unexpected image pull finding %+v", f)
+	}
+	if f := findings[FindingProbeFailed]; f == nil || f.Container != "app" || f.Details["probe"] != "liveness" || f.Details["count"] != "12" || f.Details["timeoutSeconds"] != "1" {
+		t.Errorf("unexpected probe finding %+v", f)
+	}
+	if f := findings[FindingNodeProblem]; f == nil || f.Details["condition"] != "MemoryPressure" {
+		t.Errorf("unexpected node finding %+v", f)
+	}
+}
+
+func TestPodDiagnoseUnschedulable(t *testing.T) {
+	item := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"},
+		Spec: v1.PodSpec{Containers: []v1.Container{
+			{Name: "app", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("3")}}},
+			{Name: "sidecar", Resources: v1.ResourceRequirements{Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")}}},
+		}},
+		Status: v1.PodStatus{
+			Phase: v1.PodPending,
+			Conditions: []v1.PodCondition{{
+				Type:   v1.PodScheduled,
+				Status: v1.ConditionFalse,
+				Reason: v1.PodReasonUnschedulable,
+				Message: "0/6 nodes are available: 1 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }, 2 Insufficient cpu, " +
+					"3 node(s) didn't match Pod's node affinity/selector. preemption: 0/6 nodes are available: 6 Preemption is not helpful for scheduling.",
+			}},
+		},
+	}
+	k := RegisterFakeCluster("pod-diagnose-unschedulable-cluster", item)
+	report, err := k.Namespace("default").Name("web-1").Ctl().Pod().Diagnose()
+	if err != nil {
+		t.Fatalf("diagnose failed: %v", err)
+	}
+	if len(report.Findings) != 3 {
+		t.Fatalf("expected 3 findings, got %+v", report.Findings)
+	}
+	expected := []struct{ nodes, category string }{{"1", "taint"}, {"2", "insufficient-cpu"}, {"3", "affinity"}}
+	for i, e := range expected {
+		f := report.Findings[i]
+		if f.Type != FindingUnschedulable || f.Details["nodes"] != e.nodes || f.Details["category"] != e.category {
+			t.Errorf("unexpected finding %d %+v", i, f)
+		}
+	}
+	if s := report.Findings[1].Suggestion; !strings.Contains(s, "3500m") {
+		t.Errorf("suggestion should contain total cpu requests, got %s", s)
+	}
+}
+
+func TestPodDiagnoseVolumesAndHealthy(t *testing.T) {
+	item := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "default", UID: "uid-2"},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "app"}},
+			Volumes: []v1.Volume{{Name: "data", VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "data-web-2"},
+			}}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodPending},
+	}
+	mount := podEvent("e1", "web-2", "", "FailedAttachVolume", `Multi-Attach error for volume "data" Volume is already exclusively attached to one node`, 1)
+	mount.InvolvedObject.UID = "uid-2"
+	stale := podEvent("e2", "web-2", "", "FailedMount", `MountVolume.SetUp failed for volume "config" : configmap "old" not found`, 1)
+	stale.InvolvedObject.UID = "uid-old"
+	healthy := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-3", Namespace: "default"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+		Status: v1.PodStatus{Phase: v1.PodRunning, ContainerStatuses: []v1.ContainerStatus{
+			{Name: "app", Ready: true, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}},
+		}},
+	}
+	k := RegisterFakeCluster("pod-diagnose-volume-cluster", item, healthy, mount, stale)
+
+	report, err := k.Namespace("default").Name("web-2").Ctl().Pod().Diagnose()
+	if err != nil {
+		t.Fatalf("diagnose failed: %v", err)
+	}
+	if len(report.Findings) != 1 {
+		t.Fatalf("events of the old pod should be ignored, got %+v", report.Findings)
+	}
+	if f := report.Findings[0]; f.Type != FindingVolumeMount || f.Details["volume"] != "data" || f.Details["claimName"] != "data-web-2" || !strings.Contains(f.Suggestion, "ReadWriteOnce") {
+		t.Errorf("unexpected volume finding %+v", f)
+	}
+
+	report, err = k.Namespace("default").Name("web-3").Ctl().Pod().Diagnose()
+	if err != nil || !report.Healthy || len(report.Findings) != 0 {
+		t.Errorf("pod should be healthy, got %+v %v", report, err)
+	}
+	if _, err = k.Namespace("default").Name("missing").Ctl().Pod().Diagnose(); err == nil {
+		t.Errorf("expected error for missing pod")
+	}
+}
+
+func TestPodDiagnoseNode(t *testing.T) {
+	item := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-4", Namespace: "default"},
+		Spec:       v1.PodSpec{NodeName: "node-gone", Containers: []v1.Container{{Name: "app"}}},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	k := RegisterFakeCluster("pod-diagnose-node-cluster", item)
+	var selector string
+	_ = k.Callback().List().Before("fake:list").Register("test:event-selector", func(k *Kubectl) error {
+		if len(k.Statement.ListOptions) > 0 {
+			selector = k.Statement.ListOptions[0].FieldSelector
+		}
+		return nil
+	})
+
+	// 节点不存在
+	report, err := k.Namespace("default").Name("web-4").Ctl().Pod().Diagnose()
+	if err != nil {
+		t.Fatalf("diagnose failed: %v", err)
+	}
+	if f := findingsByType(report)[FindingNodeProblem]; f == nil || f.Severity != DiagnoseCritical {
+		t.Errorf("missing node should be critical, got %+v", f)
+	}
+	if selector != "involvedObject.name=web-4,involvedObject.kind=Pod" {
+		t.Errorf("events should be listed with field selector, got %q", selector)
+	}
+
+	// 无权限读取节点时不能判断节点已删除
+	_ = k.Callback().Get().Before("fake:get").Register("test:forbid-node", func(k *Kubectl) error {
+		if k.Statement.GVR.Resource == "nodes" {
+			return apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, k.Statement.Name, nil)
+		}
+		return nil
+	})
+	report, err = k.Namespace("default").Name("web-4").Ctl().Pod().Diagnose()
+	if err != nil {
+		t.Fatalf("diagnose failed: %v", err)
+	}
+	if f := findingsByType(report)[FindingNodeProblem]; f == nil || f.Severity != DiagnoseInfo || !report.Healthy {
+		t.Errorf("node get error should be reported as info, got %+v", report.Findings)
+	}
+}
+
+func TestParseSchedulerMessage(t *testing.T) {
+	causes := parseSchedulerMessage("0/3 nodes are available: 3 Insufficient memory.")
+	if len(causes) != 1 || causes[0].nodes != "3" || causes[0].reason != "Insufficient memory" {
+		t.Errorf("unexpected causes %+v", causes)
+	}
+	if causes = parseSchedulerMessage("pod has unbound immediate PersistentVolumeClaims"); causes != nil {
+		t.Errorf("unexpected causes %+v", causes)
+	}
+}
@@ -0,0 +1,241 @@
+package kom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecResult 命令执行结果
+type ExecResult struct {
+	Stdout   []byte        `json:"stdout"`   // 标准输出，设置了 ExecStdout 时为空
+	Stderr   []byte        `json:"stderr"`   // 标准错误，设置了 ExecStderr 或开启 TTY 时为空
+	ExitCode int           `json:"exitCode"` // 退出码，命令未能正常结束（如连接失败、被取消）时为 -1
+	Duration time.Duration `json:"duration"` // 执行耗时
+}
+
+// Success 命令是否执行成功，即退出码为 0
+func (r *ExecResult) Success() bool {
+	return r.ExitCode == 0
+}
+
+// ExecOption 执行命令的配置项
+type ExecOption func(*execOptions)
+
+type execOptions struct {
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	tty       bool
+	sizeQueue remotecommand.TerminalSizeQueue
+}
+
+// ExecStdin 设置标准输入
+func ExecStdin(r io.Reader) ExecOption {
+	return func(o *execOptions) {
+		o.stdin = r
+	}
+}
+
+// ExecStdout 将标准输出写入 w，不再保存到 ExecResult.Stdout 中，适合输出较多的长时间会话
+func ExecStdout(w io.Writer) ExecOption {
+	return func(o *execOptions) {
+		o.stdout = w
+	}
+}
+
+// ExecStderr 将标准错误写入 w，不再保存到 ExecResult.Stderr 中
+func ExecStderr(w io.Writer) ExecOption {
+	return func(o *execOptions) {
+		o.stderr = w
+	}
+}
+
+// ExecTTY 是否分配 TTY，默认不分配。开启后 stderr 合并到 stdout 中
+func ExecTTY(tty bool) ExecOption {
+	return func(o *execOptions) {
+		o.tty = tty
+	}
+}
+
+// ExecTerminalSizeQueue 使用自定义的终端尺寸队列，同时开启 TTY
+// 未设置时，TTY 模式下通过 ExecSession.Resize 调整终端尺寸
+func ExecTerminalSizeQueue(queue remotecommand.TerminalSizeQueue) ExecOption {
+	return func(o *execOptions) {
+		o.sizeQueue = queue
+		o.tty = true
+	}
+}
+
+// ExecSession 执行中的命令会话
+type ExecSession struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	sizes  chan remotecommand.TerminalSize
+	done   chan struct{}
+	result *ExecResult
+	err    error
+}
+
+// StartExec 在容器内启动命令并立即返回会话，命令在后台执行
+// 会话在命令结束、调用 Close 或 WithContext 设置的上下文取消时结束，结束时会断开连接，远端进程随之退出
+//
+// Example:
+//
+//	session, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").
+//		Command("/bin/sh").StartExec(kom.ExecTTY(true), kom.ExecStdin(stdin), kom.ExecStdout(stdout))
+//	session.Resize(120, 40)
+//	result, err := session.Wait()
+func (p *pod) StartExec(opts ...ExecOption) (*ExecSession, error) {
+	if p.Error != nil {
+		return nil, p.Error
+	}
+	if p.kubectl.Error != nil {
+		return nil, p.kubectl.Error
+	}
+	options := &execOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+
+	if p.kubectl.Statement.Command == "" {
+		return nil, fmt.Errorf("请调用Command()方法设置命令")
+	}
+	// 复制 Statement，同一个 pod 多次执行时上下文及输入输出互不影响
+	statement := *p.kubectl.getInstance().Statement
+	tx := &Kubectl{ID: p.kubectl.ID, Statement: &statement}
+	parent := tx.Statement.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	tx.Statement.Context = ctx
+	s := &ExecSession{
+		ctx:    ctx,
+		cancel: cancel,
+		sizes:  make(chan remotecommand.TerminalSize, 1),
+		done:   make(chan struct{}),
+		result: &ExecResult{ExitCode: -1},
+	}
+
+	var stdout, stderr bytes.Buffer
+	streamOptions := &remotecommand.StreamOptions{
+		Stdin:  options.stdin,
+		Stdout: options.stdout,
+		Stderr: options.stderr,
+		Tty:    options.tty,
+	}
+	if streamOptions.Stdout == nil {
+		streamOptions.Stdout = &stdout
+	}
+	if streamOptions.Tty {
+		// TTY 模式下只有一个输出流
+		streamOptions.Stderr = nil
+		streamOptions.TerminalSizeQueue = options.sizeQueue
+		if streamOptions.TerminalSizeQueue == nil {
+			streamOptions.TerminalSizeQueue = s
+		}
+	} else if streamOptions.Stderr == nil {
+		streamOptions.Stderr = &stderr
+	}
+	tx.Statement.StreamOptions = streamOptions
+	tx.Statement.ExecTTY = &streamOptions.Tty
+
+	go func() {
+		defer close(s.done)
+		defer cancel()
+		start := time.Now()
+		err := tx.Callback().StreamExec().Execute(tx)
+		s.result.Duration = time.Since(start)
+		s.result.Stdout = stdout.Bytes()
+		s.result.Stderr = stderr.Bytes()
+		s.result.ExitCode, s.err = execExitCode(err)
+		if s.err != nil && ctx.Err() != nil {
+			// 会话被取消或超时
+			s.err = ctx.Err()
+		}
+	}()
+	return s, nil
+}
+
+// ExecWithResult 在容器内执行命令，等待结束后返回标准输出、标准错误、退出码及耗时
+// 命令以非 0 退出码结束时不返回错误，通过 ExitCode 判断；无法执行或连接中断时返回错误
+//
+// Example:
+//
+//	result, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").
+//		Command("sh", "-c", "ls /data").ExecWithResult()
+func (p *pod) ExecWithResult(opts ...ExecOption) (*ExecResult, error) {
+	s, err := p.StartExec(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Wait()
+}
+
+// execExitCode 从执行错误中解析退出码，命令正常结束（包括非 0 退出码）时不返回错误
+func execExitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return exitErr.ExitStatus(), nil
+	}
+	return -1, err
+}
+
+// Resize 调整终端尺寸，仅在 TTY 模式且未设置 ExecTerminalSizeQueue 时生效
+// 尚未发送的尺寸会被新的尺寸替换
+func (s *ExecSession) Resize(width, height uint16) {
+	size := remotecommand.TerminalSize{Width: width, Height: height}
+	for {
+		select {
+		case s.sizes <- size:
+			return
+		case <-s.done:
+			return
+		default:
+			// 丢弃未发送的旧尺寸
+			select {
+			case <-s.sizes:
+			default:
+			}
+		}
+	}
+}
+
+// Next 实现 remotecommand.TerminalSizeQueue，会话结束时返回 nil
+func (s *ExecSession) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-s.sizes:
+		return &size
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
+// Close 结束会话，断开连接并终止远端进程，等待后台执行结束
+func (s *ExecSession) Close() {
+	s.cancel()
+	<-s.done
+}
+
+// Done 会话结束时关闭
+func (s *ExecSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait 等待命令结束并返回结果，会话被 Close 或上下文取消时返回上下文的错误，如 context.Canceled
+func (s *ExecSession) Wait() (*ExecResult, error) {
+	<-s.done
+	return s.result, s.err
+}
@@ -0,0 +1,176 @@
+package kom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// fakeStreamExecHandler 在 fake:stream-exec 之前注册处理函数，模拟远端命令的执行
+func fakeStreamExecHandler(k *Kubectl, handler func(k *Kubectl, opts *remotecommand.StreamOptions) error) {
+	_ = k.Callback().StreamExec().Before("fake:stream-exec").Register("test:stream-exec", func(k *Kubectl) error {
+		return handler(k, k.Statement.StreamOptions)
+	})
+}
+
+func TestExecWithResult(t *testing.T) {
+	k := RegisterFakeCluster("exec-result-cluster", runningPod("web-0", nil, "app"))
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		if opts.Tty || opts.TerminalSizeQueue != nil || k.Statement.ExecTTY == nil || *k.Statement.ExecTTY {
+			t.Errorf("tty should be disabled by default")
+		}
+		if k.Statement.Command != "sh" || strings.Join(k.Statement.Args, " ") != "-c exit 3" {
+			t.Errorf("unexpected command %s %v", k.Statement.Command, k.Statement.Args)
+		}
+		_, _ = io.WriteString(opts.Stdout, "out")
+		_, _ = io.WriteString(opts.Stderr, "err")
+		return fmt.Errorf("error Stream executing command: %w", utilexec.CodeExitError{Err: errors.New("command terminated with exit code 3"), Code: 3})
+	})
+
+	result, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Command("sh", "-c", "exit 3").ExecWithResult()
+	if err != nil {
+		t.Fatalf("non-zero exit code should not be an error: %v", err)
+	}
+	if string(result.Stdout) != "out" || string(result.Stderr) != "err" || result.ExitCode != 3 || result.Success() {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	if _, err = k.Namespace("default").Name("web-0").Ctl().Pod().ExecWithResult(); err == nil {
+		t.Errorf("expected error without command")
+	}
+}
+
+func TestExecWithResultWriters(t *testing.T) {
+	k := RegisterFakeCluster("exec-writers-cluster", runningPod("web-0", nil, "app"))
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		data, _ := io.ReadAll(opts.Stdin)
+		_, _ = opts.Stdout.Write(bytes.ToUpper(data))
+		_, _ = io.WriteString(opts.Stderr, "warning")
+		return nil
+	})
+
+	var stdout, stderr bytes.Buffer
+	result, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Command("tr", "a-z", "A-Z").
+		ExecWithResult(ExecStdin(strings.NewReader("hello")), ExecStdout(&stdout), ExecStderr(&stderr))
+	if err != nil {
+		t.Fatalf("exec failed: %v", err)
+	}
+	if stdout.String() != "HELLO" || stderr.String() != "warning" {
+		t.Errorf("output should be written to writers, got %q %q", stdout.String(), stderr.String())
+	}
+	if len(result.Stdout) != 0 || len(result.Stderr) != 0 || !result.Success() {
+		t.Errorf("output should not be captured when writers are set: %+v", result)
+	}
+}
+
+func TestExecWithResultError(t *testing.T) {
+	k := RegisterFakeCluster("exec-error-cluster", runningPod("web-0", nil, "app"))
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		return errors.New("container not found")
+	})
+
+	result, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Command("ls").ExecWithResult()
+	if err == nil || !strings.Contains(err.Error(), "container not found") {
+		t.Fatalf("expected transport error, got %v", err)
+	}
+	if result.ExitCode != -1 {
+		t.Errorf("expected exit code -1, got %d", result.ExitCode)
+	}
+}
+
+// StreamExecuteWithOptions 不显式指定 TTY，保持有 stdin 即分配 TTY 的行为
+func TestStreamExecuteWithOptionsTTY(t *testing.T) {
+	k := RegisterFakeCluster("exec-stream-options-cluster", runningPod("web-0", nil, "app"))
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		if k.Statement.ExecTTY != nil {
+			t.Errorf("tty should not be set explicitly, got %v", *k.Statement.ExecTTY)
+		}
+		return nil
+	})
+	stdin, _ := io.Pipe()
+	err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Command("/bin/sh").
+		StreamExecuteWithOptions(&remotecommand.StreamOptions{Stdin: stdin, Stdout: io.Discard}).Error
+	if err != nil {
+		t.Fatalf("stream execute failed: %v", err)
+	}
+}
+
+func TestStartExecInteractive(t *testing.T) {
+	k := RegisterFakeCluster("exec-interactive-cluster", runningPod("web-0", nil, "app"))
+	started := make(chan struct{})
+	sizes := make(chan remotecommand.TerminalSize, 1)
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		if !opts.Tty || opts.Stderr != nil || opts.TerminalSizeQueue == nil || opts.Stdin == nil || k.Statement.ExecTTY == nil || !*k.Statement.ExecTTY {
+			t.Errorf("unexpected interactive stream options: %+v", opts)
+		}
+		_, _ = io.WriteString(opts.Stdout, "$ ")
+		close(started)
+		if size := opts.TerminalSizeQueue.Next(); size != nil {
+			sizes <- *size
+		}
+		// 模拟交互式进程，直到会话结束
+		<-k.Statement.Context.Done()
+		return fmt.Errorf("error Stream executing command: %w", k.Statement.Context.Err())
+	})
+
+	stdin, _ := io.Pipe()
+	var stdout bytes.Buffer
+	session, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Command("/bin/sh").
+		StartExec(ExecTTY(true), ExecStdin(stdin), ExecStdout(&stdout))
+	if err != nil {
+		t.Fatalf("start exec failed: %v", err)
+	}
+	<-started
+	session.Resize(80, 24)
+	session.Resize(120, 40)
+	select {
+	case size := <-sizes:
+		if size.Width != 120 && size.Width != 80 {
+			t.Errorf("unexpected terminal size %+v", size)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("terminal size was not delivered")
+	}
+	select {
+	case <-session.Done():
+		t.Fatalf("session should still be running")
+	default:
+	}
+
+	session.Close()
+	result, err := session.Wait()
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if result.ExitCode != -1 || stdout.String() != "$ " {
+		t.Errorf("unexpected result %+v, stdout %q", result, stdout.String())
+	}
+	// 会话结束后 Resize 不会阻塞
+	session.Resize(10, 10)
+}
+
+func TestStartExecContextCancel(t *testing.T) {
+	k := RegisterFakeCluster("exec-context-cluster", runningPod("web-0", nil, "app"))
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		<-k.Statement.Context.Done()
+		return k.Statement.Context.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err := k.WithContext(ctx).Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").Command("sleep", "3600").ExecWithResult()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("unexpected duration %v", result.Duration)
+	}
+}
@@ -3,283 +3,758 @@ package kom
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/weibaohui/kom/utils"
 	"k8s.io/klog/v2"
 )
 
 // FileInfo 文件节点结构
 type FileInfo struct {
-	Name        string `json:"name"`
-	Type        string `json:"type"` // file or directory
-	Permissions string `json:"permissions"`
-	Owner       string `json:"owner"`
-	Group       string `json:"group"`
-	Size        int64  `json:"size"`
-	ModTime     string `json:"modTime"`
-	Path        string `json:"path"`  // 存储路径
-	IsDir       bool   `json:"isDir"` // 指示是否
-}
-
-// ListFiles  获取容器中指定路径的文件和目录列表
-func (p *pod) ListFiles(path string) ([]*FileInfo, error) {
-	klog.V(6).Infof("ListFiles %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`        // file、directory、link、block、character、pipe、socket
+	Permissions string      `json:"permissions"` // 与 ls -l 相同格式的权限，如 -rw-r--r--
+	Owner       string      `json:"owner"`
+	Group       string      `json:"group"`
+	Size        int64       `json:"size"`
+	ModTime     string      `json:"modTime"` // 修改时间，格式为 2006-01-02 15:04:05
+	Path        string      `json:"path"`    // 存储路径
+	IsDir       bool        `json:"isDir"`   // 指示是否
+	Mode        os.FileMode `json:"mode"`
+	ModifiedAt  time.Time   `json:"modifiedAt"`
+	LinkTarget  string      `json:"linkTarget,omitempty"` // 符号链接指向的路径
+}
+
+// FileOption 文件操作的配置项
+type FileOption func(*fileOptions)
+
+type fileOptions struct {
+	mode   os.FileMode
+	verify bool
+}
+
+// FileMode 设置上传文件的权限，默认 0644，上传 os.File 时默认使用本地文件的权限
+func FileMode(mode os.FileMode) FileOption {
+	return func(o *fileOptions) {
+		o.mode = mode
+	}
+}
+
+// FileVerifyChecksum 传输完成后在容器内执行 sha256sum，与本地计算的结果比对，不一致时返回错误
+func FileVerifyChecksum() FileOption {
+	return func(o *fileOptions) {
+		o.verify = true
+	}
+}
+
+func newFileOptions(opts []FileOption) *fileOptions {
+	options := &fileOptions{mode: 0644}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	return options
+}
+
+const (
+	// statFormat stat 输出格式：原始 mode(16进制) 大小 修改时间 uid gid 用户 用户组 路径
+	statFormat = "%f %s %Y %u %g %U %G %n"
+	// fileArgsBatch 单次命令传入的路径数量上限
+	fileArgsBatch = 256
+	// maxDownloadSize DownloadFile 读取到内存中的文件大小上限
+	maxDownloadSize = 500 * 1024 * 1024
+)
+
+// errTarClosed 一端提前结束时关闭 tar 数据流
+var errTarClosed = errors.New("tar stream closed")
+
+// cleanPodPath 规范化容器内的路径，相对路径以 ./ 开头，避免被命令当作参数解析
+func cleanPodPath(p string) (string, error) {
+	if p == "" {
+		return "", fmt.Errorf("path is empty")
+	}
+	p = path.Clean(p)
+	if !strings.HasPrefix(p, "/") && p != "." {
+		p = "./" + p
+	}
+	return p, nil
+}
 
-	var result []byte
-	err := p.Command("ls", "-l", path).Execute(&result).Error
+// runFileCommand 在容器内执行命令，参数直接传给进程，不经过 shell 解析
+func (p *pod) runFileCommand(opts []ExecOption, command string, args ...string) (*ExecResult, error) {
+	result, err := p.fileExec(opts, command, args...)
 	if err != nil {
-		return nil, fmt.Errorf("error executing ListFiles: %v", err)
+		return nil, fmt.Errorf("error executing %s: %w", command, err)
 	}
+	if !result.Success() {
+		return result, fmt.Errorf("%s exited with code %d: %s", command, result.ExitCode, strings.TrimSpace(string(result.Stderr)))
+	}
+	return result, nil
+}
 
-	return parseFileList(path, string(result)), nil
+// ListFiles  获取容器中指定路径的文件和目录列表，不包含隐藏文件
+func (p *pod) ListFiles(path string) ([]*FileInfo, error) {
+	klog.V(6).Infof("ListFiles %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	return p.listFiles(path, false)
 }
+
+// ListAllFiles 获取容器中指定路径的文件和目录列表，包含隐藏文件
 func (p *pod) ListAllFiles(path string) ([]*FileInfo, error) {
-	klog.V(6).Infof("ListFiles %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	klog.V(6).Infof("ListAllFiles %s from [%s/%s:%s]\n", path, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	return p.listFiles(path, true)
+}
 
-	var result []byte
-	err := p.Command("ls", "-l", "-a", path).Execute(&result).Error
+// listFiles 通过 find 获取目录下的文件名，再通过 stat 获取文件属性，文件名以 \0 分隔，支持空格等特殊字符
+func (p *pod) listFiles(dir string, all bool) ([]*FileInfo, error) {
+	dir, err := p.podFilePath(dir)
 	if err != nil {
-		return nil, fmt.Errorf("error executing ListFiles: %v", err)
+		return nil, err
 	}
-
-	return parseFileList(path, string(result)), nil
+	result, err := p.runFileCommand(nil, "find", dir, "-mindepth", "1", "-maxdepth", "1", "-print0")
+	if err != nil {
+		return nil, fmt.Errorf("error executing ListFiles: %w", err)
+	}
+	var paths []string
+	for _, name := range strings.Split(string(result.Stdout), "\x00") {
+		if name == "" || (!all && strings.HasPrefix(path.Base(name), ".")) {
+			continue
+		}
+		paths = append(paths, name)
+	}
+	files, err := p.statFiles(paths)
+	if err != nil {
+		return nil, fmt.Errorf("error executing ListFiles: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
 }
-func (p *pod) DownloadFile(filePath string) ([]byte, error) {
-	klog.V(6).Infof("DownloadFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 
-	result, err := p.DownloadTarFile(filePath)
+// StatFile 获取容器中文件或目录的属性，符号链接返回链接本身的属性及指向的路径
+func (p *pod) StatFile(filePath string) (*FileInfo, error) {
+	klog.V(6).Infof("StatFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	filePath, err := p.podFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	files, err := p.statFiles([]string{filePath})
 	if err != nil {
-		return nil, fmt.Errorf("error executing DownloadTarFile: %v", err)
+		return nil, err
 	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("file %s not found in container", filePath)
+	}
+	return files[0], nil
+}
 
-	tr := tar.NewReader(bytes.NewReader(result))
-	var fileContent []byte
-	found := false
+// statFiles 批量获取文件属性，执行期间被删除的文件会被忽略
+func (p *pod) statFiles(paths []string) ([]*FileInfo, error) {
+	var files []*FileInfo
+	var links []*FileInfo
+	for start := 0; start < len(paths); start += fileArgsBatch {
+		batch := paths[start:min(start+fileArgsBatch, len(paths))]
+		args := append([]string{"-c", statFormat, "--"}, batch...)
+		result, err := p.fileExec(nil, "stat", args...)
+		if err != nil {
+			return nil, fmt.Errorf("error executing stat: %w", err)
+		}
+		parsed := parseStatOutput(string(result.Stdout), batch)
+		if !result.Success() && len(parsed) == 0 {
+			return nil, fmt.Errorf("stat exited with code %d: %s", result.ExitCode, strings.TrimSpace(string(result.Stderr)))
+		}
+		for _, f := range parsed {
+			if f.Type == "link" {
+				links = append(links, f)
+			}
+		}
+		files = append(files, parsed...)
+	}
+	if err := p.readLinkTargets(links); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		f.Path = p.displayFilePath(f.Path)
+	}
+	return files, nil
+}
 
-	// 遍历 tar 中的每个文件
-	for {
-		header, err := tr.Next()
-		if err == io.EOF {
-			break
+// parseStatOutput 按传入的路径顺序解析 stat 输出
+// 每条记录以路径结尾，按已知路径匹配，路径中包含空格、换行也能正确解析，未输出的路径被跳过
+func parseStatOutput(output string, paths []string) []*FileInfo {
+	var files []*FileInfo
+	for _, p := range paths {
+		fields := strings.SplitN(output, " ", 8)
+		if len(fields) < 8 || !strings.HasPrefix(fields[7], p+"\n") {
+			// 该文件 stat 失败，没有输出
+			continue
 		}
+		info, err := newStatFileInfo(p, fields[:7])
 		if err != nil {
-			return nil, fmt.Errorf("error reading tar header: %v", err)
+			klog.V(6).Infof("parse stat output of %s error: %v", p, err)
+			continue
 		}
+		files = append(files, info)
+		output = strings.TrimPrefix(fields[7], p+"\n")
+	}
+	return files
+}
 
-		if header.Name == strings.TrimPrefix(filePath, "/") {
-			found = true
-			// 使用带有大小限制的读取方式
-			if header.Size > 500*1024*1024 { // 500MB 限制
-				return nil, fmt.Errorf("file size %d exceeds maximum allowed size", header.Size)
-			}
+// newStatFileInfo 根据 stat 输出的字段生成 FileInfo
+func newStatFileInfo(p string, fields []string) (*FileInfo, error) {
+	rawMode, err := strconv.ParseUint(fields[0], 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	mtime, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	owner, group := fields[5], fields[6]
+	// 容器内没有对应的用户时使用 uid、gid
+	if owner == "UNKNOWN" {
+		owner = fields[3]
+	}
+	if group == "UNKNOWN" {
+		group = fields[4]
+	}
+	mode := unixFileMode(uint32(rawMode))
+	modifiedAt := time.Unix(mtime, 0)
+	return &FileInfo{
+		Name:        path.Base(p),
+		Type:        getFileType(mode),
+		Permissions: lsPermissions(mode),
+		Owner:       owner,
+		Group:       group,
+		Size:        size,
+		ModTime:     modifiedAt.Format(time.DateTime),
+		Path:        p,
+		IsDir:       mode.IsDir(),
+		Mode:        mode,
+		ModifiedAt:  modifiedAt,
+	}, nil
+}
 
-			buf := bytes.NewBuffer(make([]byte, 0, header.Size))
-			if _, err := io.Copy(buf, tr); err != nil {
-				return nil, fmt.Errorf("error reading file content: %v", err)
+// readLinkTargets 通过 tar 获取符号链接指向的路径，tar 不跟随符号链接，只输出链接本身
+func (p *pod) readLinkTargets(links []*FileInfo) error {
+	for start := 0; start < len(links); start += fileArgsBatch {
+		batch := links[start:min(start+fileArgsBatch, len(links))]
+		args := []string{"-c", "-f", "-", "--"}
+		for _, l := range batch {
+			args = append(args, l.Path)
+		}
+		result, err := p.fileExec(nil, "tar", args...)
+		if err != nil {
+			return fmt.Errorf("error executing tar: %w", err)
+		}
+		tr := tar.NewReader(bytes.NewReader(result.Stdout))
+		for {
+			header, err := tr.Next()
+			if err != nil {
+				break
+			}
+			name := "/" + strings.TrimPrefix(header.Name, "/")
+			for _, l := range batch {
+				if header.Typeflag == tar.TypeSymlink && (l.Path == name || l.Path == header.Name) {
+					l.LinkTarget = header.Linkname
+				}
 			}
-			fileContent = buf.Bytes()
-			break
 		}
 	}
+	return nil
+}
 
-	if !found {
-		return nil, fmt.Errorf("file %s not found in container", filePath)
+// unixFileMode 将 stat 输出的 st_mode 转换为 os.FileMode
+func unixFileMode(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0777)
+	switch raw & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	case 0060000:
+		mode |= os.ModeDevice
+	case 0020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0010000:
+		mode |= os.ModeNamedPipe
+	case 0140000:
+		mode |= os.ModeSocket
+	}
+	if raw&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// getFileType 根据文件权限获取文件类型
+func getFileType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "directory" // 目录
+	case mode&os.ModeSymlink != 0:
+		return "link" // 符号链接
+	case mode&os.ModeCharDevice != 0:
+		return "character" // 字符设备
+	case mode&os.ModeDevice != 0:
+		return "block" // 块设备
+	case mode&os.ModeNamedPipe != 0:
+		return "pipe" // 命名管道
+	case mode&os.ModeSocket != 0:
+		return "socket" // 套接字
+	case mode.IsRegular():
+		return "file" // 普通文件
+	default:
+		return "unknown" // 未知类型
 	}
+}
 
-	return fileContent, nil
+// lsPermissions 生成与 ls -l 相同格式的权限字符串，如 drwxr-xr-x
+func lsPermissions(mode os.FileMode) string {
+	types := map[string]byte{"directory": 'd', "link": 'l', "block": 'b', "character": 'c', "pipe": 'p', "socket": 's', "file": '-'}
+	t, ok := types[getFileType(mode)]
+	if !ok {
+		t = '?'
+	}
+	b := []byte{t}
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			b = append(b, rwx[i])
+		} else {
+			b = append(b, '-')
+		}
+	}
+	special := func(i int, set bool, c byte) {
+		if !set {
+			return
+		}
+		if b[i] == 'x' {
+			b[i] = c
+		} else {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	special(3, mode&os.ModeSetuid != 0, 's')
+	special(6, mode&os.ModeSetgid != 0, 's')
+	special(9, mode&os.ModeSticky != 0, 't')
+	return string(b)
 }
-func (p *pod) DownloadTarFile(filePath string) ([]byte, error) {
-	klog.V(6).Infof("DownloadTarFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
 
-	var result []byte
-	err := p.Command("tar", "cf", "-", filePath).Execute(&result).Error
-	if err != nil {
-		return nil, fmt.Errorf("error executing DownloadTarFile: %v", err)
+// DownloadFile 下载容器中的文件，文件内容读取到内存中，大文件请使用 DownloadFileTo
+func (p *pod) DownloadFile(filePath string, opts ...FileOption) ([]byte, error) {
+	klog.V(6).Infof("DownloadFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+
+	var buf bytes.Buffer
+	if err := p.downloadFile(filePath, &buf, maxDownloadSize, opts); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
+}
 
-	return result, nil
+// DownloadFileTo 下载容器中的文件并写入 w，以流的方式传输，适合大文件
+// 符号链接会下载其指向的文件
+func (p *pod) DownloadFileTo(filePath string, w io.Writer, opts ...FileOption) error {
+	klog.V(6).Infof("DownloadFileTo %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	return p.downloadFile(filePath, w, -1, opts)
 }
-func (p *pod) DeleteFile(filePath string) ([]byte, error) {
-	klog.V(6).Infof("DeleteFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
-	var result []byte
-	err := p.Command("rm", "-rf", filePath).Execute(&result).Error
+
+// downloadFile 通过 tar 读取单个文件写入 w，maxSize 小于 0 时不限制大小
+func (p *pod) downloadFile(filePath string, w io.Writer, maxSize int64, opts []FileOption) error {
+	options := newFileOptions(opts)
+	filePath, err := p.podFilePath(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("error executing DeleteFile : %v", err)
+		return err
 	}
 
-	return result, nil
+	var sum hash.Hash
+	err = p.readTar([]string{"-c", "-h", "-f", "-", "--", filePath}, func(tr *tar.Reader) error {
+		header, err := tr.Next()
+		if err != nil {
+			return fmt.Errorf("file %s not found in container: %w", filePath, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return fmt.Errorf("%s is not a regular file", filePath)
+		}
+		if maxSize >= 0 && header.Size > maxSize {
+			return fmt.Errorf("file size %d exceeds maximum allowed size", header.Size)
+		}
+		if options.verify {
+			sum = sha256.New()
+			w = io.MultiWriter(w, sum)
+		}
+		if _, err = io.Copy(w, tr); err != nil {
+			return fmt.Errorf("error reading file content: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error executing DownloadFile: %w", err)
+	}
+	if options.verify {
+		return p.verifyChecksums([]string{filePath}, []string{hex.EncodeToString(sum.Sum(nil))})
+	}
+	return nil
 }
 
-// UploadFile 将文件上传到指定容器
-func (p *pod) UploadFile(destPath string, file *os.File) error {
-	klog.V(6).Infof("UploadFile %s to [%s/%s:%s] \n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+// readTar 在容器内执行 tar 命令，以流的方式读取输出的 tar 内容
+func (p *pod) readTar(args []string, read func(tr *tar.Reader) error) error {
+	pr, pw := io.Pipe()
+	type execDone struct {
+		result *ExecResult
+		err    error
+	}
+	done := make(chan execDone, 1)
+	go func() {
+		result, err := p.fileExec([]ExecOption{ExecStdout(pw)}, "tar", args...)
+		_ = pw.Close()
+		done <- execDone{result, err}
+	}()
+
+	readErr := read(tar.NewReader(pr))
+	if readErr == nil {
+		// 读取剩余内容，避免远端阻塞
+		_, readErr = io.Copy(io.Discard, pr)
+	}
+	_ = pr.CloseWithError(errTarClosed)
+	d := <-done
+	if d.err == nil && !d.result.Success() {
+		return fmt.Errorf("tar exited with code %d: %s", d.result.ExitCode, strings.TrimSpace(string(d.result.Stderr)))
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if d.err != nil {
+		return fmt.Errorf("error executing tar: %w", d.err)
+	}
+	return nil
+}
 
-	// 读取并压缩文件内容
+// DownloadTarFile 下载容器中的文件或目录，返回 tar 格式的内容，目录会递归打包
+func (p *pod) DownloadTarFile(filePath string) ([]byte, error) {
 	var buf bytes.Buffer
-	if err := createTar(file, &buf); err != nil {
-		panic(err.Error())
-	}
-	var result []byte
-	err := p.
-		Stdin(&buf).
-		Command("tar", "-xmf", "-", "-C", destPath).
-		Execute(&result).Error
+	if err := p.DownloadTarTo(filePath, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DownloadTarTo 将容器中的文件或目录以 tar 格式写入 w，目录会递归打包
+func (p *pod) DownloadTarTo(filePath string, w io.Writer) error {
+	klog.V(6).Infof("DownloadTarFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	filePath, err := p.podFilePath(filePath)
 	if err != nil {
-		return fmt.Errorf("error executing UploadFile: %v", err)
+		return err
+	}
+	args := []string{"-c", "-f", "-", "--", filePath}
+	if root := p.fileRoot(); root != "" && strings.HasPrefix(filePath, root+"/") {
+		// 保持与直接在目标容器中打包相同的路径
+		args = []string{"-c", "-f", "-", "-C", root, "--", strings.TrimPrefix(filePath, root+"/")}
+	}
+	if _, err = p.runFileCommand([]ExecOption{ExecStdout(w)}, "tar", args...); err != nil {
+		return fmt.Errorf("error executing DownloadTarFile: %w", err)
 	}
 	return nil
 }
 
-// createTar 创建一个 tar 格式的压缩文件
-func createTar(file *os.File, buf *bytes.Buffer) error {
-	// 创建 tar writer
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
-
-	// 获取文件信息
-	stat, err := file.Stat()
+// DownloadDir 递归下载容器中的目录到本地目录，本地目录不存在时自动创建
+// 符号链接及设备文件等特殊文件会被忽略
+func (p *pod) DownloadDir(dirPath string, localDir string, opts ...FileOption) error {
+	klog.V(6).Infof("DownloadDir %s from [%s/%s:%s] to %s\n", dirPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName, localDir)
+	options := newFileOptions(opts)
+	dirPath, err := p.podFilePath(dirPath)
 	if err != nil {
 		return err
 	}
+	if err = os.MkdirAll(localDir, 0755); err != nil {
+		return err
+	}
 
-	// 添加文件头信息
-	hdr := &tar.Header{
-		Name: stat.Name(),
-		Mode: int64(stat.Mode()),
-		Size: stat.Size(),
+	var remotePaths, sums []string
+	err = p.readTar([]string{"-c", "-f", "-", "-C", dirPath, "--", "."}, func(tr *tar.Reader) error {
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("error reading tar header: %w", err)
+			}
+			name := path.Clean(header.Name)
+			if name == "." {
+				continue
+			}
+			if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+				return fmt.Errorf("invalid file path %s in tar", header.Name)
+			}
+			target := filepath.Join(localDir, filepath.FromSlash(name))
+			switch header.Typeflag {
+			case tar.TypeDir:
+				if err = os.MkdirAll(target, 0755); err != nil {
+					return err
+				}
+			case tar.TypeReg:
+				sum, err := writeLocalFile(target, tr, header.FileInfo().Mode().Perm(), options.verify)
+				if err != nil {
+					return err
+				}
+				if options.verify {
+					remotePaths = append(remotePaths, path.Join(dirPath, name))
+					sums = append(sums, sum)
+				}
+			default:
+				klog.V(6).Infof("DownloadDir skip %s, type %c", header.Name, header.Typeflag)
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("error executing DownloadDir: %w", err)
 	}
-	if err := tw.WriteHeader(hdr); err != nil {
-		return err
+	if options.verify {
+		return p.verifyChecksums(remotePaths, sums)
 	}
+	return nil
+}
 
-	// 将文件内容写入到 tar
-	_, err = io.Copy(tw, file)
-	return err
+// writeLocalFile 写入本地文件，verify 为 true 时返回 sha256
+func writeLocalFile(target string, r io.Reader, mode os.FileMode, verify bool) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	var w io.Writer = f
+	sum := sha256.New()
+	if verify {
+		w = io.MultiWriter(f, sum)
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
 }
 
-// SaveFile
-// TODO 要写入文件的字节数据
-//
-//	data := []byte("这是一些字节数据。")
-//
-//	// 创建或打开文件
-//	file, err := os.Create("output.txt")
-//	if err != nil {
-//	    fmt.Println("无法创建文件:", err)
-//	    return
-//	}
-//	defer file.Close() // 确保在函数结束时关闭文件
-//
-//	// 将 []byte 写入文件
-//	_, err = file.Write(data)
-//	if err != nil {
-//	    fmt.Println("写入文件失败:", err)
-//	    return
-//	}
-//
-//	fmt.Println("字节数据已成功写入文件.")
-func (p *pod) SaveFile(destPath string, context string) error {
-	klog.V(6).Infof("SaveFile %s to [%s/%s:%s]\n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
-	klog.V(8).Infof("SaveFile %s \n", context)
+// DeleteFile 删除容器中的文件或目录
+func (p *pod) DeleteFile(filePath string) ([]byte, error) {
+	klog.V(6).Infof("DeleteFile %s from [%s/%s:%s]\n", filePath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	filePath, err := p.podFilePath(filePath)
+	if err != nil {
+		return nil, err
+	}
+	result, err := p.runFileCommand(nil, "rm", "-rf", "--", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error executing DeleteFile : %w", err)
+	}
+	return result.Stdout, nil
+}
 
-	var result []byte
-	err := p.
-		Stdin(strings.NewReader(context)).
-		Command("sh", "-c", fmt.Sprintf("cat > %s", destPath)).
-		Execute(&result).Error
+// UploadFile 将文件上传到容器的指定目录下，文件名与本地文件相同
+func (p *pod) UploadFile(destPath string, file *os.File, opts ...FileOption) error {
+	klog.V(6).Infof("UploadFile %s to [%s/%s:%s] \n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	opts = append([]FileOption{FileMode(stat.Mode().Perm())}, opts...)
+	return p.UploadFileFrom(path.Join(destPath, filepath.Base(stat.Name())), file, stat.Size(), opts...)
+}
+
+// UploadFileFrom 将 r 中的内容写入容器中的 destPath 文件，以流的方式传输，适合大文件
+// size 为内容长度，未知时传入 -1，此时会先将内容缓存到本地临时文件
+func (p *pod) UploadFileFrom(destPath string, r io.Reader, size int64, opts ...FileOption) error {
+	klog.V(6).Infof("UploadFileFrom %s to [%s/%s:%s] \n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	options := newFileOptions(opts)
+	destPath, err := p.podFilePath(destPath)
 	if err != nil {
-		return fmt.Errorf("error executing command: %v", err)
+		return err
+	}
+	if size < 0 {
+		// tar 需要预先知道文件大小
+		tmp, err := os.CreateTemp("", "kom-upload-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, r); err != nil {
+			return err
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = tmp
+	}
+
+	sum := sha256.New()
+	err = p.writeTar(path.Dir(destPath), func(tw *tar.Writer) error {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Base(destPath),
+			Mode:     int64(options.mode.Perm()),
+			Size:     size,
+			ModTime:  time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		n, err := io.Copy(io.MultiWriter(tw, sum), r)
+		if err == nil && n != size {
+			err = fmt.Errorf("expected %d bytes, got %d", size, n)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error executing UploadFile: %w", err)
+	}
+	if options.verify {
+		return p.verifyChecksums([]string{destPath}, []string{hex.EncodeToString(sum.Sum(nil))})
 	}
 	return nil
 }
 
-// getFileType 根据文件权限获取文件类型
-//
-// l 代表符号链接（Symbolic link）
-// - 代表普通文件（Regular file）
-// d 代表目录（Directory）
-// b 代表块设备（Block device）
-// c 代表字符设备（Character device）
-// p 代表命名管道（Named pipe）
-// s 代表套接字（Socket）
-func getFileType(permissions string) string {
-	// 获取文件类型标志位
-	p := permissions[0]
-	var fileType string
-
-	switch p {
-	case 'd':
-		fileType = "directory" // 目录
-	case '-':
-		fileType = "file" // 普通文件
-	case 'l':
-		fileType = "link" // 符号链接
-	case 'b':
-		fileType = "block" // 块设备
-	case 'c':
-		fileType = "character" // 字符设备
-	case 'p':
-		fileType = "pipe" // 命名管道
-	case 's':
-		fileType = "socket" // 套接字
-	default:
-		fileType = "unknown" // 未知类型
+// UploadDir 将本地目录递归上传到容器的 destDir 目录下，destDir 不存在时自动创建
+func (p *pod) UploadDir(localDir string, destDir string, opts ...FileOption) error {
+	klog.V(6).Infof("UploadDir %s to %s [%s/%s:%s] \n", localDir, destDir, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	options := newFileOptions(opts)
+	destDir, err := p.podFilePath(destDir)
+	if err != nil {
+		return err
+	}
+	if _, err = p.runFileCommand(nil, "mkdir", "-p", "--", destDir); err != nil {
+		return fmt.Errorf("error executing UploadDir: %w", err)
 	}
 
-	return fileType
+	var remotePaths, sums []string
+	err = p.writeTar(destDir, func(tw *tar.Writer) error {
+		return filepath.WalkDir(localDir, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(localDir, file)
+			if err != nil || rel == "." {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			link := ""
+			if info.Mode()&os.ModeSymlink != 0 {
+				if link, err = os.Readlink(file); err != nil {
+					return err
+				}
+			}
+			header, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(rel)
+			if err = tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			sum := sha256.New()
+			if _, err = io.Copy(io.MultiWriter(tw, sum), f); err != nil {
+				return err
+			}
+			remotePaths = append(remotePaths, path.Join(destDir, header.Name))
+			sums = append(sums, hex.EncodeToString(sum.Sum(nil)))
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("error executing UploadDir: %w", err)
+	}
+	if options.verify {
+		return p.verifyChecksums(remotePaths, sums)
+	}
+	return nil
 }
 
-// parseFileList 解析输出并生成 FileInfo 列表
-func parseFileList(path, output string) []*FileInfo {
-	var nodes []*FileInfo
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
-		}
-		parts := strings.Fields(line)
-		klog.V(6).Infof("parseFileList path %s %s\n", path, line)
-		if len(parts) < 9 {
-			continue // 不完整的行
+// writeTar 将 write 生成的 tar 内容以流的方式解压到容器的 destDir 目录，不恢复文件属主
+func (p *pod) writeTar(destDir string, write func(tw *tar.Writer) error) error {
+	pr, pw := io.Pipe()
+	writeErr := make(chan error, 1)
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := write(tw)
+		if err == nil {
+			err = tw.Close()
 		}
+		_ = pw.CloseWithError(err)
+		writeErr <- err
+	}()
+	_, err := p.runFileCommand([]ExecOption{ExecStdin(pr)}, "tar", "-x", "-o", "-f", "-", "-C", destDir)
+	// 远端提前退出时，停止写入
+	_ = pr.CloseWithError(errTarClosed)
+	if e := <-writeErr; e != nil && !errors.Is(e, errTarClosed) {
+		// 优先返回本地读取文件的错误
+		return e
+	}
+	return err
+}
 
-		permissions := parts[0]
-		name := parts[8]
-		size := parts[4]
-		owner := parts[2]
-		group := parts[3]
-		modTime := strings.Join(parts[5:8], " ")
-
-		// 判断文件类型
-
-		fileType := getFileType(permissions)
-
-		// 封装成 FileInfo
-		node := FileInfo{
-			Path:        fmt.Sprintf("/%s", name),
-			Name:        name,
-			Type:        fileType,
-			Owner:       owner,
-			Group:       group,
-			Permissions: permissions,
-			Size:        utils.ToInt64(size),
-			ModTime:     modTime,
-			IsDir:       fileType == "directory",
+// verifyChecksums 在容器内计算文件的 sha256 并与期望值比对
+func (p *pod) verifyChecksums(paths []string, expected []string) error {
+	for start := 0; start < len(paths); start += fileArgsBatch {
+		end := min(start+fileArgsBatch, len(paths))
+		args := append([]string{"--"}, paths[start:end]...)
+		result, err := p.runFileCommand(nil, "sha256sum", args...)
+		if err != nil {
+			return fmt.Errorf("error verifying checksum: %w", err)
 		}
-		if strings.HasPrefix(name, "/") {
-			node.Path = name
-		} else if path != "/" && path != name {
-			node.Path = fmt.Sprintf("%s/%s", path, name)
-		} else {
-			node.Path = fmt.Sprintf("/%s", name)
+		// 按传入顺序逐行输出，文件名包含特殊字符时行首为 \
+		lines := strings.Split(strings.TrimSuffix(string(result.Stdout), "\n"), "\n")
+		if len(lines) != end-start {
+			return fmt.Errorf("error verifying checksum: unexpected sha256sum output %q", result.Stdout)
+		}
+		for i, line := range lines {
+			actual := strings.TrimPrefix(line, "\\")
+			if len(actual) < sha256.Size*2 || actual[:sha256.Size*2] != expected[start+i] {
+				return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", paths[start+i], expected[start+i], line)
+			}
 		}
-
-		nodes = append(nodes, &node)
 	}
+	return nil
+}
 
-	return nodes
+// SaveFile 将内容写入容器中的 destPath 文件，文件已存在时覆盖
+func (p *pod) SaveFile(destPath string, context string) error {
+	klog.V(6).Infof("SaveFile %s to [%s/%s:%s]\n", destPath, p.kubectl.Statement.Namespace, p.kubectl.Statement.Name, p.kubectl.Statement.ContainerName)
+	klog.V(8).Infof("SaveFile %s \n", context)
+	return p.UploadFileFrom(destPath, strings.NewReader(context), int64(len(context)))
 }
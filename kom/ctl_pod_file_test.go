@@ -0,0 +1,223 @@
+package kom
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// fakeLocalContainer 在本机执行容器内的命令，模拟容器的文件系统，返回执行过的命令
+func fakeLocalContainer(t *testing.T, k *Kubectl) func() []string {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires GNU find, stat and tar")
+	}
+	for _, tool := range []string{"find", "stat", "tar", "sha256sum", "rm", "mkdir"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not found", tool)
+		}
+	}
+	var mu sync.Mutex
+	var commands []string
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		mu.Lock()
+		commands = append(commands, k.Statement.Command)
+		mu.Unlock()
+		cmd := exec.CommandContext(k.Statement.Context, k.Statement.Command, k.Statement.Args...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = opts.Stdin, opts.Stdout, opts.Stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return utilexec.CodeExitError{Err: err, Code: exitErr.ExitCode()}
+		}
+		return err
+	})
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), commands...)
+	}
+}
+
+func filePod(k *Kubectl) *pod {
+	return k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app")
+}
+
+func TestPodListFiles(t *testing.T) {
+	k := RegisterFakeCluster("pod-file-list-cluster", runningPod("web-0", nil, "app"))
+	commands := fakeLocalContainer(t, k)
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "a file.txt"), []byte("hello"), 0640)
+	_ = os.WriteFile(filepath.Join(dir, "line\nbreak"), nil, 0644)
+	_ = os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0644)
+	_ = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	_ = os.Symlink("a file.txt", filepath.Join(dir, "link"))
+
+	files, err := filePod(k).ListFiles(dir)
+	if err != nil {
+		t.Fatalf("list files failed: %v", err)
+	}
+	var names []string
+	byName := map[string]*FileInfo{}
+	for _, f := range files {
+		names = append(names, f.Name)
+		byName[f.Name] = f
+	}
+	if strings.Join(names, "|") != "a file.txt|line\nbreak|link|sub" {
+		t.Fatalf("unexpected files %q", names)
+	}
+	if f := byName["a file.txt"]; f.Size != 5 || f.Type != "file" || f.Permissions != "-rw-r-----" || f.Path != filepath.Join(dir, "a file.txt") || f.ModifiedAt.IsZero() {
+		t.Errorf("unexpected file info %+v", f)
+	}
+	if f := byName["sub"]; !f.IsDir || f.Type != "directory" || f.Permissions != "drwxr-xr-x" {
+		t.Errorf("unexpected directory info %+v", f)
+	}
+	if f := byName["link"]; f.Type != "link" || f.LinkTarget != "a file.txt" || f.Permissions[0] != 'l' {
+		t.Errorf("unexpected link info %+v", f)
+	}
+
+	files, err = filePod(k).ListAllFiles(dir)
+	if err != nil || len(files) != 5 || files[0].Name != ".hidden" {
+		t.Errorf("hidden files should be listed: %v %v", files, err)
+	}
+
+	info, err := filePod(k).StatFile(filepath.Join(dir, "link"))
+	if err != nil || info.LinkTarget != "a file.txt" {
+		t.Errorf("unexpected stat result %+v %v", info, err)
+	}
+	if _, err = filePod(k).StatFile(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+	if _, err = filePod(k).ListFiles(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("expected error for missing directory")
+	}
+	for _, c := range commands() {
+		if c == "sh" || c == "ls" {
+			t.Errorf("command %s should not be used", c)
+		}
+	}
+}
+
+func TestPodUploadDownloadFile(t *testing.T) {
+	k := RegisterFakeCluster("pod-file-transfer-cluster", runningPod("web-0", nil, "app"))
+	fakeLocalContainer(t, k)
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789"), 100000)
+
+	dest := filepath.Join(dir, "data.bin")
+	if err := filePod(k).UploadFileFrom(dest, bytes.NewReader(content), -1, FileMode(0600), FileVerifyChecksum()); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	stat, err := os.Stat(dest)
+	if err != nil || stat.Mode().Perm() != 0600 || stat.Size() != int64(len(content)) {
+		t.Fatalf("unexpected uploaded file %v %v", stat, err)
+	}
+
+	var buf bytes.Buffer
+	if err = filePod(k).DownloadFileTo(dest, &buf, FileVerifyChecksum()); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("downloaded content mismatch, got %d bytes", buf.Len())
+	}
+
+	// 路径不经过 shell 解析
+	name := filepath.Join(dir, "x; touch injected")
+	if err = filePod(k).SaveFile(name, "safe"); err != nil {
+		t.Fatalf("save file failed: %v", err)
+	}
+	data, err := filePod(k).DownloadFile(name)
+	if err != nil || string(data) != "safe" {
+		t.Errorf("unexpected saved content %q %v", data, err)
+	}
+	if _, err = os.Stat("injected"); err == nil {
+		_ = os.Remove("injected")
+		t.Errorf("path should not be interpreted by shell")
+	}
+
+	local, _ := os.CreateTemp(t.TempDir(), "local-*.txt")
+	_, _ = local.WriteString("from os.File")
+	_, _ = local.Seek(0, 0)
+	if err = filePod(k).UploadFile(dir, local); err != nil {
+		t.Fatalf("upload os.File failed: %v", err)
+	}
+	if data, _ = os.ReadFile(filepath.Join(dir, filepath.Base(local.Name()))); string(data) != "from os.File" {
+		t.Errorf("unexpected uploaded os.File content %q", data)
+	}
+
+	if _, err = filePod(k).DownloadFile(dir); err == nil {
+		t.Errorf("expected error when downloading a directory")
+	}
+	if _, err = filePod(k).DownloadFile(filepath.Join(dir, "missing")); err == nil || !strings.Contains(err.Error(), "exited with code") {
+		t.Errorf("expected tar error for missing file, got %v", err)
+	}
+
+	if _, err = filePod(k).DeleteFile(name); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err = os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("file should be deleted, got %v", err)
+	}
+}
+
+func TestPodUploadDownloadDir(t *testing.T) {
+	k := RegisterFakeCluster("pod-file-dir-cluster", runningPod("web-0", nil, "app"))
+	fakeLocalContainer(t, k)
+	src := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(src, "conf", "nested dir"), 0755)
+	_ = os.WriteFile(filepath.Join(src, "conf", "app.yaml"), []byte("a: 1"), 0644)
+	_ = os.WriteFile(filepath.Join(src, "conf", "nested dir", "b.txt"), []byte("b"), 0644)
+	_ = os.WriteFile(filepath.Join(src, "run.sh"), []byte("#!/bin/sh"), 0755)
+
+	remote := filepath.Join(t.TempDir(), "new", "app")
+	if err := filePod(k).UploadDir(src, remote, FileVerifyChecksum()); err != nil {
+		t.Fatalf("upload dir failed: %v", err)
+	}
+	if stat, err := os.Stat(filepath.Join(remote, "run.sh")); err != nil || stat.Mode().Perm() != 0755 {
+		t.Errorf("unexpected uploaded script %v %v", stat, err)
+	}
+
+	local := t.TempDir()
+	if err := filePod(k).DownloadDir(remote, local, FileVerifyChecksum()); err != nil {
+		t.Fatalf("download dir failed: %v", err)
+	}
+	for name, expected := range map[string]string{"conf/app.yaml": "a: 1", "conf/nested dir/b.txt": "b", "run.sh": "#!/bin/sh"} {
+		if data, err := os.ReadFile(filepath.Join(local, name)); err != nil || string(data) != expected {
+			t.Errorf("unexpected %s content %q %v", name, data, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := filePod(k).DownloadTarTo(remote, &buf); err != nil || buf.Len() == 0 {
+		t.Errorf("download tar failed: %v", err)
+	}
+}
+
+func TestPodFileChecksumMismatch(t *testing.T) {
+	k := RegisterFakeCluster("pod-file-checksum-cluster", runningPod("web-0", nil, "app"))
+	fakeLocalContainer(t, k)
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other.txt")
+	_ = os.WriteFile(other, []byte("changed"), 0644)
+	// 模拟传输过程中内容被改变，容器内计算的是另一个文件的 sha256
+	_ = k.Callback().StreamExec().Before("test:stream-exec").Register("test:checksum", func(k *Kubectl) error {
+		if k.Statement.Command == "sha256sum" {
+			k.Statement.Args = []string{"--", other}
+		}
+		return nil
+	})
+
+	dest := filepath.Join(dir, "data.txt")
+	err := filePod(k).UploadFileFrom(dest, strings.NewReader("data"), 4, FileVerifyChecksum())
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
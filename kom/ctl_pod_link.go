@@ -460,6 +460,13 @@ func (p *pod) LinkedEnv() ([]*Env, error) {
 			return nil, err
 		}
 
+		// 来源于 Secret 的环境变量按策略脱敏
+		isSecretEnv := func(name string) bool { return false }
+		policy := p.kubectl.secretRedactPolicy()
+		if policy != nil {
+			isSecretEnv = p.secretEnvMatcher(container, policy)
+		}
+
 		// 解析result，获取ENV名称和ENV值
 		envArrays := strings.Split(string(result), "\n")
 		for _, envline := range envArrays {
@@ -467,6 +474,9 @@ func (p *pod) LinkedEnv() ([]*Env, error) {
 			if len(envArray) != 2 {
 				continue
 			}
+			if isSecretEnv(envArray[0]) {
+				envArray[1] = policy.mask()
+			}
 			envs = append(envs, &Env{ContainerName: container.Name, EnvName: envArray[0], EnvValue: envArray[1]})
 		}
 	}
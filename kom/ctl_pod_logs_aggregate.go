@@ -0,0 +1,540 @@
+package kom
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// logAggregateResyncPeriod 跟踪日志时定期重新获取 Pod 的间隔，作为 Watch 事件处理的兜底
+var logAggregateResyncPeriod = time.Minute
+
+// LogRecord 聚合日志中的一行
+type LogRecord struct {
+	Namespace string    `json:"namespace"`
+	Pod       string    `json:"pod"`
+	Container string    `json:"container"`
+	Time      time.Time `json:"time"` // 日志时间，来自 API Server 记录的时间戳
+	Line      string    `json:"line"` // 日志内容，不含换行符
+}
+
+// String 输出带前缀的文本，格式为 [pod/container] line
+func (r *LogRecord) String() string {
+	return fmt.Sprintf("[%s/%s] %s", r.Pod, r.Container, r.Line)
+}
+
+// AggregateLogOption AggregateLogs 配置项
+type AggregateLogOption func(*aggregateLogOptions)
+
+type aggregateLogOptions struct {
+	logOptions  v1.PodLogOptions    // SinceSeconds、TailLines 等日志参数
+	containers  map[string]struct{} // 只获取指定的容器，为空时获取全部容器
+	follow      bool                // 持续跟踪日志及新出现的 Pod
+	orderWindow time.Duration       // 排序窗口，窗口内的日志按时间排序后输出
+}
+
+// AggregateLogPodLogOptions 设置获取日志的参数，如 SinceSeconds、TailLines、Previous，Container、Follow、Timestamps 不生效
+func AggregateLogPodLogOptions(opt *v1.PodLogOptions) AggregateLogOption {
+	return func(o *aggregateLogOptions) {
+		if opt != nil {
+			o.logOptions = *opt
+		}
+	}
+}
+
+// AggregateLogContainers 只获取指定名称的容器日志
+func AggregateLogContainers(names ...string) AggregateLogOption {
+	return func(o *aggregateLogOptions) {
+		for _, name := range names {
+			o.containers[name] = struct{}{}
+		}
+	}
+}
+
+// AggregateLogNoFollow 只获取当前已有的日志，获取完毕后结束，不跟踪新日志及新出现的 Pod
+func AggregateLogNoFollow() AggregateLogOption {
+	return func(o *aggregateLogOptions) {
+		o.follow = false
+	}
+}
+
+// AggregateLogOrderWindow 设置排序窗口，默认 500ms
+// 各容器的日志先缓存一个窗口的时间，再按时间顺序输出，窗口越大顺序越准确，延迟也越大
+func AggregateLogOrderWindow(d time.Duration) AggregateLogOption {
+	return func(o *aggregateLogOptions) {
+		o.orderWindow = d
+	}
+}
+
+// LogAggregator 聚合多个 Pod、多个容器的日志
+type LogAggregator struct {
+	kubectl   *Kubectl
+	namespace string
+	options   *aggregateLogOptions
+	listPods  func() ([]*v1.Pod, error) // 获取当前匹配的 Pod
+	selector  string                    // Watch Pod 时使用的标签选择器
+	field     string                    // Watch Pod 时使用的字段选择器
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	in      chan *LogRecord
+	out     chan *LogRecord
+	streams sync.WaitGroup
+	done    chan struct{}
+
+	mu        sync.Mutex
+	following map[string]struct{} // 正在跟踪的容器，key 为 podUID/容器名/重启次数
+	pods      map[types.UID]bool  // Watch 到的 Pod，true 表示在获取范围内
+	errs      []error
+}
+
+// AggregateLogs 同时获取多个 Pod、多个容器（包括 Init 容器及临时容器）的日志，合并为一个按时间排序的日志流
+// 获取范围由当前的查询条件决定：
+//
+// Deployment、StatefulSet、DaemonSet、ReplicaSet：通过 ManagedPods 获取其管理的 Pod
+// Pod：指定了 Name 时为单个 Pod，否则为 WithLabelSelector、Where 等条件匹配的 Pod
+//
+// 默认持续跟踪，通过 Watch 发现新出现的 Pod 及新启动的容器并自动跟踪，调用 Stop 结束。
+//
+// Example:
+// agg, err := kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("default").WithLabelSelector("app=web").AggregateLogs()
+// agg, err := kom.DefaultCluster().Resource(&appsv1.Deployment{}).Namespace("default").Name("web").AggregateLogs()
+//
+//	for record := range agg.Records() {
+//		fmt.Println(record.String())
+//	}
+func (k *Kubectl) AggregateLogs(opts ...AggregateLogOption) (*LogAggregator, error) {
+	options := &aggregateLogOptions{
+		containers:  map[string]struct{}{},
+		follow:      true,
+		orderWindow: 500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+
+	a := &LogAggregator{
+		kubectl:   k,
+		namespace: k.Statement.Namespace,
+		options:   options,
+		in:        make(chan *LogRecord, 256),
+		out:       make(chan *LogRecord, 256),
+		done:      make(chan struct{}),
+		following: map[string]struct{}{},
+		pods:      map[types.UID]bool{},
+	}
+	if a.namespace == "" {
+		a.namespace = metav1.NamespaceDefault
+	}
+	a.ctx, a.cancel = context.WithCancel(k.Statement.Context)
+	if err := a.resolveScope(); err != nil {
+		a.cancel()
+		return nil, err
+	}
+	pods, err := a.listPods()
+	if err != nil {
+		a.cancel()
+		return nil, err
+	}
+
+	go a.merge()
+	a.attach(pods)
+	if options.follow {
+		a.streams.Add(1)
+		go a.watchPods()
+	}
+	go func() {
+		a.streams.Wait()
+		close(a.in)
+	}()
+	return a, nil
+}
+
+// resolveScope 根据查询条件确定获取 Pod 的方式
+// listPods 会在 Watch 协程中调用，只能使用复制出的查询条件及新的实例，不能使用调用方的 Statement
+func (a *LogAggregator) resolveScope() error {
+	k := a.kubectl
+	stmt := k.Statement
+	switch stmt.GVK.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+		if stmt.Name == "" {
+			return fmt.Errorf("aggregate logs: %s name is required", stmt.GVK.Kind)
+		}
+		var workload *unstructured.Unstructured
+		if err := k.newInstance().WithContext(a.ctx).GVK(stmt.GVK.Group, stmt.GVK.Version, stmt.GVK.Kind).
+			Namespace(a.namespace).Name(stmt.Name).Get(&workload).Error; err != nil {
+			return err
+		}
+		matchLabels, _, _ := unstructured.NestedStringMap(workload.Object, "spec", "selector", "matchLabels")
+		a.selector = labels.SelectorFromSet(matchLabels).String()
+		gvk, name := stmt.GVK, stmt.Name
+		a.listPods = func() ([]*v1.Pod, error) {
+			ctl := k.newInstance().WithContext(a.ctx).GVK(gvk.Group, gvk.Version, gvk.Kind).Namespace(a.namespace).Name(name).Ctl()
+			switch gvk.Kind {
+			case "Deployment":
+				return ctl.Deployment().ManagedPods()
+			case "StatefulSet":
+				return ctl.StatefulSet().ManagedPods()
+			case "DaemonSet":
+				return ctl.DaemonSet().ManagedPods()
+			default:
+				return ctl.ReplicaSet().ManagedPods()
+			}
+		}
+	case "Pod", "":
+		if stmt.Name != "" {
+			name := stmt.Name
+			a.listPods = func() ([]*v1.Pod, error) {
+				var item v1.Pod
+				err := k.newInstance().WithContext(a.ctx).Resource(&item).Namespace(a.namespace).Name(name).Get(&item).Error
+				if err != nil {
+					return nil, err
+				}
+				return []*v1.Pod{&item}, nil
+			}
+			a.selector = ""
+			a.field = "metadata.name=" + name
+			return nil
+		}
+		if len(stmt.ListOptions) > 0 {
+			a.selector = stmt.ListOptions[0].LabelSelector
+		}
+		listOptions := append([]metav1.ListOptions(nil), stmt.ListOptions...)
+		filter := stmt.Filter
+		a.listPods = func() ([]*v1.Pod, error) {
+			var pods []*v1.Pod
+			tx := k.newInstance().WithContext(a.ctx).Resource(&v1.Pod{}).Namespace(a.namespace)
+			tx.Statement.ListOptions = listOptions
+			tx.Statement.Filter = filter
+			err := tx.List(&pods).Error
+			return pods, err
+		}
+	default:
+		return fmt.Errorf("aggregate logs: unsupported kind %s", stmt.GVK.Kind)
+	}
+	return nil
+}
+
+// Records 返回按时间排序的日志，结束后关闭
+func (a *LogAggregator) Records() <-chan *LogRecord {
+	return a.out
+}
+
+// Reader 返回带 [pod/container] 前缀的文本日志流
+func (a *LogAggregator) Reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		for record := range a.out {
+			if _, err := io.WriteString(pw, record.String()+"\n"); err != nil {
+				a.Stop()
+				break
+			}
+		}
+		_ = pw.Close()
+	}()
+	return pr
+}
+
+// Stop 停止获取日志并关闭 Records，排序窗口中尚未输出的日志会被丢弃
+func (a *LogAggregator) Stop() {
+	a.cancel()
+}
+
+// Done 全部日志输出完毕后关闭
+func (a *LogAggregator) Done() <-chan struct{} {
+	return a.done
+}
+
+// Errors 返回获取日志过程中出现的错误，单个容器的错误不影响其他容器
+func (a *LogAggregator) Errors() []error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]error(nil), a.errs...)
+}
+
+func (a *LogAggregator) addError(err error) {
+	if a.ctx.Err() != nil {
+		return
+	}
+	a.mu.Lock()
+	a.errs = append(a.errs, err)
+	a.mu.Unlock()
+}
+
+// watchPods 监听 Pod 变化，发现新的 Pod 或新启动的容器时开始跟踪
+func (a *LogAggregator) watchPods() {
+	defer a.streams.Done()
+	for a.ctx.Err() == nil {
+		var watcher watch.Interface
+		opts := metav1.ListOptions{LabelSelector: a.selector, FieldSelector: a.field}
+		err := a.kubectl.newInstance().WithContext(a.ctx).Resource(&v1.Pod{}).Namespace(a.namespace).Watch(&watcher, opts).Error
+		if err != nil || watcher == nil {
+			a.addError(fmt.Errorf("watch pods error: %v", err))
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-time.After(readyResyncPeriod):
+				continue
+			}
+		}
+		a.consumeEvents(watcher)
+		watcher.Stop()
+		// Watch 结束后重新获取一次，避免遗漏
+		a.refresh()
+	}
+}
+
+func (a *LogAggregator) consumeEvents(watcher watch.Interface) {
+	resync := time.NewTicker(logAggregateResyncPeriod)
+	defer resync.Stop()
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-resync.C:
+			a.refresh()
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			a.handleEvent(event)
+		}
+	}
+}
+
+// handleEvent 根据事件中的 Pod 跟踪新启动的容器，只有首次出现的 Pod 才重新获取，确认是否在获取范围内
+func (a *LogAggregator) handleEvent(event watch.Event) {
+	obj, ok := event.Object.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	var pod v1.Pod
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod); err != nil {
+		a.addError(fmt.Errorf("convert pod %s error: %v", obj.GetName(), err))
+		return
+	}
+	switch event.Type {
+	case watch.Deleted:
+		a.detach(pod.UID)
+	case watch.Added, watch.Modified:
+		a.mu.Lock()
+		matched, known := a.pods[pod.UID]
+		a.mu.Unlock()
+		if known {
+			if matched {
+				a.attach([]*v1.Pod{&pod})
+			}
+			return
+		}
+		// 标签匹配的 Pod 不一定由工作负载管理，重新获取一次，不在范围内的 Pod 直到下次定期获取前不再处理
+		a.refresh()
+		a.mu.Lock()
+		if _, ok := a.pods[pod.UID]; !ok {
+			a.pods[pod.UID] = false
+		}
+		a.mu.Unlock()
+	}
+}
+
+// detach 删除 Pod 的跟踪记录，Pod 删除后日志流会自行结束
+func (a *LogAggregator) detach(uid types.UID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.pods, uid)
+	prefix := string(uid) + "/"
+	for key := range a.following {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.following, key)
+		}
+	}
+}
+
+// refresh 重新获取匹配的 Pod 并跟踪新的容器
+func (a *LogAggregator) refresh() {
+	pods, err := a.listPods()
+	if err != nil {
+		a.addError(err)
+		return
+	}
+	a.attach(pods)
+}
+
+// attach 跟踪已启动、尚未跟踪的容器
+func (a *LogAggregator) attach(pods []*v1.Pod) {
+	for _, pod := range pods {
+		a.mu.Lock()
+		a.pods[pod.UID] = true
+		a.mu.Unlock()
+		for _, status := range podContainerStatuses(pod) {
+			if len(a.options.containers) > 0 {
+				if _, ok := a.options.containers[status.Name]; !ok {
+					continue
+				}
+			}
+			if status.State.Running == nil && status.State.Terminated == nil {
+				// 未启动的容器没有日志，等待后续事件
+				continue
+			}
+			key := fmt.Sprintf("%s/%s/%d", pod.UID, status.Name, status.RestartCount)
+			a.mu.Lock()
+			_, ok := a.following[key]
+			if !ok {
+				a.following[key] = struct{}{}
+			}
+			a.mu.Unlock()
+			if ok || a.ctx.Err() != nil {
+				continue
+			}
+			a.streams.Add(1)
+			go a.stream(pod.Name, status.Name)
+		}
+	}
+}
+
+// podContainerStatuses 返回 Init 容器、普通容器及临时容器的状态
+func podContainerStatuses(pod *v1.Pod) []v1.ContainerStatus {
+	var statuses []v1.ContainerStatus
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	statuses = append(statuses, pod.Status.EphemeralContainerStatuses...)
+	return statuses
+}
+
+// stream 读取单个容器的日志
+func (a *LogAggregator) stream(podName, container string) {
+	defer a.streams.Done()
+	opt := a.options.logOptions
+	opt.Follow = a.options.follow
+	opt.Timestamps = true
+
+	var stream io.ReadCloser
+	err := a.kubectl.newInstance().WithContext(a.ctx).Resource(&v1.Pod{}).Namespace(a.namespace).Name(podName).
+		Ctl().Pod().ContainerName(container).GetLogs(&stream, &opt).Error
+	if err != nil {
+		a.addError(fmt.Errorf("get logs %s/%s error: %w", podName, container, err))
+		return
+	}
+	if stream == nil {
+		return
+	}
+	defer stream.Close()
+	go func() {
+		// 停止时关闭日志流，结束阻塞的读取
+		<-a.ctx.Done()
+		stream.Close()
+	}()
+
+	reader := bufio.NewReader(stream)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			record := parseLogLine(line)
+			record.Namespace, record.Pod, record.Container = a.namespace, podName, container
+			select {
+			case a.in <- record:
+			case <-a.ctx.Done():
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				a.addError(fmt.Errorf("read logs %s/%s error: %w", podName, container, err))
+			}
+			klog.V(6).Infof("logs of %s/%s finished: %v", podName, container, err)
+			return
+		}
+	}
+}
+
+// parseLogLine 解析 API Server 添加的 RFC3339 时间戳
+func parseLogLine(line string) *LogRecord {
+	if t, rest, ok := splitLogTimestamp(line); ok {
+		return &LogRecord{Time: t, Line: rest}
+	}
+	return &LogRecord{Time: time.Now(), Line: strings.TrimRight(line, "\r\n")}
+}
+
+// splitLogTimestamp 拆分 Timestamps=true 时行首的 RFC3339 时间戳，不含时间戳时返回 false
+func splitLogTimestamp(line string) (time.Time, string, bool) {
+	line = strings.TrimRight(line, "\r\n")
+	if ts, rest, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t, rest, true
+		}
+	}
+	return time.Time{}, line, false
+}
+
+// merge 在排序窗口内按时间排序后输出，Stop 后丢弃未输出的日志
+func (a *LogAggregator) merge() {
+	defer close(a.done)
+	defer a.cancel()
+	defer close(a.out)
+	var pending logRecordHeap
+	ticker := time.NewTicker(a.options.orderWindow/4 + time.Millisecond)
+	defer ticker.Stop()
+	flush := func(before time.Time) bool {
+		for pending.Len() > 0 && (before.IsZero() || !pending[0].received.After(before)) {
+			select {
+			case a.out <- heap.Pop(&pending).(*pendingRecord).LogRecord:
+			case <-a.ctx.Done():
+				return false
+			}
+		}
+		return true
+	}
+	for {
+		select {
+		case record, ok := <-a.in:
+			if !ok {
+				flush(time.Time{})
+				return
+			}
+			heap.Push(&pending, &pendingRecord{LogRecord: record, received: time.Now()})
+		case <-ticker.C:
+			if !flush(time.Now().Add(-a.options.orderWindow)) {
+				return
+			}
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+type pendingRecord struct {
+	*LogRecord
+	received time.Time // 进入排序窗口的时间
+}
+
+// logRecordHeap 按日志时间排序的小顶堆
+type logRecordHeap []*pendingRecord
+
+func (h logRecordHeap) Len() int           { return len(h) }
+func (h logRecordHeap) Less(i, j int) bool { return h[i].Time.Before(h[j].Time) }
+func (h logRecordHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *logRecordHeap) Push(x interface{}) {
+	*h = append(*h, x.(*pendingRecord))
+}
+func (h *logRecordHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
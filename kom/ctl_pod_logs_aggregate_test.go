@@ -0,0 +1,198 @@
+package kom
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// fakePodLogs 按 pod/container 返回预设的日志
+func fakePodLogs(k *Kubectl, logs map[string]string) *sync.Map {
+	requested := &sync.Map{}
+	_ = k.Callback().Logs().Before("fake:logs").Register("test:logs", func(k *Kubectl) error {
+		key := k.Statement.Name + "/" + k.Statement.ContainerName
+		requested.Store(key, *k.Statement.PodLogOptions)
+		if dest, ok := k.Statement.Dest.(*io.ReadCloser); ok {
+			*dest = io.NopCloser(strings.NewReader(logs[key]))
+		}
+		return nil
+	})
+	return requested
+}
+
+func runningPod(name string, labels map[string]string, containers ...string) *v1.Pod {
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels, UID: k8stypes.UID("uid-" + name)}}
+	for i, c := range containers {
+		status := v1.ContainerStatus{Name: c, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}
+		if i == 0 && strings.HasPrefix(c, "init") {
+			pod.Status.InitContainerStatuses = append(pod.Status.InitContainerStatuses, status)
+			continue
+		}
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, status)
+	}
+	return pod
+}
+
+func TestAggregateLogsBySelector(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	k := RegisterFakeCluster("aggregate-logs-cluster",
+		runningPod("web-1", web, "init-db", "app"),
+		runningPod("web-2", web, "app"),
+		runningPod("other", map[string]string{"app": "other"}, "app"),
+	)
+	requested := fakePodLogs(k, map[string]string{
+		"web-1/init-db": "2024-01-01T00:00:01Z init done\n",
+		"web-1/app":     "2024-01-01T00:00:02Z web-1 started\n2024-01-01T00:00:05Z web-1 ready\n",
+		"web-2/app":     "2024-01-01T00:00:03Z web-2 started\n2024-01-01T00:00:04Z web-2 ready",
+		"other/app":     "2024-01-01T00:00:00Z should not appear\n",
+	})
+
+	agg, err := k.Resource(&v1.Pod{}).Namespace("default").WithLabelSelector("app=web").
+		AggregateLogs(AggregateLogNoFollow(), AggregateLogPodLogOptions(&v1.PodLogOptions{TailLines: func() *int64 { i := int64(10); return &i }()}))
+	if err != nil {
+		t.Fatalf("aggregate logs failed: %v", err)
+	}
+	var lines []string
+	for r := range agg.Records() {
+		lines = append(lines, r.String())
+	}
+	want := []string{
+		"[web-1/init-db] init done",
+		"[web-1/app] web-1 started",
+		"[web-2/app] web-2 started",
+		"[web-2/app] web-2 ready",
+		"[web-1/app] web-1 ready",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected lines:\n%s", strings.Join(lines, "\n"))
+	}
+	if errs := agg.Errors(); len(errs) > 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	v, ok := requested.Load("web-1/app")
+	if !ok {
+		t.Fatalf("logs of web-1/app should be requested")
+	}
+	opt := v.(v1.PodLogOptions)
+	if !opt.Timestamps || opt.Follow || opt.TailLines == nil || *opt.TailLines != 10 {
+		t.Errorf("unexpected log options %+v", opt)
+	}
+}
+
+func TestAggregateLogsFollowNewPods(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	k := RegisterFakeCluster("aggregate-logs-follow-cluster", runningPod("web-1", web, "app"))
+	fakePodLogs(k, map[string]string{
+		"web-1/app": "2024-01-01T00:00:01Z first\n",
+		"web-2/app": "2024-01-01T00:00:02Z second\n",
+	})
+
+	query := k.Resource(&v1.Pod{}).Namespace("default").WithLabelSelector("app=web")
+	agg, err := query.AggregateLogs(AggregateLogOrderWindow(10 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("aggregate logs failed: %v", err)
+	}
+	next := func() string {
+		select {
+		case r := <-agg.Records():
+			return r.String()
+		case <-time.After(3 * time.Second):
+			return "timeout"
+		}
+	}
+	if line := next(); line != "[web-1/app] first" {
+		t.Fatalf("unexpected line %s", line)
+	}
+
+	// 新出现的 Pod 自动跟踪
+	pod := runningPod("web-2", web, "app")
+	if err := k.Resource(pod).Create(pod).Error; err != nil {
+		t.Fatalf("create pod failed: %v", err)
+	}
+	if line := next(); line != "[web-2/app] second" {
+		t.Fatalf("unexpected line %s", line)
+	}
+	// 后台获取 Pod 不使用调用方的 Statement
+	if query.Statement.Dest != nil || query.Statement.RowsAffected != 0 {
+		t.Errorf("caller statement should not be used by the aggregator, dest %v", query.Statement.Dest)
+	}
+
+	agg.Stop()
+	select {
+	case <-agg.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatalf("aggregator should stop")
+	}
+}
+
+func TestAggregateLogsWatchEvents(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	k := RegisterFakeCluster("aggregate-logs-events-cluster", runningPod("web-1", web, "app"))
+	fakePodLogs(k, map[string]string{"web-1/app": "2024-01-01T00:00:01Z first\n"})
+	var lists sync.Map
+	_ = k.Callback().List().Before("fake:list").Register("test:count-list", func(k *Kubectl) error {
+		n, _ := lists.LoadOrStore("pods", new(int))
+		*n.(*int)++
+		return nil
+	})
+	listCount := func() int {
+		n, _ := lists.Load("pods")
+		return *n.(*int)
+	}
+
+	agg, err := k.Resource(&v1.Pod{}).Namespace("default").WithLabelSelector("app=web").
+		AggregateLogs(AggregateLogOrderWindow(10 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("aggregate logs failed: %v", err)
+	}
+	defer agg.Stop()
+	next := func() string {
+		select {
+		case r := <-agg.Records():
+			return r.String()
+		case <-time.After(3 * time.Second):
+			return "timeout"
+		}
+	}
+	if line := next(); line != "[web-1/app] first" {
+		t.Fatalf("unexpected line %s", line)
+	}
+	before := listCount()
+
+	// 已知 Pod 的容器重启后根据事件直接跟踪，不重新获取 Pod 列表
+	pod := runningPod("web-1", web, "app")
+	pod.Status.ContainerStatuses[0].RestartCount = 1
+	if err := k.Resource(pod).Update(pod).Error; err != nil {
+		t.Fatalf("update pod failed: %v", err)
+	}
+	if line := next(); line != "[web-1/app] first" {
+		t.Fatalf("restarted container should be followed, got %s", line)
+	}
+	if n := listCount(); n != before {
+		t.Errorf("modified event of a known pod should not list pods, got %d lists", n-before)
+	}
+
+	// Pod 删除后清理跟踪记录
+	if err := k.Resource(pod).Namespace("default").Name("web-1").Delete().Error; err != nil {
+		t.Fatalf("delete pod failed: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		agg.mu.Lock()
+		n := len(agg.following) + len(agg.pods)
+		agg.mu.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deleted pod should be detached, %d entries left", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
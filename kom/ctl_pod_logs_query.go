@@ -0,0 +1,486 @@
+package kom
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LogLine 日志查询结果中的一行
+type LogLine struct {
+	Number  int                    `json:"number"`           // 在获取到的日志中的行号，从 1 开始
+	Time    time.Time              `json:"time"`             // 日志时间，来自 API Server 记录的时间戳，缺少时间戳时为零值
+	Line    string                 `json:"line"`             // 日志内容，不含时间戳及换行符
+	Fields  map[string]interface{} `json:"fields,omitempty"` // JSON 格式日志解析后的字段
+	Context bool                   `json:"context"`          // 是否为上下文行，false 表示匹配的行
+}
+
+// LogQueryResult 日志查询结果
+type LogQueryResult struct {
+	Lines     []*LogLine `json:"lines"`
+	Scanned   int        `json:"scanned"`   // 获取到的日志行数
+	Matched   int        `json:"matched"`   // 匹配的行数，不含上下文行
+	Truncated bool       `json:"truncated"` // 是否因 LimitBytes 被截断
+
+	separated bool // 是否在不连续的片段之间输出分隔符
+}
+
+// String 输出日志文本，开启上下文时不连续的片段之间以 -- 分隔，与 grep 一致
+func (r *LogQueryResult) String() string {
+	var sb strings.Builder
+	for i, line := range r.Lines {
+		if r.separated && i > 0 && line.Number > r.Lines[i-1].Number+1 {
+			sb.WriteString("--\n")
+		}
+		sb.WriteString(line.Line)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// LogQueryOption QueryLogs 配置项
+type LogQueryOption func(*logQueryOptions)
+
+type logQueryOptions struct {
+	logOptions v1.PodLogOptions
+	until      time.Time
+	include    []string
+	exclude    []string
+	before     int
+	after      int
+	parseJSON  bool
+	where      string
+	whereArgs  []interface{}
+}
+
+// LogQuerySince 获取最近一段时间的日志，由 API Server 过滤，精度为秒
+func LogQuerySince(d time.Duration) LogQueryOption {
+	return func(o *logQueryOptions) {
+		seconds := int64(math.Ceil(d.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		o.logOptions.SinceSeconds = &seconds
+		o.logOptions.SinceTime = nil
+	}
+}
+
+// LogQuerySinceTime 获取指定时间之后的日志，由 API Server 过滤，精度为秒
+func LogQuerySinceTime(t time.Time) LogQueryOption {
+	return func(o *logQueryOptions) {
+		since := metav1.NewTime(t)
+		o.logOptions.SinceTime = &since
+		o.logOptions.SinceSeconds = nil
+	}
+}
+
+// LogQueryUntil 只保留指定时间及之前的日志，API Server 不支持该参数，在读取时按时间戳过滤
+func LogQueryUntil(t time.Time) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.until = t
+	}
+}
+
+// LogQueryTailLines 获取末尾的行数，由 API Server 截取，在过滤之前生效
+func LogQueryTailLines(n int64) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.logOptions.TailLines = &n
+	}
+}
+
+// LogQueryPrevious 获取上一个容器实例的日志，用于排查容器重启
+func LogQueryPrevious() LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.logOptions.Previous = true
+	}
+}
+
+// LogQueryLimitBytes 限制 API Server 返回的字节数
+func LogQueryLimitBytes(n int64) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.logOptions.LimitBytes = &n
+	}
+}
+
+// LogQueryInclude 只保留匹配任一正则表达式的行
+func LogQueryInclude(patterns ...string) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.include = append(o.include, patterns...)
+	}
+}
+
+// LogQueryExclude 排除匹配任一正则表达式的行
+func LogQueryExclude(patterns ...string) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// LogQueryContext 输出匹配行之前、之后的行数，与 grep -B、-A 一致
+func LogQueryContext(before, after int) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.before = before
+		o.after = after
+	}
+}
+
+// LogQueryParseJSON 将 JSON 格式的日志解析为字段，保存在 LogLine.Fields 中
+func LogQueryParseJSON() LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.parseJSON = true
+	}
+}
+
+// LogQueryWhere 按 JSON 日志的字段过滤，语法与 Where 一致，会同时开启 JSON 解析
+// 字段支持以 . 访问嵌套字段，非 JSON 格式的行不匹配
+//
+// Example:
+// LogQueryWhere("level = 'error' and latency > 500")
+// LogQueryWhere("http.status in (500, 502) or msg like '%timeout%'")
+// LogQueryWhere("user = ?", "admin")
+func LogQueryWhere(condition string, values ...interface{}) LogQueryOption {
+	return func(o *logQueryOptions) {
+		o.parseJSON = true
+		o.where = condition
+		o.whereArgs = values
+	}
+}
+
+// logQuery 编译后的查询条件
+type logQuery struct {
+	options    *logQueryOptions
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+	conditions []*Condition
+}
+
+// QueryLogs 查询 Pod 日志，在 GetLogs 的基础上支持时间窗口、上一个容器实例、正则过滤、上下文行及 JSON 字段过滤
+// 时间窗口、行数、字节数由 API Server 处理，Until 及各类过滤在读取时进行
+//
+// Example:
+// result, err := kom.DefaultCluster().Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").
+//
+//	QueryLogs(kom.LogQuerySince(time.Hour), kom.LogQueryInclude("(?i)error"), kom.LogQueryContext(2, 2))
+func (p *pod) QueryLogs(opts ...LogQueryOption) (*LogQueryResult, error) {
+	if p.Error != nil {
+		return nil, p.Error
+	}
+	if p.kubectl.Error != nil {
+		return nil, p.kubectl.Error
+	}
+	options := &logQueryOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	query, err := compileLogQuery(options)
+	if err != nil {
+		return nil, err
+	}
+
+	logOptions := options.logOptions
+	// 读取时需要时间戳，用于 Until 过滤及返回日志时间
+	logOptions.Timestamps = true
+	logOptions.Follow = false
+	var stream io.ReadCloser
+	if err = p.GetLogs(&stream, &logOptions).Error; err != nil {
+		return nil, err
+	}
+	if stream == nil {
+		return &LogQueryResult{}, nil
+	}
+	defer stream.Close()
+	return query.run(stream)
+}
+
+func compileLogQuery(options *logQueryOptions) (*logQuery, error) {
+	if options.before < 0 || options.after < 0 {
+		return nil, fmt.Errorf("context lines must not be negative")
+	}
+	query := &logQuery{options: options}
+	for _, pattern := range options.include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+		}
+		query.include = append(query.include, re)
+	}
+	for _, pattern := range options.exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+		query.exclude = append(query.exclude, re)
+	}
+	if strings.TrimSpace(options.where) != "" {
+		sql := fmt.Sprintf("select * from logs where ( %s )", formatSql(options.where, options.whereArgs))
+		conditions, err := parseWhereSql(sql)
+		if err != nil {
+			return nil, fmt.Errorf("invalid where condition %q: %w", options.where, err)
+		}
+		for _, c := range conditions {
+			// 与关键字同名的字段会被加上反引号，如 http.`status`
+			c.Field = strings.ReplaceAll(c.Field, "`", "")
+			c.Value = fmt.Sprintf("%v", c.Value)
+		}
+		query.conditions = conditions
+	}
+	return query, nil
+}
+
+// run 逐行读取日志并过滤，保留匹配行及其上下文
+func (q *logQuery) run(stream io.Reader) (*LogQueryResult, error) {
+	result := &LogQueryResult{separated: q.options.before > 0 || q.options.after > 0}
+	reader := bufio.NewReader(stream)
+	var (
+		read     int64
+		previous []*LogLine // 尚未输出的前置上下文行
+		after    int        // 还需输出的后置上下文行数
+	)
+	for {
+		text, err := reader.ReadString('\n')
+		read += int64(len(text))
+		if text != "" {
+			t, content, ok := splitLogTimestamp(text)
+			if ok && !q.options.until.IsZero() && t.After(q.options.until) {
+				// 日志按时间顺序输出，之后的行都不在时间窗口内
+				break
+			}
+			result.Scanned++
+			line := &LogLine{Number: result.Scanned, Time: t, Line: content}
+			if q.options.parseJSON {
+				line.Fields = parseLogFields(line.Line)
+			}
+			switch {
+			case q.match(line):
+				result.Lines = append(result.Lines, previous...)
+				result.Lines = append(result.Lines, line)
+				result.Matched++
+				previous = nil
+				after = q.options.after
+			case after > 0:
+				line.Context = true
+				result.Lines = append(result.Lines, line)
+				after--
+			case q.options.before > 0:
+				line.Context = true
+				previous = append(previous, line)
+				if len(previous) > q.options.before {
+					previous = previous[1:]
+				}
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return result, err
+		}
+	}
+	if limit := q.options.logOptions.LimitBytes; limit != nil && read >= *limit {
+		result.Truncated = true
+	}
+	return result, nil
+}
+
+// match 判断是否为匹配行：匹配任一 include、不匹配任何 exclude，并满足字段条件
+func (q *logQuery) match(line *LogLine) bool {
+	if len(q.include) > 0 {
+		matched := false
+		for _, re := range q.include {
+			if re.MatchString(line.Line) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range q.exclude {
+		if re.MatchString(line.Line) {
+			return false
+		}
+	}
+	if len(q.conditions) > 0 {
+		if line.Fields == nil {
+			return false
+		}
+		return matchLogConditions(line.Fields, q.conditions)
+	}
+	return true
+}
+
+// parseLogFields 解析 JSON 格式的日志，非 JSON 对象返回 nil
+func parseLogFields(line string) map[string]interface{} {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(trimmed), &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// matchLogConditions 与 Where 的处理一致：AND 条件需全部满足，OR 条件满足其一即可
+func matchLogConditions(fields map[string]interface{}, conditions []*Condition) bool {
+	hasOr, anyOr := false, false
+	for _, c := range conditions {
+		matched := matchLogCondition(fields, c)
+		if c.AndOr == "OR" {
+			hasOr = true
+			anyOr = anyOr || matched
+			continue
+		}
+		if !matched {
+			return false
+		}
+	}
+	return !hasOr || anyOr
+}
+
+// matchLogCondition 判断单个条件，比较时依次尝试数字、时间，最后按字符串不区分大小写比较
+func matchLogCondition(fields map[string]interface{}, c *Condition) bool {
+	raw, found := lookupLogField(fields, c.Field)
+	if !found {
+		return false
+	}
+	value := logFieldString(raw)
+	target := fmt.Sprintf("%v", c.Value)
+	switch strings.ToLower(c.Operator) {
+	case "=":
+		return compareLogValues(value, target) == 0
+	case "!=", "<>":
+		return compareLogValues(value, target) != 0
+	case ">":
+		return compareLogValues(value, target) > 0
+	case "<":
+		return compareLogValues(value, target) < 0
+	case ">=":
+		return compareLogValues(value, target) >= 0
+	case "<=":
+		return compareLogValues(value, target) <= 0
+	case "like":
+		return likeLogValue(value, target)
+	case "not like":
+		return !likeLogValue(value, target)
+	case "in":
+		return inLogValues(value, target)
+	case "not in":
+		return !inLogValues(value, target)
+	case "between":
+		return betweenLogValues(value, target)
+	case "not between":
+		return !betweenLogValues(value, target)
+	default:
+		return false
+	}
+}
+
+// lookupLogField 获取字段值，优先匹配完整的键名（如 log.level），再按 . 逐级访问嵌套字段
+func lookupLogField(fields map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := fields[path]; ok {
+		return v, true
+	}
+	head, rest, ok := strings.Cut(path, ".")
+	if !ok {
+		return nil, false
+	}
+	child, ok := fields[head].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookupLogField(child, rest)
+}
+
+func logFieldString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case nil:
+		return "null"
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// compareLogValues 比较两个值，返回 -1、0、1
+func compareLogValues(a, b string) int {
+	if x, err1 := strconv.ParseFloat(a, 64); err1 == nil {
+		if y, err2 := strconv.ParseFloat(b, 64); err2 == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	if x, err1 := utils.ParseTime(a); err1 == nil {
+		if y, err2 := utils.ParseTime(b); err2 == nil {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// likeLogValue 与 Where 的 like 一致，支持以 % 开头或结尾，不区分大小写
+func likeLogValue(value, pattern string) bool {
+	value = strings.ToLower(value)
+	val := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(pattern, "%"), "%"))
+	prefix, suffix := strings.HasPrefix(pattern, "%"), strings.HasSuffix(pattern, "%")
+	switch {
+	case prefix && suffix:
+		return strings.Contains(value, val)
+	case suffix:
+		return strings.HasPrefix(value, val)
+	case prefix:
+		return strings.HasSuffix(value, val)
+	default:
+		return value == val
+	}
+}
+
+// inLogValues 判断是否在 (a, b, c) 列表中
+func inLogValues(value, list string) bool {
+	list = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(list), "("), ")")
+	for _, item := range strings.Split(list, ",") {
+		if compareLogValues(value, utils.TrimQuotes(strings.TrimSpace(item))) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+var logBetweenRegexp = regexp.MustCompile(`(?i)^(.+?)\s+and\s+(.+)$`)
+
+// betweenLogValues 判断是否在 from and to 范围内，包含边界
+func betweenLogValues(value, rng string) bool {
+	m := logBetweenRegexp.FindStringSubmatch(strings.TrimSpace(rng))
+	if m == nil {
+		return false
+	}
+	from, to := utils.TrimQuotes(strings.TrimSpace(m[1])), utils.TrimQuotes(strings.TrimSpace(m[2]))
+	return compareLogValues(value, from) >= 0 && compareLogValues(value, to) <= 0
+}
@@ -0,0 +1,154 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const queryLogs = `2024-01-01T00:00:01Z starting
+2024-01-01T00:00:02Z {"level":"info","msg":"request","latency":120,"http":{"status":200}}
+2024-01-01T00:00:03Z connecting to db
+2024-01-01T00:00:04Z {"level":"error","msg":"db timeout","latency":900,"http":{"status":502}}
+2024-01-01T00:00:05Z retrying
+2024-01-01T00:00:06Z {"level":"warn","msg":"slow request","latency":600,"http":{"status":200}}
+2024-01-01T00:00:07Z ERROR connection refused
+2024-01-01T00:00:08Z shutting down
+`
+
+func queryLogLines(result *LogQueryResult) []string {
+	var lines []string
+	for _, l := range result.Lines {
+		lines = append(lines, l.Line)
+	}
+	return lines
+}
+
+func queryPod(k *Kubectl) *pod {
+	return k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app")
+}
+
+func TestQueryLogsServerOptions(t *testing.T) {
+	k := RegisterFakeCluster("query-logs-options-cluster", runningPod("web-0", nil, "app"))
+	requested := fakePodLogs(k, map[string]string{"web-0/app": queryLogs})
+
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result, err := k.Namespace("default").Name("web-0").Ctl().Pod().ContainerName("app").QueryLogs(
+		LogQuerySinceTime(since), LogQueryTailLines(50), LogQueryPrevious(), LogQueryLimitBytes(64))
+	if err != nil {
+		t.Fatalf("query logs failed: %v", err)
+	}
+	v, ok := requested.Load("web-0/app")
+	if !ok {
+		t.Fatalf("logs were not requested")
+	}
+	opt := v.(v1.PodLogOptions)
+	if opt.SinceTime == nil || !opt.SinceTime.Time.Equal(since) || opt.TailLines == nil || *opt.TailLines != 50 ||
+		!opt.Previous || opt.LimitBytes == nil || *opt.LimitBytes != 64 || !opt.Timestamps || opt.Container != "app" {
+		t.Errorf("unexpected pod log options: %+v", opt)
+	}
+	// fake 不处理 LimitBytes，返回的内容超过限制即视为截断
+	if !result.Truncated {
+		t.Errorf("expected result to be truncated")
+	}
+	if result.Scanned != 8 || result.Matched != 8 {
+		t.Errorf("expected 8 scanned and matched lines, got %d/%d", result.Scanned, result.Matched)
+	}
+	if got := result.Lines[0]; got.Line != "starting" || !got.Time.Equal(since.Add(time.Second)) {
+		t.Errorf("timestamp should be split from line, got %+v", got)
+	}
+}
+
+func TestQueryLogsUntil(t *testing.T) {
+	k := RegisterFakeCluster("query-logs-until-cluster", runningPod("web-0", nil, "app"))
+	fakePodLogs(k, map[string]string{"web-0/app": queryLogs})
+
+	until := time.Date(2024, 1, 1, 0, 0, 3, 0, time.UTC)
+	result, err := queryPod(k).QueryLogs(LogQueryUntil(until))
+	if err != nil {
+		t.Fatalf("query logs failed: %v", err)
+	}
+	if result.Scanned != 3 || result.Lines[2].Line != "connecting to db" {
+		t.Errorf("expected logs up to %s, got %v", until, queryLogLines(result))
+	}
+}
+
+func TestQueryLogsIncludeExcludeContext(t *testing.T) {
+	k := RegisterFakeCluster("query-logs-grep-cluster", runningPod("web-0", nil, "app"))
+	fakePodLogs(k, map[string]string{"web-0/app": queryLogs})
+	p := queryPod(k)
+
+	result, err := p.QueryLogs(LogQueryInclude("(?i)error", "timeout"), LogQueryExclude("refused"))
+	if err != nil {
+		t.Fatalf("query logs failed: %v", err)
+	}
+	if got := queryLogLines(result); len(got) != 1 || !strings.Contains(got[0], "db timeout") {
+		t.Errorf("unexpected include/exclude result: %v", got)
+	}
+
+	result, err = queryPod(k).QueryLogs(LogQueryInclude("^starting$", "ERROR"), LogQueryContext(1, 1))
+	if err != nil {
+		t.Fatalf("query logs failed: %v", err)
+	}
+	expected := "starting\n" +
+		`{"level":"info","msg":"request","latency":120,"http":{"status":200}}` + "\n" +
+		"--\n" +
+		`{"level":"warn","msg":"slow request","latency":600,"http":{"status":200}}` + "\n" +
+		"ERROR connection refused\n" +
+		"shutting down\n"
+	if got := result.String(); got != expected {
+		t.Errorf("unexpected context output:\n%s", got)
+	}
+	if result.Matched != 2 || !result.Lines[1].Context || result.Lines[3].Context {
+		t.Errorf("match and context flags are wrong: %+v", result.Lines)
+	}
+
+	if _, err = queryPod(k).QueryLogs(LogQueryInclude("(")); err == nil {
+		t.Errorf("expected invalid pattern error")
+	}
+}
+
+func TestQueryLogsWhere(t *testing.T) {
+	k := RegisterFakeCluster("query-logs-where-cluster", runningPod("web-0", nil, "app"))
+	fakePodLogs(k, map[string]string{"web-0/app": queryLogs})
+
+	cases := []struct {
+		where    string
+		args     []interface{}
+		expected []string
+	}{
+		{"level = 'ERROR'", nil, []string{"db timeout"}},
+		{"latency > 500 and http.status = 200", nil, []string{"slow request"}},
+		{"http.status in (500, 502) or msg like '%slow%'", nil, []string{"db timeout", "slow request"}},
+		{"latency between 100 and 600", nil, []string{"request", "slow request"}},
+		{"level != ?", []interface{}{"info"}, []string{"db timeout", "slow request"}},
+		{"missing = 1", nil, nil},
+	}
+	for _, c := range cases {
+		result, err := queryPod(k).QueryLogs(LogQueryWhere(c.where, c.args...))
+		if err != nil {
+			t.Fatalf("query logs %q failed: %v", c.where, err)
+		}
+		var msgs []string
+		for _, l := range result.Lines {
+			msgs = append(msgs, l.Fields["msg"].(string))
+		}
+		if strings.Join(msgs, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("where %q: expected %v, got %v", c.where, c.expected, msgs)
+		}
+	}
+
+	result, err := queryPod(k).QueryLogs(LogQueryParseJSON())
+	if err != nil {
+		t.Fatalf("query logs failed: %v", err)
+	}
+	if len(result.Lines) != 8 || result.Lines[0].Fields != nil || result.Lines[1].Fields["level"] != "info" {
+		t.Errorf("unexpected parsed fields: %+v", result.Lines[:2])
+	}
+
+	if _, err = queryPod(k).QueryLogs(LogQueryWhere("level = ")); err == nil {
+		t.Errorf("expected invalid where error")
+	}
+}
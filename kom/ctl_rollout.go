@@ -48,6 +48,9 @@ func (d *rollout) checkResourceKind(kind string, supportedKinds []string) error
 }
 
 func (d *rollout) Restart() error {
+	return d.kubectl.execCtl("rollout", "restart", nil, d.restart)
+}
+func (d *rollout) restart() error {
 
 	kind := d.kubectl.Statement.GVK.Kind
 	d.logInfo("Restart")
@@ -62,6 +65,9 @@ func (d *rollout) Restart() error {
 	return d.handleError(kind, d.kubectl.Statement.Namespace, d.kubectl.Statement.Name, "restarting", err)
 }
 func (d *rollout) Pause() error {
+	return d.kubectl.execCtl("rollout", "pause", nil, d.pause)
+}
+func (d *rollout) pause() error {
 	kind := d.kubectl.Statement.GVK.Kind
 	d.logInfo("Pause")
 
@@ -76,6 +82,9 @@ func (d *rollout) Pause() error {
 
 }
 func (d *rollout) Resume() error {
+	return d.kubectl.execCtl("rollout", "resume", nil, d.resume)
+}
+func (d *rollout) resume() error {
 	kind := d.kubectl.Statement.GVK.Kind
 	d.logInfo("Resume")
 
@@ -380,7 +389,18 @@ func (d *rollout) filterByOwner(versionList []*v1.ControllerRevision, kind strin
 	})
 	return versionList
 }
-func (d *rollout) Undo(toVersions ...int) (string, error) {
+func (d *rollout) Undo(toVersions ...int) (result string, err error) {
+	params := map[string]string{}
+	if len(toVersions) > 0 {
+		params["toVersion"] = strconv.Itoa(toVersions[0])
+	}
+	err = d.kubectl.execCtl("rollout", "undo", params, func() (e error) {
+		result, e = d.undo(toVersions...)
+		return e
+	})
+	return
+}
+func (d *rollout) undo(toVersions ...int) (string, error) {
 	kind := d.kubectl.Statement.GVK.Kind
 	name := d.kubectl.Statement.Name
 	namespace := d.kubectl.Statement.Namespace
@@ -471,8 +491,10 @@ func (d *rollout) rollbackDeployment(toVersion int) error {
 	}
 	spec := vrs.Spec.Template.Spec
 
-	deploy.Spec.Template.Spec = spec
-	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&deploy).Update(&deploy).Error
+	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&deploy).UpdateWithRetry(&deploy, func(obj interface{}) error {
+		deploy.Spec.Template.Spec = spec
+		return nil
+	}).Error
 	if err != nil {
 		return fmt.Errorf(" rollbackDeployment rollout undo deployment  err %v ", err)
 	}
@@ -583,10 +605,10 @@ func (d *rollout) rollbackDaemonSet(toVersion int) error {
 	}
 
 	// 使用目标版本的模板更新当前 DaemonSet
-	ds.Spec.Template.Spec = dsTemplate.Spec.Template.Spec
-
-	// 更新 DaemonSet
-	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&ds).Update(&ds).Error
+	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&ds).UpdateWithRetry(&ds, func(obj interface{}) error {
+		ds.Spec.Template.Spec = dsTemplate.Spec.Template.Spec
+		return nil
+	}).Error
 	if err != nil {
 		return fmt.Errorf("rollbackDaemonSet update daemonset err %v", err)
 	}
@@ -671,11 +693,11 @@ func (d *rollout) rollbackStatefulSet(toVersion int) error {
 		return fmt.Errorf("rollbackStatefulSet unmarshal controllerrevision data err %v", err)
 	}
 
-	// 使用目标版本的模板更新当前 DaemonSet
-	sts.Spec.Template.Spec = stsTemplate.Spec.Template.Spec
-
-	// 更新 DaemonSet
-	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&sts).Update(&sts).Error
+	// 使用目标版本的模板更新当前 StatefulSet
+	err = d.kubectl.WithContext(d.kubectl.Statement.Context).Resource(&sts).UpdateWithRetry(&sts, func(obj interface{}) error {
+		sts.Spec.Template.Spec = stsTemplate.Spec.Template.Spec
+		return nil
+	}).Error
 	if err != nil {
 		return fmt.Errorf("rollbackStatefulSet update daemonset err %v", err)
 	}
@@ -14,6 +14,11 @@ type scale struct {
 }
 
 func (s *scale) Scale(replicas int32) error {
+	return s.kubectl.execCtl("scale", "scale", map[string]string{"replicas": strconv.Itoa(int(replicas))}, func() error {
+		return s.scale(replicas)
+	})
+}
+func (s *scale) scale(replicas int32) error {
 
 	kind := s.kubectl.Statement.GVK.Kind
 	klog.V(8).Infof("scale Kind=%s", kind)
@@ -45,30 +50,29 @@ func (s *scale) Scale(replicas int32) error {
 // 停止前将当前副本数记录到deployment的annotation中
 // kom.restore.replicas
 func (s *scale) Stop() error {
+	return s.kubectl.execCtl("scale", "stop", nil, s.stop)
+}
+func (s *scale) stop() error {
 	kind := s.kubectl.Statement.GVK.Kind
 	if !isSupportedKind(kind, []string{"Deployment", "StatefulSet", "ReplicationController", "ReplicaSet"}) {
 		s.kubectl.Error = fmt.Errorf("%s %s/%s Scale is not supported", kind, s.kubectl.Statement.Namespace, s.kubectl.Statement.Name)
 		return s.kubectl.Error
 	}
 	var item *unstructured.Unstructured
-	err := s.kubectl.Get(&item).Error
-	if err != nil {
-		return err
-	}
-
-	replicas, found, err := unstructured.NestedInt64(item.Object, "spec", "replicas")
-	if err != nil {
-		return fmt.Errorf("Error fetching replicas: %v\n", err)
-	}
-	if !found {
-		return fmt.Errorf("spec.replicas not found\n")
-	}
+	err := s.kubectl.PatchWithRetry(&item, types.StrategicMergePatchType, func(current *unstructured.Unstructured) (string, error) {
+		replicas, found, err := unstructured.NestedInt64(current.Object, "spec", "replicas")
+		if err != nil {
+			return "", fmt.Errorf("Error fetching replicas: %v\n", err)
+		}
+		if !found {
+			return "", fmt.Errorf("spec.replicas not found\n")
+		}
 
-	if replicas == 0 {
-		// 已经stop了
-		return nil
-	}
-	patchData := fmt.Sprintf(`{
+		if replicas == 0 {
+			// 已经stop了
+			return "", nil
+		}
+		return fmt.Sprintf(`{
 	"spec": {
 		"replicas": %d
 	},
@@ -77,11 +81,11 @@ func (s *scale) Stop() error {
 			"kom.restore.replicas": "%d"
 		}
 	}
-}`, 0, replicas)
-	err = s.kubectl.Patch(&item, types.StrategicMergePatchType, patchData).Error
+}`, 0, replicas), nil
+	}).Error
 
 	if err != nil {
-		return fmt.Errorf("stop %s/%s error %v", item.GetNamespace(), item.GetName(), err)
+		return fmt.Errorf("stop %s/%s error %v", s.kubectl.Statement.Namespace, s.kubectl.Statement.Name, err)
 	}
 	return nil
 
@@ -92,32 +96,32 @@ func (s *scale) Stop() error {
 // 则将kom.restore.replicas的值设置为deployment的replicas
 // 没有则设置为1
 func (s *scale) Restore() error {
+	return s.kubectl.execCtl("scale", "restore", nil, s.restore)
+}
+func (s *scale) restore() error {
 	kind := s.kubectl.Statement.GVK.Kind
 	if !isSupportedKind(kind, []string{"Deployment", "StatefulSet", "ReplicationController", "ReplicaSet"}) {
 		s.kubectl.Error = fmt.Errorf("%s %s/%s Scale is not supported", kind, s.kubectl.Statement.Namespace, s.kubectl.Statement.Name)
 		return s.kubectl.Error
 	}
 	var item *unstructured.Unstructured
-	err := s.kubectl.Get(&item).Error
-	if err != nil {
-		return err
-	}
-	annotations, found, err := unstructured.NestedStringMap(item.Object, "metadata", "annotations")
-	if err != nil {
-		return fmt.Errorf("error fetching annotations: %v\n", err)
-	}
-	if !found {
-		return fmt.Errorf("annotations not found")
-	}
-	targetReplicas := int32(1)
+	err := s.kubectl.PatchWithRetry(&item, types.StrategicMergePatchType, func(current *unstructured.Unstructured) (string, error) {
+		annotations, found, err := unstructured.NestedStringMap(current.Object, "metadata", "annotations")
+		if err != nil {
+			return "", fmt.Errorf("error fetching annotations: %v\n", err)
+		}
+		if !found {
+			return "", fmt.Errorf("annotations not found")
+		}
+		targetReplicas := int32(1)
 
-	if restoreReplicas, exists := annotations["kom.restore.replicas"]; exists {
-		if i, err := strconv.ParseInt(restoreReplicas, 10, 64); err == nil {
-			targetReplicas = int32(i)
+		if restoreReplicas, exists := annotations["kom.restore.replicas"]; exists {
+			if i, err := strconv.ParseInt(restoreReplicas, 10, 64); err == nil {
+				targetReplicas = int32(i)
+			}
 		}
-	}
 
-	patchData := fmt.Sprintf(`{
+		return fmt.Sprintf(`{
 	"spec": {
 		"replicas": %d
 	},
@@ -126,11 +130,11 @@ func (s *scale) Restore() error {
 			"kom.restore.replicas": null
 		}
 	}
-}`, targetReplicas)
-	err = s.kubectl.Patch(&item, types.StrategicMergePatchType, patchData).Error
+}`, targetReplicas), nil
+	}).Error
 
 	if err != nil {
-		return fmt.Errorf("stop %s/%s error %v", item.GetNamespace(), item.GetName(), err)
+		return fmt.Errorf("stop %s/%s error %v", s.kubectl.Statement.Namespace, s.kubectl.Statement.Name, err)
 	}
 	return nil
 
@@ -0,0 +1,131 @@
+package kom_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weibaohui/kom/callbacks"
+	"github.com/weibaohui/kom/kom"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// assertDryRunAll 校验最近一次变更操作以 dryRun=All 提交到 dynamic client
+func assertDryRunAll(t *testing.T, k *kom.Kubectl, verb string) {
+	t.Helper()
+	options := kom.FakeMutationOptions(k)
+	if len(options) == 0 {
+		t.Fatalf("%s was not submitted to dynamic client", verb)
+	}
+	var got string
+	var dryRun []string
+	switch o := options[len(options)-1].(type) {
+	case metav1.CreateOptions:
+		got, dryRun = "create", o.DryRun
+	case metav1.UpdateOptions:
+		got, dryRun = "update", o.DryRun
+	case metav1.PatchOptions:
+		got, dryRun = "patch", o.DryRun
+	case metav1.DeleteOptions:
+		got, dryRun = "delete", o.DryRun
+	}
+	if got != verb || len(dryRun) != 1 || dryRun[0] != metav1.DryRunAll {
+		t.Errorf("%s should be submitted with dryRun=All, got %s %v", verb, got, dryRun)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "dry-deploy", Namespace: "default", Labels: map[string]string{"app": "dry"}},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(1)),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "dry"}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "dry"}},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "nginx", Image: "nginx"}}},
+			},
+		},
+	}
+	k := kom.RegisterFakeCluster("dry-run-cluster", deploy)
+	// 使用 callbacks 中的实现提交变更，校验 dynamic client 收到的参数
+	_ = k.Callback().Create().Replace("fake:create", callbacks.Create)
+	_ = k.Callback().Update().Replace("fake:update", callbacks.Update)
+	_ = k.Callback().Patch().Replace("fake:patch", callbacks.Patch)
+	_ = k.Callback().Delete().Replace("fake:delete", callbacks.Delete)
+
+	// DryRun 不影响后续非 DryRun 链
+	if k.DryRun().Statement.DryRun == false || k.Statement.DryRun {
+		t.Fatalf("DryRun should only be set on new instance")
+	}
+
+	// Create
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "dry-pod", Namespace: "default"}}
+	err := k.DryRun().Resource(pod).Create(pod).Error
+	if err != nil {
+		t.Fatalf("DryRun create failed: %v", err)
+	}
+	assertDryRunAll(t, k, "create")
+	var got v1.Pod
+	if err = k.Resource(&v1.Pod{}).Namespace("default").Name("dry-pod").Get(&got).Error; err == nil {
+		t.Errorf("DryRun create should not persist pod")
+	}
+
+	// Scale
+	if err = k.DryRun().Resource(&appsv1.Deployment{}).Namespace("default").Name("dry-deploy").Ctl().Scaler().Scale(3); err != nil {
+		t.Fatalf("DryRun scale failed: %v", err)
+	}
+	assertDryRunAll(t, k, "patch")
+	// Label
+	if err = k.DryRun().Resource(&appsv1.Deployment{}).Namespace("default").Name("dry-deploy").Ctl().Label("x=y"); err != nil {
+		t.Fatalf("DryRun label failed: %v", err)
+	}
+	assertDryRunAll(t, k, "patch")
+	var current appsv1.Deployment
+	if err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("dry-deploy").Get(&current).Error; err != nil {
+		t.Fatalf("Get deployment failed: %v", err)
+	}
+	if *current.Spec.Replicas != 1 || current.Labels["x"] != "" {
+		t.Errorf("DryRun scale/label should not change deployment, got replicas=%d labels=%v", *current.Spec.Replicas, current.Labels)
+	}
+
+	// Update
+	current.Labels["x"] = "y"
+	if err = k.DryRun().Resource(&current).Update(&current).Error; err != nil {
+		t.Fatalf("DryRun update failed: %v", err)
+	}
+	assertDryRunAll(t, k, "update")
+	if err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("dry-deploy").Get(&current).Error; err != nil || current.Labels["x"] != "" {
+		t.Errorf("DryRun update should not change deployment, got labels=%v %v", current.Labels, err)
+	}
+
+	// Delete
+	if err = k.DryRun().Resource(&appsv1.Deployment{}).Namespace("default").Name("dry-deploy").Delete().Error; err != nil {
+		t.Fatalf("DryRun delete failed: %v", err)
+	}
+	assertDryRunAll(t, k, "delete")
+	if err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("dry-deploy").Get(&current).Error; err != nil {
+		t.Errorf("DryRun delete should not remove deployment: %v", err)
+	}
+
+	// Applier
+	yamlContent := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dry-cm
+  namespace: default
+data:
+  a: b
+`
+	results := k.DryRun().Applier().Apply(yamlContent)
+	if len(results) != 1 || !strings.Contains(results[0], "created (server dry run)") {
+		t.Errorf("Unexpected dry run apply result: %v", results)
+	}
+	assertDryRunAll(t, k, "create")
+	var cm v1.ConfigMap
+	if err = k.Resource(&v1.ConfigMap{}).Namespace("default").Name("dry-cm").Get(&cm).Error; err == nil {
+		t.Errorf("DryRun apply should not persist configmap")
+	}
+}
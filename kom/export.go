@@ -0,0 +1,526 @@
+package kom
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+// exportSkipKinds 默认不导出的资源，均由集群自动维护
+var exportSkipKinds = map[string]struct{}{
+	"Event":              {},
+	"Endpoints":          {},
+	"EndpointSlice":      {},
+	"ControllerRevision": {},
+	"Lease":              {},
+	"PodMetrics":         {},
+}
+
+// ExportRedactedAnnotation 标记导出时 Secret 的值已被脱敏，Import 拒绝导入带有该标记的 Secret，避免掩码覆盖真实数据
+const ExportRedactedAnnotation = "kom.kubernetes.io/redacted"
+
+// ExportOption Export 配置项
+type ExportOption func(*exportOptions)
+
+type exportOptions struct {
+	includeKinds    map[string]struct{} // 只导出的资源，为空时导出全部
+	excludeKinds    map[string]struct{} // 不导出的资源
+	dir             string              // 输出目录
+	tarWriter       io.Writer           // 输出 tar 流
+	redactedSecrets bool                // 导出脱敏后的 Secret
+}
+
+// ExportIncludeKinds 只导出指定的资源，可以是 Kind（如 Deployment）、资源名（如 deployments）或 资源名.组（如 deployments.apps），不区分大小写
+func ExportIncludeKinds(kinds ...string) ExportOption {
+	return func(o *exportOptions) {
+		for _, kind := range kinds {
+			o.includeKinds[strings.ToLower(kind)] = struct{}{}
+		}
+	}
+}
+
+// ExportExcludeKinds 不导出指定的资源，格式同 ExportIncludeKinds
+func ExportExcludeKinds(kinds ...string) ExportOption {
+	return func(o *exportOptions) {
+		for _, kind := range kinds {
+			o.excludeKinds[strings.ToLower(kind)] = struct{}{}
+		}
+	}
+}
+
+// ExportToDir 将导出的文件写入目录，目录不存在时自动创建
+func ExportToDir(dir string) ExportOption {
+	return func(o *exportOptions) {
+		o.dir = dir
+	}
+}
+
+// ExportToTar 将导出的文件写入 tar 流
+func ExportToTar(w io.Writer) ExportOption {
+	return func(o *exportOptions) {
+		o.tarWriter = w
+	}
+}
+
+// ExportRedactedSecrets 集群开启了 Secret 脱敏且未调用 RevealSecrets() 时，仍导出脱敏后的 Secret，
+// 用于查看备份中包含哪些 Secret。导出的 Secret 带有 ExportRedactedAnnotation 标记，Import 时会被拒绝
+func ExportRedactedSecrets() ExportOption {
+	return func(o *exportOptions) {
+		o.redactedSecrets = true
+	}
+}
+
+// ExportFile 导出的单个资源文件
+type ExportFile struct {
+	Path string                  `json:"path"` // 相对路径，格式为 资源名[.组]/名称.yaml，如 deployments.apps/web.yaml
+	GVK  schema.GroupVersionKind `json:"gvk"`
+	Name string                  `json:"name"`
+	Data []byte                  `json:"-"` // YAML 内容
+}
+
+// ExportResult 导出结果
+type ExportResult struct {
+	Namespace string        `json:"namespace"`
+	Files     []*ExportFile `json:"files"`
+	Errors    []error       `json:"-"` // 获取失败的资源类型及因脱敏跳过的 Secret，不影响其他资源的导出
+}
+
+// Export 导出命名空间下的全部资源，每个资源一个 YAML 文件
+// 资源类型来自 Status().APIResources()，同一资源存在多个版本时使用第一个版本；
+// 默认跳过 Event、Endpoints 等由集群自动维护的资源，以及由控制器创建（ownerReferences 中 controller 为 true）的资源，
+// 如 ReplicaSet、Pod，还会跳过 ServiceAccount 令牌 Secret、default ServiceAccount 及 kube-root-ca.crt。
+// 导出内容会移除 status、managedFields、uid、resourceVersion、creationTimestamp，以及 Service 的 clusterIP、
+// PVC 的 volumeName 等由集群分配的字段，可通过 Import 导入到其他命名空间或集群。
+// 集群开启了 Secret 脱敏时，值被脱敏的 Secret 不会导出并记录在 Errors 中，需要通过 RevealSecrets() 导出原始内容，
+// 避免导入时用掩码覆盖真实数据；也可通过 ExportRedactedSecrets() 导出带有脱敏标记的 Secret
+//
+// Example:
+// result, err := kom.DefaultCluster().Export("default", kom.ExportToDir("./backup"), kom.ExportExcludeKinds("Secret"))
+func (k *Kubectl) Export(namespace string, opts ...ExportOption) (*ExportResult, error) {
+	if namespace == "" {
+		return nil, fmt.Errorf("export: namespace is required")
+	}
+	options := &exportOptions{includeKinds: map[string]struct{}{}, excludeKinds: map[string]struct{}{}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+
+	result := &ExportResult{Namespace: namespace}
+	policy := k.secretRedactPolicy()
+	for _, resource := range k.exportResources(options) {
+		gvk := schema.GroupVersionKind{Group: resource.Group, Version: resource.Version, Kind: resource.Kind}
+		var items []*unstructured.Unstructured
+		err := k.newInstance().WithContext(k.Statement.Context).GVK(gvk.Group, gvk.Version, gvk.Kind).Namespace(namespace).List(&items).Error
+		if err != nil {
+			klog.V(6).Infof("export list %s error: %v", gvk.String(), err)
+			result.Errors = append(result.Errors, fmt.Errorf("list %s/%s,%s error:%w", gvk.Group, gvk.Version, gvk.Kind, err))
+			continue
+		}
+		sort.Slice(items, func(i, j int) bool {
+			return items[i].GetName() < items[j].GetName()
+		})
+		for _, item := range items {
+			item.SetGroupVersionKind(gvk)
+			if skipExport(item) {
+				continue
+			}
+			if gvk.GroupKind() == (schema.GroupKind{Kind: "Secret"}) && secretRedacted(item, policy) {
+				if !options.redactedSecrets {
+					result.Errors = append(result.Errors, fmt.Errorf("secret %s skipped: values are redacted, use RevealSecrets() to export them", item.GetName()))
+					continue
+				}
+				annotations := item.GetAnnotations()
+				if annotations == nil {
+					annotations = map[string]string{}
+				}
+				annotations[ExportRedactedAnnotation] = "true"
+				item.SetAnnotations(annotations)
+			}
+			cleanForExport(item)
+			data, err := yaml.Marshal(item.Object)
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Errorf("marshal %s %s error:%w", gvk.Kind, item.GetName(), err))
+				continue
+			}
+			result.Files = append(result.Files, &ExportFile{
+				Path: path.Join(exportDirName(resource), item.GetName()+".yaml"),
+				GVK:  gvk,
+				Name: item.GetName(),
+				Data: data,
+			})
+		}
+	}
+
+	if options.dir != "" {
+		if err := writeExportDir(options.dir, result.Files); err != nil {
+			return result, err
+		}
+	}
+	if options.tarWriter != nil {
+		if err := writeExportTar(options.tarWriter, result.Files); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// exportResources 获取需要导出的命名空间级资源类型
+func (k *Kubectl) exportResources(options *exportOptions) (resources []*metav1.APIResource) {
+	seen := map[schema.GroupResource]struct{}{}
+	for _, r := range k.Status().APIResources() {
+		if r == nil || !r.Namespaced || strings.Contains(r.Name, "/") {
+			// 子资源，如 pods/log
+			continue
+		}
+		if len(r.Verbs) > 0 && !hasVerb(r.Verbs, "list") {
+			continue
+		}
+		gr := schema.GroupResource{Group: r.Group, Resource: r.Name}
+		if _, ok := seen[gr]; ok {
+			continue
+		}
+		seen[gr] = struct{}{}
+
+		names := []string{strings.ToLower(r.Kind), r.Name, gr.String()}
+		if len(options.includeKinds) > 0 {
+			if !matchKinds(options.includeKinds, names) {
+				continue
+			}
+		} else if _, skip := exportSkipKinds[r.Kind]; skip {
+			continue
+		}
+		if matchKinds(options.excludeKinds, names) {
+			continue
+		}
+		resources = append(resources, r)
+	}
+	return resources
+}
+
+func hasVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+func matchKinds(kinds map[string]struct{}, names []string) bool {
+	for _, name := range names {
+		if _, ok := kinds[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// exportDirName 资源文件所在的目录名，核心组为资源名，其他为 资源名.组
+func exportDirName(r *metav1.APIResource) string {
+	if r.Group == "" {
+		return r.Name
+	}
+	return r.Name + "." + r.Group
+}
+
+// skipExport 判断是否跳过由集群或控制器自动创建的资源
+func skipExport(obj *unstructured.Unstructured) bool {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.Controller != nil && *owner.Controller {
+			return true
+		}
+	}
+	switch obj.GetKind() {
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType == "kubernetes.io/service-account-token"
+	case "ServiceAccount":
+		return obj.GetName() == "default"
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	}
+	return false
+}
+
+// secretRedacted 判断 Secret 中是否存在按策略脱敏的值
+func secretRedacted(obj *unstructured.Unstructured, policy *SecretRedactPolicy) bool {
+	if policy == nil {
+		return false
+	}
+	for _, field := range []string{"data", "stringData"} {
+		values, _, _ := unstructured.NestedMap(obj.Object, field)
+		for key := range values {
+			if policy.Match(key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanForExport 移除由集群维护或分配的字段
+func cleanForExport(obj *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(obj.Object, "status")
+	for _, field := range []string{"uid", "resourceVersion", "creationTimestamp", "managedFields", "generation",
+		"selfLink", "ownerReferences", "deletionTimestamp", "deletionGracePeriodSeconds"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	annotations := obj.GetAnnotations()
+	for key := range annotations {
+		if key == "kubectl.kubernetes.io/last-applied-configuration" ||
+			key == "deployment.kubernetes.io/revision" ||
+			strings.HasPrefix(key, "pv.kubernetes.io/") ||
+			strings.HasPrefix(key, "volume.beta.kubernetes.io/") ||
+			strings.HasPrefix(key, "volume.kubernetes.io/") {
+			delete(annotations, key)
+		}
+	}
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(obj.Object, "metadata", "annotations")
+	} else {
+		obj.SetAnnotations(annotations)
+	}
+
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Service"}:
+		// Headless Service 的 clusterIP 为 None，需要保留
+		if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != v1.ClusterIPNone {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+		unstructured.RemoveNestedField(obj.Object, "spec", "healthCheckNodePort")
+		if ports, found, _ := unstructured.NestedSlice(obj.Object, "spec", "ports"); found {
+			for _, p := range ports {
+				if port, ok := p.(map[string]interface{}); ok {
+					delete(port, "nodePort")
+				}
+			}
+			_ = unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+		}
+	case schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+	case schema.GroupKind{Kind: "Pod"}:
+		unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		// 未手动指定 selector 时，selector 及 controller-uid 标签由集群生成
+		if manual, _, _ := unstructured.NestedBool(obj.Object, "spec", "manualSelector"); !manual {
+			unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+			for _, label := range []string{"controller-uid", "batch.kubernetes.io/controller-uid"} {
+				unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "labels", label)
+			}
+		}
+	}
+}
+
+func writeExportDir(dir string, files []*ExportFile) error {
+	for _, f := range files {
+		name := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, f.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeExportTar(w io.Writer, files []*ExportFile) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+	for _, f := range files {
+		header := &tar.Header{
+			Name:     f.Path,
+			Typeflag: tar.TypeReg,
+			Mode:     0o644,
+			Size:     int64(len(f.Data)),
+			ModTime:  now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ImportOption Import 配置项
+type ImportOption func(*importOptions)
+
+type importOptions struct {
+	dir          string
+	tarReader    io.Reader
+	files        []*ExportFile
+	names        map[string]string
+	applyOptions []ApplyOption
+}
+
+// ImportFromDir 从 Export 输出的目录导入
+func ImportFromDir(dir string) ImportOption {
+	return func(o *importOptions) {
+		o.dir = dir
+	}
+}
+
+// ImportFromTar 从 Export 输出的 tar 流导入
+func ImportFromTar(r io.Reader) ImportOption {
+	return func(o *importOptions) {
+		o.tarReader = r
+	}
+}
+
+// ImportFromExport 直接导入 Export 的结果
+func ImportFromExport(result *ExportResult) ImportOption {
+	return func(o *importOptions) {
+		if result != nil {
+			o.files = append(o.files, result.Files...)
+		}
+	}
+}
+
+// ImportRenames 导入时重命名资源，key 为原名称，value 为新名称，对所有类型的资源生效
+// 只修改资源自身的名称，不修改其他资源中对它的引用
+func ImportRenames(names map[string]string) ImportOption {
+	return func(o *importOptions) {
+		o.names = names
+	}
+}
+
+// ImportApplyOptions 设置导入时使用的 Apply 配置项，如 ApplyThreeWayMerge()
+func ImportApplyOptions(opts ...ApplyOption) ImportOption {
+	return func(o *importOptions) {
+		o.applyOptions = append(o.applyOptions, opts...)
+	}
+}
+
+// Import 将 Export 导出的资源通过 Applier 应用到当前集群
+// namespace 不为空时，命名空间级资源导入到该命名空间；为空时使用文件中的命名空间。
+// 导入顺序与 ApplyWithResult 一致，DryRun() 同样生效
+//
+// Example:
+// results, err := kom.Cluster("backup").Import("restore", kom.ImportFromDir("./backup"))
+func (k *Kubectl) Import(namespace string, opts ...ImportOption) ([]*ApplyResult, error) {
+	options := &importOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	files := options.files
+	if options.dir != "" {
+		dirFiles, err := readImportDir(options.dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
+	}
+	if options.tarReader != nil {
+		tarFiles, err := readImportTar(options.tarReader)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tarFiles...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("import: no files to import")
+	}
+
+	var docs []string
+	var results []*ApplyResult
+	for _, f := range files {
+		objs, errs := parseManifests(string(f.Data))
+		for _, err := range errs {
+			results = append(results, &ApplyResult{Action: ApplyActionFailed, Error: fmt.Errorf("%s: YAML 解析失败: %w", f.Path, err)})
+		}
+		for _, obj := range objs {
+			if obj.GroupVersionKind().GroupKind() == (schema.GroupKind{Kind: "Secret"}) && obj.GetAnnotations()[ExportRedactedAnnotation] == "true" {
+				err := fmt.Errorf("%s: secret %s was exported with redacted values, export it again with RevealSecrets()", f.Path, obj.GetName())
+				results = append(results, (&ApplyResult{GVK: obj.GroupVersionKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()}).fail(err))
+				continue
+			}
+			if namespace != "" && obj.GetNamespace() != "" {
+				obj.SetNamespace(namespace)
+			}
+			if newName, ok := options.names[obj.GetName()]; ok && newName != "" {
+				obj.SetName(newName)
+			}
+			data, err := yaml.Marshal(obj.Object)
+			if err != nil {
+				results = append(results, (&ApplyResult{GVK: obj.GroupVersionKind(), Name: obj.GetName()}).fail(err))
+				continue
+			}
+			docs = append(docs, string(data))
+		}
+	}
+	if len(docs) > 0 {
+		results = append(results, k.Applier().ApplyWithResult(strings.Join(docs, "---\n"), options.applyOptions...)...)
+	}
+	return results, nil
+}
+
+func readImportDir(dir string) (files []*ExportFile, err error) {
+	err = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isManifestFile(name) {
+			return nil
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, name)
+		files = append(files, &ExportFile{Path: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return files, err
+}
+
+func readImportTar(r io.Reader) (files []*ExportFile, err error) {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !header.FileInfo().Mode().IsRegular() || !isManifestFile(header.Name) {
+			continue
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, tr); err != nil {
+			return nil, err
+		}
+		files = append(files, &ExportFile{Path: header.Name, Data: buf.Bytes()})
+	}
+}
+
+func isManifestFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
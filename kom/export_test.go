@@ -0,0 +1,227 @@
+package kom
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func exportFixtures() *Kubectl {
+	controller := true
+	return RegisterFakeCluster("export-cluster",
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "shop", UID: "u1", ResourceVersion: "10"},
+			Data:       map[string]string{"a": "1"},
+		},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "kube-root-ca.crt", Namespace: "shop"}},
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"},
+			Spec: v1.ServiceSpec{
+				ClusterIP:  "10.0.0.10",
+				ClusterIPs: []string{"10.0.0.10"},
+				Selector:   map[string]string{"app": "web"},
+				Ports:      []v1.ServicePort{{Port: 80, NodePort: 30080}},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", Generation: 3},
+			Status:     appsv1.DeploymentStatus{Replicas: 1},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "shop", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "d1", Controller: &controller},
+			}},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sa-token", Namespace: "shop"},
+			Type:       v1.SecretTypeServiceAccountToken,
+		},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}},
+	)
+}
+
+func TestExport(t *testing.T) {
+	k := exportFixtures()
+	var buf bytes.Buffer
+	dir := t.TempDir()
+	result, err := k.Export("shop", ExportToTar(&buf), ExportToDir(dir))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	files := map[string][]byte{}
+	for _, f := range result.Files {
+		files[f.Path] = f.Data
+	}
+	want := []string{"configmaps/app-config.yaml", "services/web.yaml", "deployments.apps/web.yaml"}
+	if len(files) != len(want) {
+		t.Errorf("unexpected files %v", exportFileNames(files))
+	}
+	for _, name := range want {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing file %s, got %v", name, exportFileNames(files))
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("file %s should be written to dir: %v", name, err)
+		}
+	}
+
+	cm := string(files["configmaps/app-config.yaml"])
+	for _, field := range []string{"uid", "resourceVersion", "creationTimestamp"} {
+		if strings.Contains(cm, field+":") {
+			t.Errorf("%s should be removed:\n%s", field, cm)
+		}
+	}
+	var svc v1.Service
+	if err := yaml.Unmarshal(files["services/web.yaml"], &svc); err != nil {
+		t.Fatalf("unmarshal service failed: %v", err)
+	}
+	if svc.Spec.ClusterIP != "" || len(svc.Spec.ClusterIPs) != 0 || svc.Spec.Ports[0].NodePort != 0 {
+		t.Errorf("cluster assigned fields should be removed: %+v", svc.Spec)
+	}
+	if strings.Contains(string(files["deployments.apps/web.yaml"]), "status:") {
+		t.Errorf("status should be removed")
+	}
+
+	// tar 流与目录内容一致，可直接导入
+	tarFiles, err := readImportTar(&buf)
+	if err != nil || len(tarFiles) != len(want) {
+		t.Errorf("unexpected tar files %d, %v", len(tarFiles), err)
+	}
+}
+
+func TestExportKindFilters(t *testing.T) {
+	k := exportFixtures()
+	result, err := k.Export("shop", ExportIncludeKinds("ConfigMap", "deployments.apps"))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if len(result.Files) != 2 {
+		t.Errorf("expected 2 files, got %d", len(result.Files))
+	}
+	result, err = k.Export("shop", ExportExcludeKinds("services", "configmap"))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if len(result.Files) != 1 || result.Files[0].GVK.Kind != "Deployment" {
+		t.Errorf("unexpected files %v", result.Files)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := exportFixtures().Export("shop", ExportToDir(dir)); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+
+	target := RegisterFakeCluster("import-cluster")
+	results, err := target.Import("restore", ImportFromDir(dir), ImportRenames(map[string]string{"app-config": "app-config-v2"}))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Error != nil || r.Action != ApplyActionCreated || r.Namespace != "restore" {
+			t.Errorf("unexpected result %s %s", r, r.Namespace)
+		}
+	}
+	var cm v1.ConfigMap
+	if err := target.Resource(&cm).Namespace("restore").Name("app-config-v2").Get(&cm).Error; err != nil {
+		t.Fatalf("renamed configmap should exist: %v", err)
+	}
+	if cm.Data["a"] != "1" {
+		t.Errorf("unexpected data %v", cm.Data)
+	}
+	var svc v1.Service
+	if err := target.Resource(&svc).Namespace("restore").Name("web").Get(&svc).Error; err != nil {
+		t.Errorf("service should be imported: %v", err)
+	}
+}
+
+func exportFileNames(m map[string][]byte) (result []string) {
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+func TestExportHeadlessService(t *testing.T) {
+	k := RegisterFakeCluster("export-headless-cluster", &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop"},
+		Spec: v1.ServiceSpec{
+			ClusterIP:  v1.ClusterIPNone,
+			ClusterIPs: []string{v1.ClusterIPNone},
+			Selector:   map[string]string{"app": "db"},
+		},
+	})
+	result, err := k.Export("shop", ExportIncludeKinds("Service"))
+	if err != nil || len(result.Files) != 1 {
+		t.Fatalf("export failed: %v %v", result, err)
+	}
+	var svc v1.Service
+	if err := yaml.Unmarshal(result.Files[0].Data, &svc); err != nil {
+		t.Fatalf("unmarshal service failed: %v", err)
+	}
+	if svc.Spec.ClusterIP != v1.ClusterIPNone || len(svc.Spec.ClusterIPs) != 1 {
+		t.Errorf("headless service should keep clusterIP None: %+v", svc.Spec)
+	}
+}
+
+func TestExportRedactedSecret(t *testing.T) {
+	k := RegisterFakeCluster("export-secret-cluster", &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-auth", Namespace: "shop"},
+		Data:       map[string][]byte{"password": []byte("s3cret")},
+	})
+	Clusters().GetClusterById("export-secret-cluster").redactPolicy = NewSecretRedactPolicy()
+
+	// 脱敏后的 Secret 默认不导出
+	result, err := k.Export("shop", ExportIncludeKinds("Secret"))
+	if err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	if len(result.Files) != 0 || len(result.Errors) != 1 {
+		t.Errorf("redacted secret should be skipped, got files %v errors %v", result.Files, result.Errors)
+	}
+
+	// RevealSecrets 导出原始内容
+	result, err = k.RevealSecrets().Export("shop", ExportIncludeKinds("Secret"))
+	if err != nil || len(result.Files) != 1 {
+		t.Fatalf("export failed: %v %v", result, err)
+	}
+	var secret v1.Secret
+	if err := yaml.Unmarshal(result.Files[0].Data, &secret); err != nil {
+		t.Fatalf("unmarshal secret failed: %v", err)
+	}
+	if string(secret.Data["password"]) != "s3cret" || secret.Annotations[ExportRedactedAnnotation] != "" {
+		t.Errorf("revealed secret should be exported as is: %v %v", secret.Data, secret.Annotations)
+	}
+
+	// 带有脱敏标记的 Secret 拒绝导入
+	dir := t.TempDir()
+	result, err = k.Export("shop", ExportIncludeKinds("Secret"), ExportRedactedSecrets(), ExportToDir(dir))
+	if err != nil || len(result.Files) != 1 {
+		t.Fatalf("export failed: %v %v", result, err)
+	}
+	if !strings.Contains(string(result.Files[0].Data), ExportRedactedAnnotation) {
+		t.Errorf("redacted secret should be marked:\n%s", result.Files[0].Data)
+	}
+	target := RegisterFakeCluster("export-secret-target")
+	results, err := target.Import("restore", ImportFromDir(dir))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Action != ApplyActionFailed || results[0].Error == nil {
+		t.Errorf("redacted secret should be refused, got %v", results)
+	}
+	if err := target.Resource(&secret).Namespace("restore").Name("db-auth").Get(&secret).Error; err == nil {
+		t.Errorf("redacted secret should not be imported")
+	}
+}
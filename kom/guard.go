@@ -0,0 +1,88 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/duke-git/lancet/v2/slice"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	// ErrClusterReadOnly 集群以只读方式注册，拒绝所有变更类操作
+	ErrClusterReadOnly = errors.New("cluster is registered as read-only")
+	// ErrNamespaceNotAllowed 操作的命名空间不在注册时允许的范围内
+	ErrNamespaceNotAllowed = errors.New("namespace is not allowed")
+)
+
+// guardVerbs 需要守卫的处理器，包括所有变更类操作、容器内执行命令、端口转发以及 Ctl 高层操作
+var guardVerbs = append([]string{"create", "update", "patch", "delete", "exec", "stream-exec", "port-forward"}, ctlProcessors...)
+
+// guard 注册时设置的集群访问限制，不受 kubeconfig 自身权限的影响
+type guard struct {
+	readOnly          bool
+	allowedNamespaces []string
+}
+
+// newGuard 根据注册参数创建守卫，没有任何限制时返回 nil
+func newGuard(params *RegisterParams) *guard {
+	if !params.ReadOnly && len(params.AllowedNamespaces) == 0 {
+		return nil
+	}
+	return &guard{
+		readOnly:          params.ReadOnly,
+		allowedNamespaces: params.AllowedNamespaces,
+	}
+}
+
+// check 校验操作是否允许
+// verb 为处理器名称，ns 为操作的命名空间，集群级资源为空
+func (g *guard) check(clusterID, verb, kind, ns, name string) error {
+	if g == nil {
+		return nil
+	}
+	if g.readOnly {
+		return fmt.Errorf("%s %s %s/%s denied: cluster %s: %w", verb, kind, ns, name, clusterID, ErrClusterReadOnly)
+	}
+	if len(g.allowedNamespaces) > 0 {
+		if ns == "" {
+			return fmt.Errorf("%s cluster-scoped %s %s denied: cluster %s only allows namespaces %v: %w", verb, kind, name, clusterID, g.allowedNamespaces, ErrNamespaceNotAllowed)
+		}
+		if !slice.Contain(g.allowedNamespaces, ns) {
+			return fmt.Errorf("%s %s %s/%s denied: cluster %s only allows namespaces %v: %w", verb, kind, ns, name, clusterID, g.allowedNamespaces, ErrNamespaceNotAllowed)
+		}
+	}
+	return nil
+}
+
+// registerGuardCallbacks 在变更类处理器的最前面注册守卫回调
+func registerGuardCallbacks(cs *callbacks, g *guard) error {
+	if cs == nil || g == nil {
+		return nil
+	}
+	for _, verb := range guardVerbs {
+		v := verb
+		err := cs.processors[v].Before("*").Register("kom:guard", func(k *Kubectl) error {
+			return k.guardCheck(v)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guardCheck 按集群注册时的限制校验当前操作
+// 未经过 callback 直接调用 client 的变更操作（如驱逐 Pod），需要手动调用
+func (k *Kubectl) guardCheck(verb string) error {
+	cluster := k.parentCluster()
+	if cluster == nil || cluster.guard == nil {
+		return nil
+	}
+	stmt := k.Statement
+	ns := stmt.Namespace
+	if ns == "" && stmt.Namespaced {
+		ns = metav1.NamespaceDefault
+	}
+	return cluster.guard.check(k.ID, verb, stmt.GVK.Kind, ns, stmt.Name)
+}
@@ -0,0 +1,104 @@
+package kom
+
+import (
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// registerFakeGuardCluster 注册带访问限制的 fake 集群
+func registerFakeGuardCluster(t *testing.T, id string, params *RegisterParams) *Kubectl {
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "guard-deploy", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To(int32(1))},
+	}
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "guard-node"}}
+	k := RegisterFakeCluster(id, deploy, node)
+	cluster := Clusters().GetClusterById(id)
+	cluster.guard = newGuard(params)
+	if err := registerGuardCallbacks(cluster.callbacks, cluster.guard); err != nil {
+		t.Fatalf("register guard failed: %v", err)
+	}
+	return k
+}
+
+func TestGuardReadOnly(t *testing.T) {
+	k := registerFakeGuardCluster(t, "guard-readonly-cluster", &RegisterParams{ReadOnly: true})
+
+	// 读操作不受影响
+	var deploy appsv1.Deployment
+	if err := k.Resource(&deploy).Namespace("default").Name("guard-deploy").Get(&deploy).Error; err != nil {
+		t.Fatalf("read should be allowed: %v", err)
+	}
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "guard-pod", Namespace: "default"}}
+	err := k.Resource(pod).Create(pod).Error
+	if !errors.Is(err, ErrClusterReadOnly) {
+		t.Errorf("create should be denied, got %v", err)
+	}
+	err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("guard-deploy").Ctl().Scaler().Scale(3)
+	if !errors.Is(err, ErrClusterReadOnly) {
+		t.Errorf("scale should be denied, got %v", err)
+	}
+	err = k.Resource(&v1.Node{}).Name("guard-node").Ctl().Node().Cordon()
+	if !errors.Is(err, ErrClusterReadOnly) {
+		t.Errorf("cordon should be denied, got %v", err)
+	}
+	err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("guard-deploy").Delete().Error
+	if !errors.Is(err, ErrClusterReadOnly) {
+		t.Errorf("delete should be denied, got %v", err)
+	}
+	err = k.Resource(&v1.Pod{}).Namespace("default").Name("guard-pod").Ctl().Pod().ContainerName("c").Command("ls").Execute(nil).Error
+	if !errors.Is(err, ErrClusterReadOnly) {
+		t.Errorf("exec should be denied, got %v", err)
+	}
+
+	if err = k.Resource(&deploy).Namespace("default").Name("guard-deploy").Get(&deploy).Error; err != nil {
+		t.Fatalf("get deployment failed: %v", err)
+	}
+	if *deploy.Spec.Replicas != 1 {
+		t.Errorf("deployment should not be changed, got replicas=%d", *deploy.Spec.Replicas)
+	}
+}
+
+func TestGuardAllowedNamespaces(t *testing.T) {
+	k := registerFakeGuardCluster(t, "guard-ns-cluster", &RegisterParams{AllowedNamespaces: []string{"default"}})
+
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "guard-pod", Namespace: "default"}}
+	if err := k.Resource(pod).Namespace("default").Create(pod).Error; err != nil {
+		t.Errorf("create in allowed namespace should succeed: %v", err)
+	}
+
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "guard-pod", Namespace: "kube-system"}}
+	err := k.Resource(other).Namespace("kube-system").Create(other).Error
+	if !errors.Is(err, ErrNamespaceNotAllowed) {
+		t.Errorf("create in other namespace should be denied, got %v", err)
+	}
+
+	// 集群级资源不属于任何允许的命名空间
+	err = k.Resource(&v1.Node{}).Name("guard-node").Ctl().Node().Cordon()
+	if !errors.Is(err, ErrNamespaceNotAllowed) {
+		t.Errorf("cordon should be denied, got %v", err)
+	}
+}
+
+func TestNewGuard(t *testing.T) {
+	if newGuard(&RegisterParams{}) != nil {
+		t.Errorf("guard should be nil without restrictions")
+	}
+	p := &RegisterParams{}
+	RegisterReadOnly()(p)
+	RegisterAllowedNamespaces("a", "b")(p)
+	g := newGuard(p)
+	if g == nil || !g.readOnly || len(g.allowedNamespaces) != 2 {
+		t.Errorf("unexpected guard %+v", g)
+	}
+	var nilGuard *guard
+	if err := nilGuard.check("c", "create", "Pod", "default", "p"); err != nil {
+		t.Errorf("nil guard should allow everything: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/dgraph-io/ristretto/v2"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 )
@@ -32,13 +33,15 @@ func initKubectl(config *rest.Config, id string) *Kubectl {
 	return k
 }
 
-// 获取一个全新的实例，只保留ctx
+// 获取一个全新的实例，只保留ctx及Secret脱敏设置
 func (k *Kubectl) newInstance() *Kubectl {
 	tx := &Kubectl{ID: k.ID, Error: k.Error}
 	// clone with new statement
 	tx.Statement = &Statement{
-		Kubectl: k.Statement.Kubectl,
-		Context: k.Statement.Context,
+		Kubectl:       k.Statement.Kubectl,
+		Context:       k.Statement.Context,
+		RedactPolicy:  k.Statement.RedactPolicy,
+		RevealSecrets: k.Statement.RevealSecrets,
 	}
 	return tx
 
@@ -50,18 +53,21 @@ func (k *Kubectl) getInstance() *Kubectl {
 		tx := &Kubectl{ID: k.ID, Error: k.Error}
 		// clone with new statement
 		tx.Statement = &Statement{
-			Kubectl:      k.Statement.Kubectl,
-			Context:      k.Statement.Context,
-			ListOptions:  k.Statement.ListOptions,
-			AllNamespace: k.Statement.AllNamespace,
-			Namespace:    k.Statement.Namespace,
-			Namespaced:   k.Statement.Namespaced,
-			GVR:          k.Statement.GVR,
-			GVK:          k.Statement.GVK,
-			Name:         k.Statement.Name,
-			CacheTTL:     k.Statement.CacheTTL,
-			Filter:       k.Statement.Filter,
-			ForceDelete:  k.Statement.ForceDelete,
+			Kubectl:       k.Statement.Kubectl,
+			Context:       k.Statement.Context,
+			ListOptions:   k.Statement.ListOptions,
+			AllNamespace:  k.Statement.AllNamespace,
+			Namespace:     k.Statement.Namespace,
+			Namespaced:    k.Statement.Namespaced,
+			GVR:           k.Statement.GVR,
+			GVK:           k.Statement.GVK,
+			Name:          k.Statement.Name,
+			CacheTTL:      k.Statement.CacheTTL,
+			Filter:        k.Statement.Filter,
+			ForceDelete:   k.Statement.ForceDelete,
+			DryRun:        k.Statement.DryRun,
+			RedactPolicy:  k.Statement.RedactPolicy,
+			RevealSecrets: k.Statement.RevealSecrets,
 		}
 		return tx
 	}
@@ -88,6 +94,10 @@ func (k *Kubectl) DynamicClient() dynamic.Interface {
 	cluster := Clusters().GetClusterById(k.ID)
 	return cluster.DynamicClient
 }
+func (k *Kubectl) MetadataClient() metadata.Interface {
+	cluster := Clusters().GetClusterById(k.ID)
+	return cluster.MetadataClient
+}
 func (k *Kubectl) parentCluster() *ClusterInst {
 	cluster := Clusters().GetClusterById(k.ID)
 	return cluster
@@ -1,8 +1,12 @@
 package kom
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/weibaohui/kom/kom/describe"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
@@ -12,7 +16,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/apimachinery/pkg/watch"
 	discoveryfake "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/dynamic/fake"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -80,7 +86,7 @@ func RegisterFakeCluster(id string, objects ...runtime.Object) *Kubectl {
 	// 注意：fake.NewSimpleDynamicClient 需要 runtime.Object，如果传入的是 typed object (如 v1.Pod)，
 	// 它内部会自动处理，但为了保险起见，我们可以确保 scheme 包含了这些类型。
 	// 这里简化处理，直接传入 objects。
-	fakeDynamicClient := fake.NewSimpleDynamicClient(s, objects...)
+	fakeDynamicClient := newFakeDynamicClient(s, objects...)
 
 	// 3. 初始化 ClusterInst
 	// 注意：我们需要创建一个 ClusterInst 并注册到全局 Clusters 中，或者直接返回一个绑定了 fake client 的 Kubectl
@@ -92,7 +98,7 @@ func RegisterFakeCluster(id string, objects ...runtime.Object) *Kubectl {
 		ID:            id,
 		Kubectl:       k,
 		Client:        fakeClient,
-		DynamicClient: fakeDynamicClient,
+		DynamicClient: &fakeDryRunClient{FakeDynamicClient: fakeDynamicClient},
 		Config:        &rest.Config{Host: "https://fake-cluster"},
 		apiResources: []*metav1.APIResource{
 			{Name: "pods", Namespaced: true, Kind: "Pod", Group: "", Version: "v1"},
@@ -118,6 +124,8 @@ func RegisterFakeCluster(id string, objects ...runtime.Object) *Kubectl {
 			{Name: "ingresses", Namespaced: true, Kind: "Ingress", Group: "networking.k8s.io", Version: "v1"},
 			{Name: "customresourcedefinitions", Namespaced: false, Kind: "CustomResourceDefinition", Group: "apiextensions.k8s.io", Version: "v1"},
 			{Name: "cronjobs", Namespaced: true, Kind: "CronJob", Group: "batch", Version: "v1"},
+			{Name: "jobs", Namespaced: true, Kind: "Job", Group: "batch", Version: "v1"},
+			{Name: "endpoints", Namespaced: true, Kind: "Endpoints", Group: "", Version: "v1"},
 			{Name: "horizontalpodautoscalers", Namespaced: true, Kind: "HorizontalPodAutoscaler", Group: "autoscaling", Version: "v2"},
 			{Name: "myapps", Namespaced: true, Kind: "MyApp", Group: "example.com", Version: "v1"},
 		},
@@ -138,9 +146,44 @@ func RegisterFakeCluster(id string, objects ...runtime.Object) *Kubectl {
 	return k
 }
 
+// newFakeDynamicClient 与 fake.NewSimpleDynamicClient 相同，额外注册自定义资源 MyApp 的 ListKind，
+// 未注册 ListKind 的资源在 List 时会 panic
+func newFakeDynamicClient(s *runtime.Scheme, objects ...runtime.Object) *fake.FakeDynamicClient {
+	unstructuredScheme := runtime.NewScheme()
+	for gvk := range s.AllKnownTypes() {
+		if strings.HasSuffix(gvk.Kind, "List") {
+			unstructuredScheme.AddKnownTypeWithName(gvk, &unstructured.UnstructuredList{})
+			continue
+		}
+		unstructuredScheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	}
+	listKinds := map[schema.GroupVersionResource]string{
+		{Group: "example.com", Version: "v1", Resource: "myapps"}: "MyAppList",
+	}
+
+	var converted []runtime.Object
+	for _, obj := range objects {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			converted = append(converted, u)
+			continue
+		}
+		m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			panic(err)
+		}
+		u := &unstructured.Unstructured{Object: m}
+		if gvks, _, err := s.ObjectKinds(obj); err == nil && len(gvks) > 0 {
+			u.SetGroupVersionKind(gvks[0])
+		}
+		converted = append(converted, u)
+	}
+	return fake.NewSimpleDynamicClientWithCustomListKinds(unstructuredScheme, listKinds, converted...)
+}
+
 func registerFakeHandlers(c *callbacks) {
 	c.Get().Register("fake:get", fakeGet)
 	c.List().Register("fake:list", fakeList)
+	c.Watch().Register("fake:watch", fakeWatch)
 	c.Delete().Register("fake:delete", fakeDelete)
 	c.Create().Register("fake:create", fakeCreate)
 	c.Update().Register("fake:update", fakeUpdate)
@@ -152,6 +195,120 @@ func registerFakeHandlers(c *callbacks) {
 	c.PortForward().Register("fake:port-forward", fakePortForward)
 }
 
+// fakeDryRunClient 在 fake dynamic client 的基础上记录变更操作收到的参数，并模拟 API Server 的 DryRun 行为：校验通过但不落库
+// fake dynamic client 会忽略 DryRun 参数，且 create、update、patch 的 Action 中不包含参数
+type fakeDryRunClient struct {
+	*fake.FakeDynamicClient
+	mu      sync.Mutex
+	options []interface{} // metav1.CreateOptions、UpdateOptions、PatchOptions、DeleteOptions
+}
+
+func (c *fakeDryRunClient) Resource(gvr schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &fakeDryRunNamespaceableResource{
+		NamespaceableResourceInterface: c.FakeDynamicClient.Resource(gvr),
+		client:                         c,
+	}
+}
+
+func (c *fakeDryRunClient) record(options interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.options = append(c.options, options)
+}
+
+// FakeMutationOptions 返回 fake 集群的 dynamic client 收到的变更操作参数，按调用顺序排列
+func FakeMutationOptions(k *Kubectl) []interface{} {
+	client, ok := Clusters().GetClusterById(k.ID).DynamicClient.(*fakeDryRunClient)
+	if !ok {
+		return nil
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]interface{}(nil), client.options...)
+}
+
+func isDryRunAll(dryRun []string) bool {
+	return len(dryRun) == 1 && dryRun[0] == metav1.DryRunAll
+}
+
+type fakeDryRunNamespaceableResource struct {
+	dynamic.NamespaceableResourceInterface
+	client *fakeDryRunClient
+}
+
+func (r *fakeDryRunNamespaceableResource) Namespace(ns string) dynamic.ResourceInterface {
+	return &fakeDryRunResource{ResourceInterface: r.NamespaceableResourceInterface.Namespace(ns), client: r.client}
+}
+
+func (r *fakeDryRunNamespaceableResource) cluster() *fakeDryRunResource {
+	return &fakeDryRunResource{ResourceInterface: r.NamespaceableResourceInterface, client: r.client}
+}
+
+func (r *fakeDryRunNamespaceableResource) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.cluster().Create(ctx, obj, options, subresources...)
+}
+
+func (r *fakeDryRunNamespaceableResource) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.cluster().Update(ctx, obj, options, subresources...)
+}
+
+func (r *fakeDryRunNamespaceableResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	return r.cluster().Patch(ctx, name, pt, data, options, subresources...)
+}
+
+func (r *fakeDryRunNamespaceableResource) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	return r.cluster().Delete(ctx, name, options, subresources...)
+}
+
+type fakeDryRunResource struct {
+	dynamic.ResourceInterface
+	client *fakeDryRunClient
+}
+
+func (r *fakeDryRunResource) Create(ctx context.Context, obj *unstructured.Unstructured, options metav1.CreateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.client.record(options)
+	if isDryRunAll(options.DryRun) {
+		// 直接返回提交的对象
+		return obj.DeepCopy(), nil
+	}
+	return r.ResourceInterface.Create(ctx, obj, options, subresources...)
+}
+
+func (r *fakeDryRunResource) Update(ctx context.Context, obj *unstructured.Unstructured, options metav1.UpdateOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.client.record(options)
+	if isDryRunAll(options.DryRun) {
+		return obj.DeepCopy(), nil
+	}
+	return r.ResourceInterface.Update(ctx, obj, options, subresources...)
+}
+
+func (r *fakeDryRunResource) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, options metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	r.client.record(options)
+	if isDryRunAll(options.DryRun) {
+		// 对象必须存在，返回当前对象
+		return r.ResourceInterface.Get(ctx, name, metav1.GetOptions{})
+	}
+	return r.ResourceInterface.Patch(ctx, name, pt, data, options, subresources...)
+}
+
+func (r *fakeDryRunResource) Delete(ctx context.Context, name string, options metav1.DeleteOptions, subresources ...string) error {
+	r.client.record(options)
+	if isDryRunAll(options.DryRun) {
+		// 对象必须存在
+		_, err := r.ResourceInterface.Get(ctx, name, metav1.GetOptions{})
+		return err
+	}
+	return r.ResourceInterface.Delete(ctx, name, options, subresources...)
+}
+
+// fakeDryRunOption 与 callbacks 相同，DryRun 时提交 dryRun=All
+func fakeDryRunOption(stmt *Statement) []string {
+	if stmt.DryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
 func fakeStreamExec(k *Kubectl) error {
 	if k.Statement.StreamOptions != nil {
 		// Verify options if needed
@@ -164,6 +321,24 @@ func fakeStreamExec(k *Kubectl) error {
 }
 
 func fakePortForward(k *Kubectl) error {
+	if req := k.Statement.PortForwardRequest; req != nil {
+		// 本地端口为 0 时模拟随机分配
+		ports := make([]ForwardedPort, 0, len(req.Ports))
+		for i, p := range req.Ports {
+			local, remote, _ := strings.Cut(p, ":")
+			l, _ := strconv.Atoi(local)
+			r, _ := strconv.Atoi(remote)
+			if l == 0 {
+				l = 40000 + i
+			}
+			ports = append(ports, ForwardedPort{Local: uint16(l), Remote: uint16(r)})
+		}
+		if req.OnReady != nil {
+			req.OnReady(ports)
+		}
+		<-req.StopCh
+		return nil
+	}
 	if k.Statement.PortForwardLocalPort == "" || k.Statement.PortForwardPodPort == "" {
 		return fmt.Errorf("ports not set")
 	}
@@ -207,16 +382,16 @@ func fakeCreate(k *Kubectl) error {
 	if u.GetName() == "" && stmt.Name != "" {
 		u.SetName(stmt.Name)
 	}
-	if u.GetNamespace() == "" && stmt.Namespace != "" {
+	if u.GetNamespace() == "" && stmt.Namespace != "" && stmt.Namespaced {
 		u.SetNamespace(stmt.Namespace)
 	}
 
 	var res *unstructured.Unstructured
 
 	if stmt.Namespaced {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, u, metav1.CreateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Create(ctx, u, metav1.CreateOptions{DryRun: fakeDryRunOption(stmt)})
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Create(ctx, u, metav1.CreateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Create(ctx, u, metav1.CreateOptions{DryRun: fakeDryRunOption(stmt)})
 	}
 
 	if err != nil {
@@ -240,9 +415,9 @@ func fakeUpdate(k *Kubectl) error {
 
 	var res *unstructured.Unstructured
 	if stmt.Namespaced {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Update(ctx, u, metav1.UpdateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Update(ctx, u, metav1.UpdateOptions{DryRun: fakeDryRunOption(stmt)})
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Update(ctx, u, metav1.UpdateOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Update(ctx, u, metav1.UpdateOptions{DryRun: fakeDryRunOption(stmt)})
 	}
 
 	if err != nil {
@@ -264,7 +439,9 @@ func fakePatch(k *Kubectl) error {
 	// Workaround for fake client: convert StrategicMergePatchType to MergePatchType
 	// because fake dynamic client deals with Unstructured and might fail with SMPT
 	// if schema information is missing or not fully supported in the fake context.
-	if patchType == types.StrategicMergePatchType {
+	// Server-side apply is emulated as a merge patch as well, the fake tracker
+	// applies it via strategic merge which does not work for Unstructured.
+	if patchType == types.StrategicMergePatchType || patchType == types.ApplyPatchType {
 		patchType = types.MergePatchType
 	}
 
@@ -272,9 +449,9 @@ func fakePatch(k *Kubectl) error {
 	var err error
 
 	if stmt.Namespaced {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, patchData, metav1.PatchOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Patch(ctx, name, patchType, patchData, metav1.PatchOptions{DryRun: fakeDryRunOption(stmt)})
 	} else {
-		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, patchData, metav1.PatchOptions{})
+		res, err = stmt.Kubectl.DynamicClient().Resource(gvr).Patch(ctx, name, patchType, patchData, metav1.PatchOptions{DryRun: fakeDryRunOption(stmt)})
 	}
 
 	if err != nil {
@@ -325,7 +502,9 @@ func fakeList(k *Kubectl) error {
 	var err error
 
 	if stmt.Namespaced {
-		if ns == "" {
+		if stmt.AllNamespace {
+			ns = metav1.NamespaceAll
+		} else if ns == "" {
 			ns = metav1.NamespaceDefault
 		}
 		list, err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).List(ctx, opts)
@@ -360,6 +539,18 @@ func fakeList(k *Kubectl) error {
 	sliceValue.SetLen(0) // clear
 
 	for _, item := range list.Items {
+		// 与真实 API 一致，列表中的元素带有 apiVersion 及 kind
+		if item.GetKind() == "" {
+			item.SetGroupVersionKind(stmt.GVK)
+		}
+		// 模拟元数据客户端，只返回 apiVersion、kind 及 metadata
+		if stmt.MetadataOnly {
+			item = unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": item.GetAPIVersion(),
+				"kind":       item.GetKind(),
+				"metadata":   item.Object["metadata"],
+			}}
+		}
 		// 创建元素的新实例
 		newElem := reflect.New(sliceValue.Type().Elem()).Interface()
 
@@ -379,6 +570,37 @@ func fakeList(k *Kubectl) error {
 	return nil
 }
 
+func fakeWatch(k *Kubectl) error {
+	stmt := k.Statement
+	opts := metav1.ListOptions{}
+	if len(stmt.ListOptions) > 0 {
+		opts = stmt.ListOptions[0]
+	}
+	dest, ok := stmt.Dest.(*watch.Interface)
+	if !ok {
+		return fmt.Errorf("fakeWatch: dest must be *watch.Interface")
+	}
+
+	var watcher watch.Interface
+	var err error
+	if stmt.Namespaced {
+		ns := stmt.Namespace
+		if stmt.AllNamespace {
+			ns = metav1.NamespaceAll
+		} else if ns == "" {
+			ns = metav1.NamespaceDefault
+		}
+		watcher, err = stmt.Kubectl.DynamicClient().Resource(stmt.GVR).Namespace(ns).Watch(stmt.Context, opts)
+	} else {
+		watcher, err = stmt.Kubectl.DynamicClient().Resource(stmt.GVR).Watch(stmt.Context, opts)
+	}
+	if err != nil {
+		return err
+	}
+	*dest = watcher
+	return nil
+}
+
 func fakeDelete(k *Kubectl) error {
 	stmt := k.Statement
 	gvr := stmt.GVR
@@ -389,9 +611,9 @@ func fakeDelete(k *Kubectl) error {
 	var err error
 
 	if stmt.Namespaced {
-		err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Delete(ctx, name, metav1.DeleteOptions{})
+		err = stmt.Kubectl.DynamicClient().Resource(gvr).Namespace(ns).Delete(ctx, name, metav1.DeleteOptions{DryRun: fakeDryRunOption(stmt)})
 	} else {
-		err = stmt.Kubectl.DynamicClient().Resource(gvr).Delete(ctx, name, metav1.DeleteOptions{})
+		err = stmt.Kubectl.DynamicClient().Resource(gvr).Delete(ctx, name, metav1.DeleteOptions{DryRun: fakeDryRunOption(stmt)})
 	}
 
 	return err
@@ -0,0 +1,301 @@
+package kom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weibaohui/kom/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+// ownerIndexAllNamespaces 全部命名空间下命名空间级资源的索引，用于查找集群级资源拥有的下级资源
+const ownerIndexAllNamespaces = "*"
+
+// defaultOwnerIndexTTL 未通过 WithCache 设置缓存时间时，ownerReferences 索引的缓存时间
+const defaultOwnerIndexTTL = 30 * time.Second
+
+// ownerIndexSkipKinds 不参与 ownerReferences 索引的资源，数量多且不会成为 owner
+var ownerIndexSkipKinds = map[string]struct{}{
+	"Event":       {},
+	"PodMetrics":  {},
+	"NodeMetrics": {},
+}
+
+// ownerIndex 一个命名空间（集群级资源为空，全部命名空间为 *）下资源的 ownerReferences 索引
+type ownerIndex struct {
+	byUID   map[types.UID]*unstructured.Unstructured
+	byOwner map[types.UID][]*unstructured.Unstructured
+}
+
+// Owners 沿 ownerReferences 向上查找资源的 owner，返回从直接 owner 到顶层控制器的链，
+// 如 Pod → ReplicaSet → Deployment → Argo Rollout 等自定义资源。
+// 存在多个 ownerReference 时沿 controller 为 true 的引用查找，没有时使用第一个。
+// owner 已被删除或其类型未在集群中注册时停止查找。
+// 返回的对象只包含 apiVersion、kind 及 metadata，需要完整内容时通过 Get 获取。
+// 命名空间内的资源通过索引查找，索引默认缓存 30 秒，可通过 WithCache 设置，Tools().ClearCache() 清除。
+//
+// Example:
+//
+//	owners, err := kom.DefaultCluster().Resource(&v1.Pod{}).Namespace("default").Name("web-7d4b9c-x2x9z").Owners()
+//	top := owners[len(owners)-1]
+func (k *Kubectl) Owners() ([]*unstructured.Unstructured, error) {
+	item, err := k.ownerStart()
+	if err != nil {
+		return nil, err
+	}
+	var owners []*unstructured.Unstructured
+	visited := map[types.UID]bool{item.GetUID(): true}
+	current := item
+	for {
+		ref := controllerOwnerRef(current.GetOwnerReferences())
+		if ref == nil || visited[ref.UID] {
+			break
+		}
+		visited[ref.UID] = true
+		owner, err := k.findOwner(current.GetNamespace(), ref)
+		if err != nil {
+			return owners, err
+		}
+		if owner == nil {
+			break
+		}
+		owners = append(owners, owner.DeepCopy())
+		current = owner
+	}
+	return owners, nil
+}
+
+// Dependents 沿 ownerReferences 向下查找资源拥有的全部下级资源，按层级顺序返回，同一层级内按 Kind、名称排序。
+// depth 为查找的层数，1 为只查找直接拥有的资源，小于等于 0 时不限制层数。
+// 下级资源来自资源所在命名空间下全部可 list 的资源类型（包括 CRD），
+// 集群级资源（如 Namespace、集群级 CR）同时查找集群级及全部命名空间下的下级资源，此时索引需要 list 全部命名空间。
+// 返回的对象只包含 apiVersion、kind 及 metadata；索引的缓存规则同 Owners。
+//
+// Example:
+//
+//	dependents, err := kom.DefaultCluster().Resource(&appsv1.Deployment{}).Namespace("default").Name("web").Dependents(0)
+func (k *Kubectl) Dependents(depth int) ([]*unstructured.Unstructured, error) {
+	item, err := k.ownerStart()
+	if err != nil {
+		return nil, err
+	}
+	index, err := k.ownerIndex(item.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+	indexes := []*ownerIndex{index}
+	if item.GetNamespace() == "" {
+		// 集群级资源可以拥有任意命名空间下的资源
+		if index, err = k.ownerIndex(ownerIndexAllNamespaces); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	var dependents []*unstructured.Unstructured
+	visited := map[types.UID]bool{item.GetUID(): true}
+	queue := []types.UID{item.GetUID()}
+	for level := 0; len(queue) > 0 && (depth <= 0 || level < depth); level++ {
+		var next []types.UID
+		for _, uid := range queue {
+			for _, index := range indexes {
+				for _, child := range index.byOwner[uid] {
+					if visited[child.GetUID()] {
+						continue
+					}
+					visited[child.GetUID()] = true
+					dependents = append(dependents, child.DeepCopy())
+					next = append(next, child.GetUID())
+				}
+			}
+		}
+		queue = next
+	}
+	return dependents, nil
+}
+
+// ownerStart 获取查找的起始资源
+func (k *Kubectl) ownerStart() (*unstructured.Unstructured, error) {
+	if k.Error != nil {
+		return nil, k.Error
+	}
+	stmt := k.Statement
+	gvk := stmt.GVK
+	if gvk.Kind == "" || stmt.Name == "" {
+		return nil, fmt.Errorf("请先设置资源类型及名称")
+	}
+	var item *unstructured.Unstructured
+	tx := k.newInstance().WithContext(stmt.Context).GVK(gvk.Group, gvk.Version, gvk.Kind).Name(stmt.Name)
+	if stmt.Namespaced {
+		tx = tx.Namespace(stmt.Namespace)
+	}
+	if err := tx.Get(&item).Error; err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, fmt.Errorf("%s %s not found", gvk.Kind, stmt.Name)
+	}
+	item.SetGroupVersionKind(gvk)
+	return item, nil
+}
+
+// controllerOwnerRef 返回 controller 为 true 的 ownerReference，没有时返回第一个
+func controllerOwnerRef(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	if len(refs) > 0 {
+		return &refs[0]
+	}
+	return nil
+}
+
+// findOwner 根据 ownerReference 查找 owner，不存在或类型未注册时返回 nil
+func (k *Kubectl) findOwner(namespace string, ref *metav1.OwnerReference) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("parse owner apiVersion %s error: %w", ref.APIVersion, err)
+	}
+	resource := k.ownerAPIResource(gv.WithKind(ref.Kind))
+	if resource == nil {
+		klog.V(6).Infof("owner %s %s is not a registered resource", ref.APIVersion, ref.Kind)
+		return nil, nil
+	}
+	if !resource.Namespaced {
+		namespace = ""
+	} else {
+		index, err := k.ownerIndex(namespace)
+		if err != nil {
+			return nil, err
+		}
+		if owner, ok := index.byUID[ref.UID]; ok {
+			return owner, nil
+		}
+	}
+
+	// 集群级资源，或索引建立后才创建的资源
+	var owner *unstructured.Unstructured
+	tx := k.newInstance().WithContext(k.Statement.Context).GVK(resource.Group, resource.Version, resource.Kind).Name(ref.Name)
+	if namespace != "" {
+		tx = tx.Namespace(namespace)
+	}
+	if err = tx.Get(&owner).Error; err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if owner == nil || (ref.UID != "" && owner.GetUID() != ref.UID) {
+		// 同名资源已被重建
+		return nil, nil
+	}
+	owner.SetGroupVersionKind(schema.GroupVersionKind{Group: resource.Group, Version: resource.Version, Kind: resource.Kind})
+	return ownerMetadata(owner), nil
+}
+
+// ownerAPIResource 查找资源类型，ownerReference 中的版本未注册时使用同组同 Kind 的其他版本
+func (k *Kubectl) ownerAPIResource(gvk schema.GroupVersionKind) *metav1.APIResource {
+	var candidate *metav1.APIResource
+	for _, r := range k.Status().APIResources() {
+		if r == nil || strings.Contains(r.Name, "/") || r.Group != gvk.Group || r.Kind != gvk.Kind {
+			continue
+		}
+		if r.Version == gvk.Version {
+			return r
+		}
+		if candidate == nil {
+			candidate = r
+		}
+	}
+	return candidate
+}
+
+// ownerIndex 获取命名空间下的 ownerReferences 索引，namespace 为空时为集群级资源的索引，
+// 为 ownerIndexAllNamespaces 时为全部命名空间下命名空间级资源的索引
+func (k *Kubectl) ownerIndex(namespace string) (*ownerIndex, error) {
+	ttl := k.Statement.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultOwnerIndexTTL
+	}
+	return utils.GetOrSetCache(k.ClusterCache(), "owner-index/"+namespace, ttl, func() (*ownerIndex, error) {
+		return k.buildOwnerIndex(namespace)
+	})
+}
+
+func (k *Kubectl) buildOwnerIndex(namespace string) (*ownerIndex, error) {
+	index := &ownerIndex{
+		byUID:   map[types.UID]*unstructured.Unstructured{},
+		byOwner: map[types.UID][]*unstructured.Unstructured{},
+	}
+	seen := map[schema.GroupResource]struct{}{}
+	for _, r := range k.Status().APIResources() {
+		if r == nil || r.Namespaced != (namespace != "") || strings.Contains(r.Name, "/") {
+			continue
+		}
+		if len(r.Verbs) > 0 && !hasVerb(r.Verbs, "list") {
+			continue
+		}
+		if _, skip := ownerIndexSkipKinds[r.Kind]; skip {
+			continue
+		}
+		gr := schema.GroupResource{Group: r.Group, Resource: r.Name}
+		if _, ok := seen[gr]; ok {
+			continue
+		}
+		seen[gr] = struct{}{}
+
+		gvk := schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+		var items []*unstructured.Unstructured
+		// 索引只需要 metadata，避免在大集群上获取完整对象
+		tx := k.newInstance().WithContext(k.Statement.Context).GVK(gvk.Group, gvk.Version, gvk.Kind).MetadataOnly()
+		switch namespace {
+		case "":
+		case ownerIndexAllNamespaces:
+			tx = tx.AllNamespace()
+		default:
+			tx = tx.Namespace(namespace)
+		}
+		if err := tx.List(&items).Error; err != nil {
+			// 无权限等原因无法获取的资源类型不影响其他资源
+			klog.V(6).Infof("owner index list %s in namespace %q error: %v", gvk.String(), namespace, err)
+			continue
+		}
+		for _, item := range items {
+			item.SetGroupVersionKind(gvk)
+			obj := ownerMetadata(item)
+			index.byUID[obj.GetUID()] = obj
+			for _, ref := range obj.GetOwnerReferences() {
+				index.byOwner[ref.UID] = append(index.byOwner[ref.UID], obj)
+			}
+		}
+	}
+	for _, children := range index.byOwner {
+		sort.Slice(children, func(i, j int) bool {
+			if children[i].GetKind() != children[j].GetKind() {
+				return children[i].GetKind() < children[j].GetKind()
+			}
+			return children[i].GetName() < children[j].GetName()
+		})
+	}
+	return index, nil
+}
+
+// ownerMetadata 只保留 apiVersion、kind 及 metadata，去掉 managedFields
+func ownerMetadata(item *unstructured.Unstructured) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	if metadata, ok := item.Object["metadata"].(map[string]interface{}); ok {
+		obj.Object["metadata"] = runtime.DeepCopyJSONValue(metadata)
+	}
+	obj.SetGroupVersionKind(item.GroupVersionKind())
+	obj.SetManagedFields(nil)
+	return obj
+}
@@ -0,0 +1,183 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/ristretto/v2"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func ownerRef(apiVersion, kind, name, uid string, controller bool) metav1.OwnerReference {
+	return metav1.OwnerReference{APIVersion: apiVersion, Kind: kind, Name: name, UID: types.UID(uid), Controller: ptr.To(controller)}
+}
+
+func ownedObjectsCluster(id string) *Kubectl {
+	app := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "MyApp",
+		"metadata":   map[string]interface{}{"name": "web", "namespace": "default", "uid": "uid-app"},
+	}}
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "uid-deploy",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("example.com/v1", "MyApp", "web", "uid-app", true)}}}
+	config := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web-config", Namespace: "default", UID: "uid-config",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("example.com/v1", "MyApp", "web", "uid-app", false)}}}
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-7d4b9c", Namespace: "default", UID: "uid-rs",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("apps/v1", "Deployment", "web", "uid-deploy", true)}}}
+	pod1 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-7d4b9c-b", Namespace: "default", UID: "uid-pod-b",
+		OwnerReferences: []metav1.OwnerReference{
+			ownerRef("v1", "ConfigMap", "web-config", "uid-config", false),
+			ownerRef("apps/v1", "ReplicaSet", "web-7d4b9c", "uid-rs", true),
+		}}}
+	pod2 := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-7d4b9c-a", Namespace: "default", UID: "uid-pod-a",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("apps/v1", "ReplicaSet", "web-7d4b9c", "uid-rs", true)}}}
+	// 其他命名空间中的同名资源
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-7d4b9c-a", Namespace: "other", UID: "uid-other",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("apps/v1", "ReplicaSet", "web-7d4b9c", "uid-rs", true)}}}
+	k := RegisterFakeCluster(id, app, deploy, config, rs, pod1, pod2, other)
+	// 索引缓存在集群的缓存中
+	cache, _ := ristretto.NewCache(&ristretto.Config[string, any]{NumCounters: 1e5, MaxCost: 1 << 24, BufferItems: 64})
+	Clusters().GetClusterById(id).Cache = cache
+	return k
+}
+
+func objectNames(items []*unstructured.Unstructured) string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.GetKind()+"/"+item.GetName())
+	}
+	return strings.Join(names, ",")
+}
+
+func TestOwners(t *testing.T) {
+	k := ownedObjectsCluster("owner-chain-cluster")
+
+	owners, err := k.Resource(&v1.Pod{}).Namespace("default").Name("web-7d4b9c-b").Owners()
+	if err != nil {
+		t.Fatalf("owners failed: %v", err)
+	}
+	if names := objectNames(owners); names != "ReplicaSet/web-7d4b9c,Deployment/web,MyApp/web" {
+		t.Errorf("unexpected owners %s", names)
+	}
+	if owners[2].GetAPIVersion() != "example.com/v1" || owners[0].Object["spec"] != nil {
+		t.Errorf("owners should only contain metadata, got %v", owners[2].Object)
+	}
+
+	owners, err = k.GVK("example.com", "v1", "MyApp").Namespace("default").Name("web").Owners()
+	if err != nil || len(owners) != 0 {
+		t.Errorf("top-level object should have no owners, got %v %v", owners, err)
+	}
+
+	// 索引建立后创建的资源通过 Get 查找
+	pod := &v1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}, ObjectMeta: metav1.ObjectMeta{Name: "late", Namespace: "default", UID: "uid-late",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("apps/v1", "ReplicaSet", "late-rs", "uid-late-rs", true)}}}
+	rs := &appsv1.ReplicaSet{TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "ReplicaSet"}, ObjectMeta: metav1.ObjectMeta{Name: "late-rs", Namespace: "default", UID: "uid-late-rs"}}
+	if err = k.Resource(rs).Create(rs).Error; err != nil {
+		t.Fatalf("create replicaset failed: %v", err)
+	}
+	if err = k.Resource(pod).Create(pod).Error; err != nil {
+		t.Fatalf("create pod failed: %v", err)
+	}
+	owners, err = k.Resource(&v1.Pod{}).Namespace("default").Name("late").Owners()
+	if err != nil || objectNames(owners) != "ReplicaSet/late-rs" {
+		t.Errorf("unexpected owners of late pod %s %v", objectNames(owners), err)
+	}
+
+	// owner 已被删除时停止查找
+	orphan := &v1.Pod{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}, ObjectMeta: metav1.ObjectMeta{Name: "orphan", Namespace: "default", UID: "uid-orphan",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("apps/v1", "ReplicaSet", "gone", "uid-gone", true)}}}
+	if err = k.Resource(orphan).Create(orphan).Error; err != nil {
+		t.Fatalf("create pod failed: %v", err)
+	}
+	owners, err = k.Resource(&v1.Pod{}).Namespace("default").Name("orphan").Owners()
+	if err != nil || len(owners) != 0 {
+		t.Errorf("deleted owner should stop the chain, got %v %v", owners, err)
+	}
+	if _, err = k.Resource(&v1.Pod{}).Namespace("default").Name("missing").Owners(); err == nil {
+		t.Errorf("expected error for missing object")
+	}
+}
+
+func TestDependents(t *testing.T) {
+	k := ownedObjectsCluster("owner-dependents-cluster")
+	lists := 0
+	metadataOnly := true
+	_ = k.Callback().List().Before("fake:list").Register("test:count-list", func(k *Kubectl) error {
+		lists++
+		metadataOnly = metadataOnly && k.Statement.MetadataOnly
+		return nil
+	})
+
+	dependents, err := k.GVK("example.com", "v1", "MyApp").Namespace("default").Name("web").Dependents(0)
+	if err != nil {
+		t.Fatalf("dependents failed: %v", err)
+	}
+	// 按层级返回，同一层级按 Kind、名称排序，同时被多个 owner 拥有的资源只返回一次
+	expected := "ConfigMap/web-config,Deployment/web,Pod/web-7d4b9c-b,ReplicaSet/web-7d4b9c,Pod/web-7d4b9c-a"
+	if names := objectNames(dependents); names != expected {
+		t.Errorf("unexpected dependents %s", names)
+	}
+	built := lists
+	if built == 0 {
+		t.Fatalf("index should be built by listing resources")
+	}
+	if !metadataOnly {
+		t.Errorf("index should be built from metadata-only lists")
+	}
+
+	dependents, err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("web").Dependents(1)
+	if err != nil || objectNames(dependents) != "ReplicaSet/web-7d4b9c" {
+		t.Errorf("unexpected direct dependents %s %v", objectNames(dependents), err)
+	}
+	dependents, err = k.Resource(&appsv1.ReplicaSet{}).Namespace("default").Name("web-7d4b9c").Dependents(2)
+	if err != nil || objectNames(dependents) != "Pod/web-7d4b9c-a,Pod/web-7d4b9c-b" {
+		t.Errorf("pods in other namespaces should not be returned, got %s %v", objectNames(dependents), err)
+	}
+	if lists != built {
+		t.Errorf("repeated lookups should use the index, listed %d times", lists-built)
+	}
+
+	// 修改返回的对象不影响索引
+	dependents[0].SetName("changed")
+	dependents, _ = k.Resource(&appsv1.ReplicaSet{}).Namespace("default").Name("web-7d4b9c").Dependents(1)
+	if dependents[0].GetName() != "web-7d4b9c-a" {
+		t.Errorf("index should not be modified by callers")
+	}
+
+	// 缓存清除后重建索引
+	k.Tools().ClearCache()
+	if _, err = k.WithCache(time.Minute).Resource(&appsv1.Deployment{}).Namespace("default").Name("web").Dependents(0); err != nil {
+		t.Fatalf("dependents failed: %v", err)
+	}
+	if lists == built {
+		t.Errorf("index should be rebuilt after cache cleared")
+	}
+}
+
+func TestDependentsClusterScoped(t *testing.T) {
+	ns := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", UID: "uid-ns"}}
+	binding := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "team-admin", UID: "uid-binding",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("v1", "Namespace", "team", "uid-ns", false)}}}
+	config := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "team-config", Namespace: "team", UID: "uid-config",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("v1", "Namespace", "team", "uid-ns", true)}}}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "team-pod", Namespace: "team", UID: "uid-pod",
+		OwnerReferences: []metav1.OwnerReference{ownerRef("v1", "ConfigMap", "team-config", "uid-config", true)}}}
+	k := RegisterFakeCluster("owner-cluster-scoped-cluster", ns, binding, config, pod)
+
+	// 集群级资源拥有的命名空间级资源同样返回
+	dependents, err := k.Resource(&v1.Namespace{}).Name("team").Dependents(0)
+	if err != nil {
+		t.Fatalf("dependents failed: %v", err)
+	}
+	expected := "ClusterRoleBinding/team-admin,ConfigMap/team-config,Pod/team-pod"
+	if names := objectNames(dependents); names != expected {
+		t.Errorf("unexpected dependents %s", names)
+	}
+}
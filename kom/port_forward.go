@@ -0,0 +1,546 @@
+package kom
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/duke-git/lancet/v2/random"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+)
+
+// ForwardedPort 端口转发实际使用的端口
+type ForwardedPort struct {
+	Local  uint16 `json:"local"`  // 本地监听端口
+	Remote uint16 `json:"remote"` // Pod 端口
+}
+
+// PortForwardRequest 端口转发参数，由 StartPortForward 设置到 Statement 中，供 PortForward callback 使用
+type PortForwardRequest struct {
+	Addresses []string                    // 监听地址，为空时只监听 localhost
+	Ports     []string                    // 端口映射，格式为 本地端口:Pod端口，本地端口为 0 时随机分配
+	StopCh    chan struct{}               // 关闭后停止转发
+	Out       io.Writer                   // 转发日志输出，为空时丢弃
+	ErrOut    io.Writer                   // 转发错误输出，为空时丢弃
+	OnReady   func(ports []ForwardedPort) // 开始监听后回调实际使用的端口
+}
+
+// PortForwardOption StartPortForward 配置项
+type PortForwardOption func(*portForwardOptions)
+
+type portForwardOptions struct {
+	ports         []string
+	addresses     []string
+	out           io.Writer
+	errOut        io.Writer
+	retryInterval time.Duration // 重新选择 Pod 的初始间隔
+}
+
+// PortForwardPorts 设置端口映射，可设置多个
+// 格式为 本地端口:远端端口，本地端口为 0 或省略（如 :80）时随机分配，只写一个端口时本地端口与远端端口相同
+// 远端端口可以是端口号或端口名称；转发 Service 时远端端口为 Service 的端口，会按 targetPort 转换为 Pod 端口
+func PortForwardPorts(ports ...string) PortForwardOption {
+	return func(o *portForwardOptions) {
+		o.ports = append(o.ports, ports...)
+	}
+}
+
+// PortForwardAddresses 设置本地监听地址，默认只监听 localhost
+// 监听 0.0.0.0 会将 Pod 端口暴露给所有能访问本机的网络，请谨慎使用
+func PortForwardAddresses(addresses ...string) PortForwardOption {
+	return func(o *portForwardOptions) {
+		o.addresses = addresses
+	}
+}
+
+// PortForwardOutput 设置转发日志的输出，默认丢弃
+func PortForwardOutput(out, errOut io.Writer) PortForwardOption {
+	return func(o *portForwardOptions) {
+		o.out = out
+		o.errOut = errOut
+	}
+}
+
+// PortForwardRetryInterval 设置 Pod 失效后重新选择 Pod 的间隔，默认 1 秒，连续失败时逐步加大，最大 30 秒
+func PortForwardRetryInterval(d time.Duration) PortForwardOption {
+	return func(o *portForwardOptions) {
+		if d > 0 {
+			o.retryInterval = d
+		}
+	}
+}
+
+const portForwardMaxRetryInterval = 30 * time.Second
+
+// portForwardPort 解析后的端口映射
+type portForwardPort struct {
+	local  int    // 本地端口，-1 表示与远端端口相同
+	remote string // 远端端口号或名称
+}
+
+// PortForwardSession 端口转发会话
+type PortForwardSession struct {
+	ID        string    `json:"id"`
+	Cluster   string    `json:"cluster"`
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"` // Pod、Service、Deployment、StatefulSet、DaemonSet、ReplicaSet
+	Name      string    `json:"name"`
+	Addresses []string  `json:"addresses"`
+	StartedAt time.Time `json:"startedAt"`
+
+	kubectl *Kubectl
+	gvk     schema.GroupVersionKind
+	options *portForwardOptions
+	specs   []portForwardPort
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopCh  chan struct{}
+	ready   chan struct{}
+	errCh   chan error
+	done    chan struct{}
+
+	readyOnce sync.Once
+	mu        sync.RWMutex
+	pod       string
+	ports     []ForwardedPort
+}
+
+// StartPortForward 启动端口转发并立即返回会话，转发在后台执行
+// 支持 Pod、Service、Deployment、StatefulSet、DaemonSet、ReplicaSet，非 Pod 资源会选择一个就绪的 Pod 进行转发，
+// 该 Pod 失效后自动重新选择 Pod 并继续使用原来的本地端口。
+// 会话在调用 Stop、WithContext 设置的上下文取消，或者转发的 Pod 失效且无法恢复时结束。
+// 每次连接 Pod 都会执行 PortForward() 类型的 callbacks。
+//
+// Example:
+//
+//	session, err := kom.DefaultCluster().Resource(&v1.Service{}).Namespace("default").Name("web").
+//		StartPortForward(kom.PortForwardPorts("0:80", "8443:https"))
+//	<-session.Ready()
+//	if len(session.Ports()) == 0 {
+//		// 就绪前会话已结束，如本地端口被占用
+//		return <-session.Err()
+//	}
+//	fmt.Println(session.Ports())
+//	defer session.Stop()
+func (k *Kubectl) StartPortForward(opts ...PortForwardOption) (*PortForwardSession, error) {
+	if k.Error != nil {
+		return nil, k.Error
+	}
+	options := &portForwardOptions{
+		addresses:     []string{"localhost"},
+		retryInterval: time.Second,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	specs, err := parsePortForwardPorts(options.ports)
+	if err != nil {
+		return nil, err
+	}
+	if len(options.addresses) == 0 {
+		return nil, fmt.Errorf("port forward: addresses is empty")
+	}
+
+	stmt := k.Statement
+	gvk := stmt.GVK
+	if gvk.Kind == "" {
+		gvk = v1.SchemeGroupVersion.WithKind("Pod")
+	}
+	switch gvk.Kind {
+	case "Pod", "Service", "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+	default:
+		return nil, fmt.Errorf("port forward: unsupported kind %s", gvk.Kind)
+	}
+	if stmt.Name == "" {
+		return nil, fmt.Errorf("port forward: %s name is required", gvk.Kind)
+	}
+	namespace := stmt.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	parent := stmt.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	s := &PortForwardSession{
+		ID:        "pf-" + strings.ToLower(random.RandString(8)),
+		Namespace: namespace,
+		Kind:      gvk.Kind,
+		Name:      stmt.Name,
+		Addresses: options.addresses,
+		StartedAt: time.Now(),
+		kubectl:   k,
+		gvk:       gvk,
+		options:   options,
+		specs:     specs,
+		ctx:       ctx,
+		cancel:    cancel,
+		stopCh:    make(chan struct{}),
+		ready:     make(chan struct{}),
+		errCh:     make(chan error, 1),
+		done:      make(chan struct{}),
+	}
+	cluster := k.parentCluster()
+	if cluster != nil {
+		s.Cluster = cluster.ID
+	}
+
+	// 首次选择 Pod 失败时直接返回错误
+	pod, ports, err := s.resolve("")
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if cluster != nil {
+		cluster.portForwards.Store(s.ID, s)
+	}
+	go func() {
+		<-ctx.Done()
+		close(s.stopCh)
+	}()
+	go s.run(cluster, pod, ports)
+	return s, nil
+}
+
+// parsePortForwardPorts 解析端口映射
+func parsePortForwardPorts(ports []string) ([]portForwardPort, error) {
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("port forward: ports is empty")
+	}
+	specs := make([]portForwardPort, 0, len(ports))
+	for _, p := range ports {
+		spec := portForwardPort{local: -1, remote: p}
+		if local, remote, found := strings.Cut(p, ":"); found {
+			spec.remote = remote
+			spec.local = 0
+			if local != "" {
+				n, err := strconv.ParseUint(local, 10, 16)
+				if err != nil {
+					return nil, fmt.Errorf("port forward: invalid local port in %q", p)
+				}
+				spec.local = int(n)
+			}
+		}
+		if spec.remote == "" {
+			return nil, fmt.Errorf("port forward: invalid port %q", p)
+		}
+		if n, err := strconv.Atoi(spec.remote); err == nil && (n <= 0 || n > 65535) {
+			return nil, fmt.Errorf("port forward: invalid remote port in %q", p)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// resolve 选择转发的 Pod 并计算端口映射，exclude 为失效的 Pod，存在其他就绪 Pod 时不再选择
+func (s *PortForwardSession) resolve(exclude string) (*v1.Pod, []string, error) {
+	k := s.kubectl
+	if s.Kind == "Pod" {
+		var pod v1.Pod
+		if err := k.newInstance().WithContext(s.ctx).Resource(&pod).Namespace(s.Namespace).Name(s.Name).Get(&pod).Error; err != nil {
+			return nil, nil, err
+		}
+		if pod.Status.Phase != v1.PodRunning {
+			return nil, nil, fmt.Errorf("port forward: pod %s/%s is not running, current phase %s", s.Namespace, s.Name, pod.Status.Phase)
+		}
+		ports, err := s.podPorts(&pod, nil)
+		return &pod, ports, err
+	}
+
+	var service *v1.Service
+	var selector map[string]string
+	if s.Kind == "Service" {
+		service = &v1.Service{}
+		if err := k.newInstance().WithContext(s.ctx).Resource(service).Namespace(s.Namespace).Name(s.Name).Get(service).Error; err != nil {
+			return nil, nil, err
+		}
+		selector = service.Spec.Selector
+	} else {
+		var workload *unstructured.Unstructured
+		if err := k.newInstance().WithContext(s.ctx).GVK(s.gvk.Group, s.gvk.Version, s.gvk.Kind).
+			Namespace(s.Namespace).Name(s.Name).Get(&workload).Error; err != nil {
+			return nil, nil, err
+		}
+		selector, _, _ = unstructured.NestedStringMap(workload.Object, "spec", "selector", "matchLabels")
+	}
+	if len(selector) == 0 {
+		return nil, nil, fmt.Errorf("port forward: %s %s/%s has no selector", s.Kind, s.Namespace, s.Name)
+	}
+
+	var pods []*v1.Pod
+	if err := k.newInstance().WithContext(s.ctx).Resource(&v1.Pod{}).Namespace(s.Namespace).
+		WithLabelSelector(labels.SelectorFromSet(selector).String()).List(&pods).Error; err != nil {
+		return nil, nil, err
+	}
+	var candidates []*v1.Pod
+	for _, pod := range pods {
+		if isPortForwardReady(pod) {
+			candidates = append(candidates, pod)
+		}
+	}
+	if len(candidates) > 1 && exclude != "" {
+		for i, pod := range candidates {
+			if pod.Name == exclude {
+				candidates = append(candidates[:i], candidates[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, fmt.Errorf("port forward: no ready pod found for %s %s/%s", s.Kind, s.Namespace, s.Name)
+	}
+	// 优先选择运行时间最长的 Pod
+	sort.Slice(candidates, func(i, j int) bool {
+		ti, tj := candidates[i].CreationTimestamp, candidates[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	ports, err := s.podPorts(candidates[0], service)
+	return candidates[0], ports, err
+}
+
+// isPortForwardReady Pod 是否可以转发：运行中、已就绪且未在删除
+func isPortForwardReady(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podPorts 计算转发到 Pod 的端口映射，格式为 本地端口:Pod端口
+// 已经分配过本地端口时继续使用原来的端口，保证重新选择 Pod 后本地地址不变
+func (s *PortForwardSession) podPorts(pod *v1.Pod, service *v1.Service) ([]string, error) {
+	s.mu.RLock()
+	assigned := s.ports
+	s.mu.RUnlock()
+
+	ports := make([]string, 0, len(s.specs))
+	for i, spec := range s.specs {
+		local := spec.local
+		target := intstr.Parse(spec.remote)
+		if service != nil {
+			var found *v1.ServicePort
+			for j := range service.Spec.Ports {
+				sp := &service.Spec.Ports[j]
+				if sp.Name == spec.remote || strconv.Itoa(int(sp.Port)) == spec.remote {
+					found = sp
+					break
+				}
+			}
+			if found == nil {
+				return nil, fmt.Errorf("port forward: service %s/%s has no port %s", service.Namespace, service.Name, spec.remote)
+			}
+			if local < 0 {
+				local = int(found.Port)
+			}
+			target = found.TargetPort
+			if target.IntValue() == 0 && target.Type == intstr.Int {
+				target = intstr.FromInt32(found.Port)
+			}
+		}
+		remote, err := containerPortOf(pod, target)
+		if err != nil {
+			return nil, err
+		}
+		if local < 0 {
+			local = remote
+		}
+		if len(assigned) == len(s.specs) {
+			local = int(assigned[i].Local)
+		}
+		ports = append(ports, fmt.Sprintf("%d:%d", local, remote))
+	}
+	return ports, nil
+}
+
+// containerPortOf 将端口号或端口名称转换为 Pod 的端口号
+func containerPortOf(pod *v1.Pod, port intstr.IntOrString) (int, error) {
+	if port.Type == intstr.Int {
+		return port.IntValue(), nil
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == port.StrVal {
+				return int(p.ContainerPort), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("port forward: pod %s/%s has no port named %s", pod.Namespace, pod.Name, port.StrVal)
+}
+
+// run 在后台执行转发，非 Pod 资源在 Pod 失效后重新选择 Pod
+func (s *PortForwardSession) run(cluster *ClusterInst, pod *v1.Pod, ports []string) {
+	var err error
+	defer func() {
+		if cluster != nil {
+			cluster.portForwards.Delete(s.ID)
+		}
+		if err != nil {
+			s.errCh <- err
+		}
+		close(s.errCh)
+		// 就绪前结束时同样关闭 ready，避免等待 Ready 的调用方一直阻塞
+		s.readyOnce.Do(func() {
+			close(s.ready)
+		})
+		s.cancel()
+		close(s.done)
+	}()
+
+	interval := s.options.retryInterval
+	for {
+		err = s.forward(pod.Name, ports)
+		if s.ctx.Err() != nil {
+			// 会话已停止
+			err = nil
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("port forward: connection to pod %s/%s closed", s.Namespace, pod.Name)
+		}
+		if s.Kind == "Pod" || !s.isReady() {
+			return
+		}
+		klog.V(2).Infof("port forward %s to pod %s/%s lost: %v, selecting another pod", s.ID, s.Namespace, pod.Name, err)
+
+		failed := pod.Name
+		for {
+			select {
+			case <-s.ctx.Done():
+				err = nil
+				return
+			case <-time.After(interval):
+			}
+			pod, ports, err = s.resolve(failed)
+			if err == nil {
+				interval = s.options.retryInterval
+				break
+			}
+			klog.V(2).Infof("port forward %s select pod error: %v", s.ID, err)
+			interval *= 2
+			if interval > portForwardMaxRetryInterval {
+				interval = portForwardMaxRetryInterval
+			}
+		}
+	}
+}
+
+// forward 通过 PortForward() callbacks 转发到指定的 Pod，阻塞直到停止或连接中断
+func (s *PortForwardSession) forward(podName string, ports []string) error {
+	s.mu.Lock()
+	s.pod = podName
+	s.mu.Unlock()
+
+	tx := s.kubectl.newInstance().WithContext(s.ctx).Resource(&v1.Pod{}).Namespace(s.Namespace).Name(podName)
+	tx.Statement.PortForwardRequest = &PortForwardRequest{
+		Addresses: s.Addresses,
+		Ports:     ports,
+		StopCh:    s.stopCh,
+		Out:       s.options.out,
+		ErrOut:    s.options.errOut,
+		OnReady: func(forwarded []ForwardedPort) {
+			s.mu.Lock()
+			s.ports = forwarded
+			s.mu.Unlock()
+			s.readyOnce.Do(func() {
+				close(s.ready)
+			})
+		},
+	}
+	return tx.Callback().PortForward().Execute(tx)
+}
+
+func (s *PortForwardSession) isReady() bool {
+	select {
+	case <-s.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Ready 首次开始监听本地端口时关闭，之后可通过 Ports 获取实际使用的端口。
+// 会话在就绪前结束（如本地端口被占用）时同样关闭，此时 Ports 为空，错误通过 Err 获取
+func (s *PortForwardSession) Ready() <-chan struct{} {
+	return s.ready
+}
+
+// Err 会话结束时关闭，异常结束时会先发送一个错误；调用 Stop 或上下文取消时没有错误
+func (s *PortForwardSession) Err() <-chan error {
+	return s.errCh
+}
+
+// Done 会话结束时关闭
+func (s *PortForwardSession) Done() <-chan struct{} {
+	return s.done
+}
+
+// Stop 停止转发，关闭本地端口并等待后台执行结束
+func (s *PortForwardSession) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// Pod 当前转发的 Pod 名称
+func (s *PortForwardSession) Pod() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pod
+}
+
+// Ports 实际使用的端口，就绪前为空
+func (s *PortForwardSession) Ports() []ForwardedPort {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]ForwardedPort(nil), s.ports...)
+}
+
+// PortForwardSessions 当前集群运行中的端口转发会话，按启动时间排序
+func (k *Kubectl) PortForwardSessions() []*PortForwardSession {
+	cluster := k.parentCluster()
+	if cluster == nil {
+		return nil
+	}
+	var sessions []*PortForwardSession
+	cluster.portForwards.Range(func(_, value any) bool {
+		sessions = append(sessions, value.(*PortForwardSession))
+		return true
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].StartedAt.Equal(sessions[j].StartedAt) {
+			return sessions[i].StartedAt.Before(sessions[j].StartedAt)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+	return sessions
+}
+
+// stopPortForwards 停止集群上所有的端口转发会话
+func (c *ClusterInst) stopPortForwards() {
+	c.portForwards.Range(func(_, value any) bool {
+		value.(*PortForwardSession).Stop()
+		return true
+	})
+}
@@ -0,0 +1,241 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// readyPod 创建运行中的 Pod，ready 为就绪状态，age 越大创建时间越早
+func readyPod(name string, labels map[string]string, age time.Duration, ready bool) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels, CreationTimestamp: metav1.NewTime(time.Now().Add(-age))},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:  "app",
+			Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+		}}},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: status}}
+	return pod
+}
+
+// recordPortForward 记录每次转发的 Pod 及端口映射
+func recordPortForward(k *Kubectl, handler func(k *Kubectl) error) chan string {
+	requests := make(chan string, 10)
+	_ = k.Callback().PortForward().Before("fake:port-forward").Register("test:port-forward", func(k *Kubectl) error {
+		req := k.Statement.PortForwardRequest
+		requests <- k.Statement.Name + " " + strings.Join(req.Ports, ",") + " " + strings.Join(req.Addresses, ",")
+		if handler != nil {
+			return handler(k)
+		}
+		return nil
+	})
+	return requests
+}
+
+func waitPortForwardReady(t *testing.T, s *PortForwardSession) {
+	select {
+	case <-s.Ready():
+		if len(s.Ports()) == 0 {
+			t.Fatalf("port forward failed: %v", <-s.Err())
+		}
+	case err := <-s.Err():
+		t.Fatalf("port forward failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("port forward is not ready")
+	}
+}
+
+func TestPortForwardService(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: v1.ServiceSpec{
+			Selector: web,
+			Ports: []v1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromString("http")},
+				{Name: "metrics", Port: 9090, TargetPort: intstr.FromInt32(9100)},
+			},
+		},
+	}
+	k := RegisterFakeCluster("port-forward-service-cluster", svc,
+		readyPod("web-old", web, 3*time.Hour, false),
+		readyPod("web-1", web, 2*time.Hour, true),
+		readyPod("web-2", web, time.Hour, true),
+	)
+	requests := recordPortForward(k, nil)
+
+	s, err := k.Resource(&v1.Service{}).Namespace("default").Name("web").
+		StartPortForward(PortForwardPorts("0:80", "metrics"), PortForwardAddresses("127.0.0.1"))
+	if err != nil {
+		t.Fatalf("start port forward failed: %v", err)
+	}
+	waitPortForwardReady(t, s)
+	if got := <-requests; got != "web-1 0:8080,9090:9100 127.0.0.1" {
+		t.Errorf("unexpected port forward request %q", got)
+	}
+	expected := []ForwardedPort{{Local: 40000, Remote: 8080}, {Local: 9090, Remote: 9100}}
+	if !reflect.DeepEqual(s.Ports(), expected) || s.Pod() != "web-1" {
+		t.Errorf("unexpected ports %v on pod %s", s.Ports(), s.Pod())
+	}
+	if sessions := k.PortForwardSessions(); len(sessions) != 1 || sessions[0] != s || sessions[0].Cluster != "port-forward-service-cluster" {
+		t.Errorf("unexpected sessions %v", sessions)
+	}
+
+	s.Stop()
+	if err, ok := <-s.Err(); ok || err != nil {
+		t.Errorf("stop should not report error, got %v", err)
+	}
+	if sessions := k.PortForwardSessions(); len(sessions) != 0 {
+		t.Errorf("stopped session should be removed, got %v", sessions)
+	}
+}
+
+func TestPortForwardReselectPod(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: web}},
+	}
+	k := RegisterFakeCluster("port-forward-reselect-cluster", deploy,
+		readyPod("web-1", web, 2*time.Hour, true),
+		readyPod("web-2", web, time.Hour, true),
+	)
+	// web-1 在就绪后断开连接，模拟 Pod 被删除
+	requests := recordPortForward(k, func(k *Kubectl) error {
+		if k.Statement.Name != "web-1" {
+			return nil
+		}
+		k.Statement.PortForwardRequest.OnReady([]ForwardedPort{{Local: 40123, Remote: 8080}})
+		return errors.New("lost connection to pod")
+	})
+
+	s, err := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("web").
+		StartPortForward(PortForwardPorts(":http"), PortForwardRetryInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("start port forward failed: %v", err)
+	}
+	defer s.Stop()
+	waitPortForwardReady(t, s)
+
+	if got := <-requests; got != "web-1 0:8080 localhost" {
+		t.Errorf("unexpected first request %q", got)
+	}
+	select {
+	case got := <-requests:
+		// 重新选择 Pod 后继续使用原来的本地端口
+		if got != "web-2 40123:8080 localhost" {
+			t.Errorf("unexpected request after pod lost %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("pod was not reselected")
+	}
+	select {
+	case err := <-s.Err():
+		t.Fatalf("session should keep running, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPortForwardPodLost(t *testing.T) {
+	k := RegisterFakeCluster("port-forward-pod-cluster", readyPod("web-0", nil, time.Hour, true))
+	recordPortForward(k, func(k *Kubectl) error {
+		k.Statement.PortForwardRequest.OnReady([]ForwardedPort{{Local: 8080, Remote: 8080}})
+		return errors.New("lost connection to pod")
+	})
+
+	s, err := k.Resource(&v1.Pod{}).Namespace("default").Name("web-0").StartPortForward(PortForwardPorts("8080"))
+	if err != nil {
+		t.Fatalf("start port forward failed: %v", err)
+	}
+	select {
+	case err = <-s.Err():
+		if err == nil || !strings.Contains(err.Error(), "lost connection") {
+			t.Errorf("expected lost connection error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session should end when pod is lost")
+	}
+	<-s.Done()
+}
+
+func TestPortForwardPortInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	k := RegisterFakeCluster("port-forward-in-use-cluster", readyPod("web-0", nil, time.Hour, true))
+	// 与实际转发一样先监听本地端口，端口被占用时不会调用 OnReady
+	recordPortForward(k, func(k *Kubectl) error {
+		local, _, _ := strings.Cut(k.Statement.PortForwardRequest.Ports[0], ":")
+		l, err := net.Listen("tcp", "127.0.0.1:"+local)
+		if err != nil {
+			return fmt.Errorf("unable to listen on any of the requested ports: %w", err)
+		}
+		return l.Close()
+	})
+
+	s, err := k.Resource(&v1.Pod{}).Namespace("default").Name("web-0").StartPortForward(PortForwardPorts(fmt.Sprintf("%d:8080", port)))
+	if err != nil {
+		t.Fatalf("start port forward failed: %v", err)
+	}
+	select {
+	case <-s.Ready():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ready should be closed when the session ends before listening")
+	}
+	if ports := s.Ports(); len(ports) != 0 {
+		t.Errorf("ports should be empty, got %v", ports)
+	}
+	if err := <-s.Err(); err == nil || !strings.Contains(err.Error(), "unable to listen") {
+		t.Errorf("expected listen error, got %v", err)
+	}
+	<-s.Done()
+}
+
+func TestPortForwardInvalid(t *testing.T) {
+	pending := readyPod("pending", nil, time.Hour, false)
+	pending.Status.Phase = v1.PodPending
+	k := RegisterFakeCluster("port-forward-invalid-cluster", readyPod("web-0", nil, time.Hour, true), pending,
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "default"}})
+
+	cases := []struct {
+		name    string
+		kubectl *Kubectl
+		opts    []PortForwardOption
+	}{
+		{"no ports", k.Resource(&v1.Pod{}).Namespace("default").Name("web-0"), nil},
+		{"invalid local port", k.Resource(&v1.Pod{}).Namespace("default").Name("web-0"), []PortForwardOption{PortForwardPorts("abc:80")}},
+		{"invalid remote port", k.Resource(&v1.Pod{}).Namespace("default").Name("web-0"), []PortForwardOption{PortForwardPorts("8080:70000")}},
+		{"unknown port name", k.Resource(&v1.Pod{}).Namespace("default").Name("web-0"), []PortForwardOption{PortForwardPorts("grpc")}},
+		{"pod not running", k.Resource(&v1.Pod{}).Namespace("default").Name("pending"), []PortForwardOption{PortForwardPorts("80")}},
+		{"no name", k.Resource(&v1.Pod{}).Namespace("default"), []PortForwardOption{PortForwardPorts("80")}},
+		{"no selector", k.Resource(&v1.Service{}).Namespace("default").Name("headless"), []PortForwardOption{PortForwardPorts("80")}},
+		{"unsupported kind", k.Resource(&v1.ConfigMap{}).Namespace("default").Name("web"), []PortForwardOption{PortForwardPorts("80")}},
+	}
+	for _, c := range cases {
+		if _, err := c.kubectl.StartPortForward(c.opts...); err == nil {
+			t.Errorf("%s: expected error", c.name)
+		}
+	}
+	if sessions := k.PortForwardSessions(); len(sessions) != 0 {
+		t.Errorf("failed sessions should not be registered: %v", sessions)
+	}
+}
@@ -0,0 +1,222 @@
+package kom
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"path"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// DefaultSecretMask Secret 脱敏后的默认占位值
+const DefaultSecretMask = "******"
+
+// SecretRedactPolicy Secret 脱敏策略
+// 启用后，Get、List 读取 Secret 时 data、stringData 中匹配的 key 的值将被替换为掩码，
+// last-applied-configuration 注解中记录的 Secret 按相同的 key 规则脱敏，
+// LinkedEnv 中来源于 Secret 的环境变量值同样会被替换。
+type SecretRedactPolicy struct {
+	KeyPatterns []string // 需要脱敏的 key，支持通配符（path.Match 语法，如 *password*、tls.*），为空表示全部脱敏
+	Mask        string   // 掩码，为空时使用 DefaultSecretMask
+}
+
+// NewSecretRedactPolicy 创建脱敏策略，keyPatterns 为空表示全部脱敏
+func NewSecretRedactPolicy(keyPatterns ...string) *SecretRedactPolicy {
+	return &SecretRedactPolicy{KeyPatterns: keyPatterns}
+}
+
+// Match 判断 key 是否需要脱敏
+func (p *SecretRedactPolicy) Match(key string) bool {
+	if p == nil {
+		return false
+	}
+	if len(p.KeyPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range p.KeyPatterns {
+		if ok, err := path.Match(pattern, key); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SecretRedactPolicy) mask() string {
+	if p.Mask == "" {
+		return DefaultSecretMask
+	}
+	return p.Mask
+}
+
+// RedactSecret 对 Secret 进行脱敏，直接修改传入的对象
+func (p *SecretRedactPolicy) RedactSecret(secret *v1.Secret) {
+	if p == nil || secret == nil {
+		return
+	}
+	for key := range secret.Data {
+		if p.Match(key) {
+			secret.Data[key] = []byte(p.mask())
+		}
+	}
+	for key := range secret.StringData {
+		if p.Match(key) {
+			secret.StringData[key] = p.mask()
+		}
+	}
+	if value, ok := secret.Annotations[v1.LastAppliedConfigAnnotation]; ok {
+		secret.Annotations[v1.LastAppliedConfigAnnotation] = p.redactLastApplied(value)
+	}
+}
+
+// redactLastApplied 对 last-applied-configuration 注解中记录的 Secret 进行脱敏，无法解析时整体替换为掩码
+func (p *SecretRedactPolicy) redactLastApplied(value string) string {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return p.mask()
+	}
+	p.redactJSON(raw)
+	bytes, err := json.Marshal(raw)
+	if err != nil {
+		return p.mask()
+	}
+	return string(bytes)
+}
+
+// redactJSON 对 JSON 结构的 Secret、Secret 数组或 SecretList 进行脱敏
+func (p *SecretRedactPolicy) redactJSON(v interface{}) {
+	switch val := v.(type) {
+	case []interface{}:
+		for _, item := range val {
+			p.redactJSON(item)
+		}
+	case map[string]interface{}:
+		if items, ok := val["items"].([]interface{}); ok {
+			p.redactJSON(items)
+			return
+		}
+		if data, ok := val["data"].(map[string]interface{}); ok {
+			encoded := base64.StdEncoding.EncodeToString([]byte(p.mask()))
+			for key, item := range data {
+				if item != nil && p.Match(key) {
+					data[key] = encoded
+				}
+			}
+		}
+		if data, ok := val["stringData"].(map[string]interface{}); ok {
+			for key, item := range data {
+				if item != nil && p.Match(key) {
+					data[key] = p.mask()
+				}
+			}
+		}
+		// kubectl apply 及三路合并应用会在注解中记录完整的 Secret
+		if metadata, ok := val["metadata"].(map[string]interface{}); ok {
+			if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+				if value, ok := annotations[v1.LastAppliedConfigAnnotation].(string); ok {
+					annotations[v1.LastAppliedConfigAnnotation] = p.redactLastApplied(value)
+				}
+			}
+		}
+	}
+}
+
+// RedactSecrets 本次调用启用 Secret 脱敏，keyPatterns 为空表示全部脱敏
+// 集群注册时可通过 RegisterRedactSecrets 统一启用
+func (k *Kubectl) RedactSecrets(keyPatterns ...string) *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.RedactPolicy = NewSecretRedactPolicy(keyPatterns...)
+	return tx
+}
+
+// RevealSecrets 本次调用显示 Secret 明文，忽略集群及调用级别的脱敏策略
+// 属于特权操作，Statement.RevealSecrets 在回调中可见，可通过回调进行鉴权拦截
+func (k *Kubectl) RevealSecrets() *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.RevealSecrets = true
+	return tx
+}
+
+// secretRedactPolicy 获取本次调用生效的脱敏策略，不需要脱敏时返回 nil
+func (k *Kubectl) secretRedactPolicy() *SecretRedactPolicy {
+	stmt := k.Statement
+	if stmt.RevealSecrets {
+		return nil
+	}
+	if stmt.RedactPolicy != nil {
+		return stmt.RedactPolicy
+	}
+	if cluster := k.parentCluster(); cluster != nil {
+		return cluster.redactPolicy
+	}
+	return nil
+}
+
+// redactSecretsCallback 读取 Secret 后按策略脱敏，注册在 get、list 处理器的最后
+// 通过 JSON 重新生成结果，不修改缓存中的对象
+func redactSecretsCallback(k *Kubectl) error {
+	stmt := k.Statement
+	if stmt.GVK.Kind != "Secret" || stmt.GVK.Group != "" || stmt.Dest == nil {
+		return nil
+	}
+	policy := k.secretRedactPolicy()
+	if policy == nil {
+		return nil
+	}
+	bytes, err := json.Marshal(stmt.Dest)
+	if err != nil {
+		return err
+	}
+	var raw interface{}
+	if err = json.Unmarshal(bytes, &raw); err != nil {
+		return err
+	}
+	policy.redactJSON(raw)
+	if bytes, err = json.Marshal(raw); err != nil {
+		return err
+	}
+	resetDest(stmt.Dest)
+	if err = json.Unmarshal(bytes, stmt.Dest); err != nil {
+		klog.V(6).Infof("redact secret error %v", err)
+		return err
+	}
+	return nil
+}
+
+// registerRedactHandlers 注册 Secret 脱敏回调
+func (cs *callbacks) registerRedactHandlers() {
+	_ = cs.Get().After("*").Register("kom:redact", redactSecretsCallback)
+	_ = cs.List().After("*").Register("kom:redact", redactSecretsCallback)
+}
+
+// secretEnvMatcher 判断容器中的环境变量是否来源于需要脱敏的 Secret key
+func (p *pod) secretEnvMatcher(container v1.Container, policy *SecretRedactPolicy) func(name string) bool {
+	names := map[string]bool{}
+	for _, env := range container.Env {
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && policy.Match(env.ValueFrom.SecretKeyRef.Key) {
+			names[env.Name] = true
+		}
+	}
+	for _, envFrom := range container.EnvFrom {
+		if envFrom.SecretRef == nil || envFrom.SecretRef.Name == "" {
+			continue
+		}
+		var secret v1.Secret
+		err := p.kubectl.newInstance().WithContext(p.kubectl.Statement.Context).
+			Resource(&v1.Secret{}).
+			Namespace(p.kubectl.Statement.Namespace).
+			Name(envFrom.SecretRef.Name).
+			Get(&secret).Error
+		if err != nil {
+			// 无法获取 Secret 的 key 列表时，无法区分来源，保守处理：视为全部来源于 Secret 的变量均需脱敏
+			klog.V(6).Infof("get secret %s for env redaction error %v", envFrom.SecretRef.Name, err)
+			return func(name string) bool { return true }
+		}
+		for key := range secret.Data {
+			if policy.Match(key) {
+				names[envFrom.Prefix+key] = true
+			}
+		}
+	}
+	return func(name string) bool { return names[name] }
+}
@@ -0,0 +1,122 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newRedactSecret() *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data: map[string][]byte{
+			"password": []byte("p@ss"),
+			"username": []byte("admin"),
+		},
+	}
+}
+
+func TestRedactSecretsPerCall(t *testing.T) {
+	k := RegisterFakeCluster("redact-call-cluster", newRedactSecret())
+
+	// 默认不脱敏
+	var plain v1.Secret
+	if err := k.Resource(&plain).Namespace("default").Name("db").Get(&plain).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	if string(plain.Data["password"]) != "p@ss" {
+		t.Errorf("secret should not be redacted by default, got %s", plain.Data["password"])
+	}
+
+	// 按 key 模式脱敏
+	var item v1.Secret
+	if err := k.RedactSecrets("pass*").Resource(&item).Namespace("default").Name("db").Get(&item).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	if string(item.Data["password"]) != DefaultSecretMask || string(item.Data["username"]) != "admin" {
+		t.Errorf("unexpected redacted data %v", item.Data)
+	}
+
+	// unstructured 同样生效
+	var u *unstructured.Unstructured
+	if err := k.RedactSecrets().Resource(&v1.Secret{}).Namespace("default").Name("db").Get(&u).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	if v, _, _ := unstructured.NestedString(u.Object, "data", "username"); v != "KioqKioq" {
+		t.Errorf("unstructured data should be redacted, got %s", v)
+	}
+}
+
+func TestRedactSecretsCluster(t *testing.T) {
+	k := RegisterFakeCluster("redact-cluster", newRedactSecret())
+	Clusters().GetClusterById("redact-cluster").redactPolicy = NewSecretRedactPolicy()
+
+	var list []v1.Secret
+	if err := k.Resource(&v1.Secret{}).Namespace("default").List(&list).Error; err != nil {
+		t.Fatalf("list secrets failed: %v", err)
+	}
+	if len(list) != 1 || string(list[0].Data["password"]) != DefaultSecretMask || string(list[0].Data["username"]) != DefaultSecretMask {
+		t.Errorf("list should be redacted, got %v", list)
+	}
+
+	// 特权调用显示明文，且不影响缓存及后续调用
+	var item v1.Secret
+	if err := k.RevealSecrets().Resource(&item).Namespace("default").Name("db").Get(&item).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	if string(item.Data["password"]) != "p@ss" {
+		t.Errorf("reveal should return clear text, got %s", item.Data["password"])
+	}
+	var again v1.Secret
+	if err := k.Resource(&again).Namespace("default").Name("db").Get(&again).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	if string(again.Data["password"]) != DefaultSecretMask {
+		t.Errorf("reveal should only affect a single call, got %s", again.Data["password"])
+	}
+}
+
+func TestSecretRedactPolicyMatch(t *testing.T) {
+	p := NewSecretRedactPolicy("*password*", "tls.*")
+	for key, want := range map[string]bool{"db-password": true, "tls.key": true, "username": false} {
+		if got := p.Match(key); got != want {
+			t.Errorf("Match(%s)=%v, want %v", key, got, want)
+		}
+	}
+	var nilPolicy *SecretRedactPolicy
+	if nilPolicy.Match("password") {
+		t.Errorf("nil policy should not match")
+	}
+}
+
+func TestRedactSecretsLastApplied(t *testing.T) {
+	secret := newRedactSecret()
+	secret.Annotations = map[string]string{
+		v1.LastAppliedConfigAnnotation: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"db","namespace":"default"},"data":{"password":"cEBzcw==","username":"YWRtaW4="},"stringData":{"token":"t0ken"}}`,
+	}
+	k := RegisterFakeCluster("redact-last-applied-cluster", secret)
+	Clusters().GetClusterById("redact-last-applied-cluster").redactPolicy = NewSecretRedactPolicy("password", "token")
+
+	var item v1.Secret
+	if err := k.Resource(&item).Namespace("default").Name("db").Get(&item).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	lastApplied := item.Annotations[v1.LastAppliedConfigAnnotation]
+	if strings.Contains(lastApplied, "cEBzcw==") || strings.Contains(lastApplied, "t0ken") {
+		t.Errorf("last applied annotation should be redacted, got %s", lastApplied)
+	}
+	if !strings.Contains(lastApplied, "YWRtaW4=") || !strings.Contains(lastApplied, DefaultSecretMask) {
+		t.Errorf("only matched keys should be redacted, got %s", lastApplied)
+	}
+
+	// 无法解析的注解整体替换为掩码
+	p := NewSecretRedactPolicy()
+	broken := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{v1.LastAppliedConfigAnnotation: "{"}}}
+	p.RedactSecret(broken)
+	if broken.Annotations[v1.LastAppliedConfigAnnotation] != DefaultSecretMask {
+		t.Errorf("unparsable annotation should be masked, got %s", broken.Annotations[v1.LastAppliedConfigAnnotation])
+	}
+}
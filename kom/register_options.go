@@ -1,31 +1,39 @@
 package kom
 
 import (
-    "net/http"
-    "net/url"
-    "time"
+	"net/http"
+	"net/url"
+	"time"
 
-    "github.com/dgraph-io/ristretto/v2"
-    "k8s.io/client-go/rest"
+	"github.com/dgraph-io/ristretto/v2"
+	"k8s.io/client-go/rest"
 )
 
 // RegisterParams carries registration-time options only. It is consumed during cluster registration
 // and is NOT stored on Kubectl or ClusterInst for post-registration usage.
 type RegisterParams struct {
-    // rest.Config options
-    ProxyURL      string
-    ProxyFunc     func(*http.Request) (*url.URL, error)
-    Timeout       time.Duration
-    QPS           *float32
-    Burst         *int
-    UserAgent     string
-    TLSInsecure   bool
-    CACert        []byte
-    Impersonation *rest.ImpersonationConfig
-
-    // cluster initialization options
-    DisableCRDWatch bool
-    CacheConfig     *ristretto.Config[string, any]
+	// rest.Config options
+	ProxyURL      string
+	ProxyFunc     func(*http.Request) (*url.URL, error)
+	Timeout       time.Duration
+	QPS           *float32
+	Burst         *int
+	UserAgent     string
+	TLSInsecure   bool
+	CACert        []byte
+	Impersonation *rest.ImpersonationConfig
+
+	// cluster initialization options
+	DisableCRDWatch bool
+	CacheConfig     *ristretto.Config[string, any]
+	CacheMetrics    bool
+
+	// access restriction options
+	ReadOnly          bool
+	AllowedNamespaces []string
+
+	// read redaction options
+	RedactSecrets *SecretRedactPolicy
 }
 
 // RegisterOption is the registration-time only option.
@@ -33,61 +41,87 @@ type RegisterOption func(*RegisterParams)
 
 // RegisterProxyURL sets HTTP proxy URL for client requests.
 func RegisterProxyURL(u string) RegisterOption {
-    return func(p *RegisterParams) { p.ProxyURL = u }
+	return func(p *RegisterParams) { p.ProxyURL = u }
 }
 
 // RegisterProxyFunc sets custom proxy function.
 func RegisterProxyFunc(fn func(*http.Request) (*url.URL, error)) RegisterOption {
-    return func(p *RegisterParams) { p.ProxyFunc = fn }
+	return func(p *RegisterParams) { p.ProxyFunc = fn }
 }
 
 // RegisterTimeout sets request timeout on rest.Config.
 func RegisterTimeout(d time.Duration) RegisterOption {
-    return func(p *RegisterParams) { p.Timeout = d }
+	return func(p *RegisterParams) { p.Timeout = d }
 }
 
 // RegisterQPS sets QPS for rest.Config.
 func RegisterQPS(q float32) RegisterOption {
-    return func(p *RegisterParams) { p.QPS = &q }
+	return func(p *RegisterParams) { p.QPS = &q }
 }
 
 // RegisterBurst sets Burst for rest.Config.
 func RegisterBurst(b int) RegisterOption {
-    return func(p *RegisterParams) { p.Burst = &b }
+	return func(p *RegisterParams) { p.Burst = &b }
 }
 
 // RegisterUserAgent sets custom user-agent for rest.Config.
 func RegisterUserAgent(ua string) RegisterOption {
-    return func(p *RegisterParams) { p.UserAgent = ua }
+	return func(p *RegisterParams) { p.UserAgent = ua }
 }
 
 // RegisterTLSInsecure enables insecure TLS on rest.Config.
 func RegisterTLSInsecure() RegisterOption {
-    return func(p *RegisterParams) { p.TLSInsecure = true }
+	return func(p *RegisterParams) { p.TLSInsecure = true }
 }
 
 // RegisterCACert sets CAData for TLS verification.
 func RegisterCACert(pem []byte) RegisterOption {
-    return func(p *RegisterParams) { p.CACert = pem }
+	return func(p *RegisterParams) { p.CACert = pem }
 }
 
 // RegisterImpersonation sets impersonation config on rest.Config.
 func RegisterImpersonation(user string, groups []string, extra map[string][]string) RegisterOption {
-    return func(p *RegisterParams) {
-        p.Impersonation = &rest.ImpersonationConfig{
-            UserName: user,
-            Groups:   groups,
-            Extra:    extra,
-        }
-    }
+	return func(p *RegisterParams) {
+		p.Impersonation = &rest.ImpersonationConfig{
+			UserName: user,
+			Groups:   groups,
+			Extra:    extra,
+		}
+	}
 }
 
 // RegisterDisableCRDWatch disables CRD watching during initialization.
 func RegisterDisableCRDWatch() RegisterOption {
-    return func(p *RegisterParams) { p.DisableCRDWatch = true }
+	return func(p *RegisterParams) { p.DisableCRDWatch = true }
 }
 
 // RegisterCacheConfig sets custom cache configuration for the cluster.
 func RegisterCacheConfig(cfg *ristretto.Config[string, any]) RegisterOption {
-    return func(p *RegisterParams) { p.CacheConfig = cfg }
-}
\ No newline at end of file
+	return func(p *RegisterParams) { p.CacheConfig = cfg }
+}
+
+// RegisterCacheMetrics enables hit/miss statistics on the cluster cache, as exported by the metrics collector.
+// Statistics add overhead to every cache access, so they are off by default.
+func RegisterCacheMetrics() RegisterOption {
+	return func(p *RegisterParams) { p.CacheMetrics = true }
+}
+
+// RegisterReadOnly registers the cluster as read-only.
+// All mutations (create/update/patch/delete/exec/port-forward and the Ctl helpers built on them)
+// are rejected with ErrClusterReadOnly, regardless of what the kubeconfig itself allows.
+func RegisterReadOnly() RegisterOption {
+	return func(p *RegisterParams) { p.ReadOnly = true }
+}
+
+// RegisterAllowedNamespaces restricts mutations to the given namespaces.
+// Mutations in other namespaces, as well as on cluster-scoped resources, are rejected with ErrNamespaceNotAllowed.
+func RegisterAllowedNamespaces(namespaces ...string) RegisterOption {
+	return func(p *RegisterParams) { p.AllowedNamespaces = append(p.AllowedNamespaces, namespaces...) }
+}
+
+// RegisterRedactSecrets masks Secret data/stringData and secret-sourced env values in all read paths.
+// keyPatterns are path.Match patterns on keys, e.g. "*password*"; empty means all keys.
+// Use Kubectl.RevealSecrets() to read clear text explicitly.
+func RegisterRedactSecrets(keyPatterns ...string) RegisterOption {
+	return func(p *RegisterParams) { p.RedactSecrets = NewSecretRedactPolicy(keyPatterns...) }
+}
@@ -0,0 +1,139 @@
+package kom
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// UpdateWithRetry 基于最新版本更新资源，遇到冲突(409)时按退避策略自动重试
+// 每次尝试都会重新获取最新对象到 dest，调用 mutate 修改 dest 后再提交更新，
+// 因此 mutate 中的修改必须基于 dest 进行，且可重复执行。
+// 读取时忽略 Secret 脱敏策略，避免将掩码写回集群，dest 中为 Secret 的明文。
+// Example:
+// var item v1.Deployment
+//
+//	err := kom.DefaultCluster().Resource(&item).Namespace("default").Name("nginx").
+//		UpdateWithRetry(&item, func(obj interface{}) error {
+//			item.Spec.Replicas = ptr.To(int32(3))
+//			return nil
+//		}).Error
+func (k *Kubectl) UpdateWithRetry(dest interface{}, mutate func(obj interface{}) error) *Kubectl {
+	tx := k.getInstance()
+	tx.fillNameFromObj(dest)
+	// 必须读取最新版本，不能使用缓存
+	tx.Statement.CacheTTL = 0
+	// 读取结果用于写回，Secret 不能脱敏，否则会将掩码写入
+	tx.Statement.RevealSecrets = true
+	tx.Error = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		resetDest(dest)
+		if err := tx.Get(dest).Error; err != nil {
+			return err
+		}
+		if mutate != nil {
+			if err := mutate(dest); err != nil {
+				return err
+			}
+		}
+		return tx.Update(dest).Error
+	})
+	return tx
+}
+
+// PatchWithRetry 基于最新版本生成 PATCH 数据并提交，遇到冲突(409)时按退避策略自动重试
+// 每次尝试都会重新获取最新对象，并调用 patchFn 生成 PATCH 数据，patchFn 返回空字符串表示无需变更。
+// 对于 Merge Patch 和 Strategic Merge Patch，若 PATCH 数据中未指定 metadata.resourceVersion，
+// 会自动填入读取到的版本号，确保基于读取结果计算出的变更不会覆盖并发修改。
+// 读取时忽略 Secret 脱敏策略，传给 patchFn 的为 Secret 的明文。
+// Example:
+//
+//	err := kom.DefaultCluster().Resource(&v1.Deployment{}).Namespace("default").Name("nginx").
+//		PatchWithRetry(&item, types.MergePatchType, func(current *unstructured.Unstructured) (string, error) {
+//			return `{"spec":{"replicas":3}}`, nil
+//		}).Error
+func (k *Kubectl) PatchWithRetry(dest interface{}, pt types.PatchType, patchFn func(current *unstructured.Unstructured) (string, error)) *Kubectl {
+	tx := k.getInstance()
+	tx.fillNameFromObj(dest)
+	tx.Statement.CacheTTL = 0
+	// patchFn 基于读取结果生成 PATCH 数据，Secret 不能脱敏
+	tx.Statement.RevealSecrets = true
+	tx.Error = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var current *unstructured.Unstructured
+		if err := tx.Get(&current).Error; err != nil {
+			return err
+		}
+		data, err := patchFn(current)
+		if err != nil {
+			return err
+		}
+		if data == "" {
+			// 无需变更，返回当前对象
+			return fillDest(current, dest)
+		}
+		data, err = withResourceVersion(pt, data, current.GetResourceVersion())
+		if err != nil {
+			return err
+		}
+		return tx.Patch(dest, pt, data).Error
+	})
+	return tx
+}
+
+// fillNameFromObj 未指定名称时，从对象中读取名称及命名空间
+func (k *Kubectl) fillNameFromObj(obj interface{}) {
+	if k.Statement.Name != "" || obj == nil {
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil || accessor.GetName() == "" {
+		return
+	}
+	k.Statement.Name = accessor.GetName()
+	if k.Statement.Namespace == "" {
+		k.Statement.Namespace = accessor.GetNamespace()
+	}
+}
+
+// resetDest 将 dest 指向的对象置为零值，避免重新获取时残留上一次的修改
+func resetDest(dest interface{}) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().CanSet() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
+
+// fillDest 将 unstructured 对象写入 dest
+func fillDest(obj *unstructured.Unstructured, dest interface{}) error {
+	if obj == nil || dest == nil {
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, dest)
+}
+
+// withResourceVersion 为 Merge Patch、Strategic Merge Patch 补充 metadata.resourceVersion，作为乐观锁
+// 其他类型的 PATCH 数据原样返回
+func withResourceVersion(pt types.PatchType, data string, resourceVersion string) (string, error) {
+	if resourceVersion == "" || (pt != types.MergePatchType && pt != types.StrategicMergePatchType) {
+		return data, nil
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &patch); err != nil {
+		return "", err
+	}
+	if _, found, _ := unstructured.NestedFieldNoCopy(patch, "metadata", "resourceVersion"); found {
+		return data, nil
+	}
+	if err := unstructured.SetNestedField(patch, resourceVersion, "metadata", "resourceVersion"); err != nil {
+		return "", err
+	}
+	bytes, err := json.Marshal(patch)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
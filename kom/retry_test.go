@@ -0,0 +1,178 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/utils/ptr"
+)
+
+// injectConflicts 让指定动作的前 n 次调用返回 409 冲突
+func injectConflicts(t *testing.T, k *Kubectl, verb, resource string, n int) *int {
+	client, ok := Clusters().GetClusterById(k.ID).DynamicClient.(*fakeDryRunClient)
+	if !ok {
+		t.Fatalf("unexpected dynamic client")
+	}
+	calls := 0
+	client.PrependReactor(verb, resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+		calls++
+		if calls <= n {
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: resource}, "retry-deploy", nil)
+		}
+		return false, nil, nil
+	})
+	return &calls
+}
+
+func newRetryDeploy() *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "retry-deploy", Namespace: "default", ResourceVersion: "1"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To(int32(2)),
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{Containers: []v1.Container{{Name: "nginx", Image: "nginx:1.0"}}},
+			},
+		},
+	}
+}
+
+func TestUpdateWithRetry(t *testing.T) {
+	k := RegisterFakeCluster("retry-update-cluster", newRetryDeploy())
+	calls := injectConflicts(t, k, "update", "deployments", 2)
+
+	mutations := 0
+	var item appsv1.Deployment
+	err := k.Resource(&item).Namespace("default").Name("retry-deploy").UpdateWithRetry(&item, func(obj interface{}) error {
+		mutations++
+		item.Spec.Replicas = ptr.To(*item.Spec.Replicas + 1)
+		return nil
+	}).Error
+	if err != nil {
+		t.Fatalf("UpdateWithRetry failed: %v", err)
+	}
+	if *calls != 3 || mutations != 3 {
+		t.Errorf("expected 3 attempts, got update calls=%d mutations=%d", *calls, mutations)
+	}
+
+	var current appsv1.Deployment
+	if err = k.Resource(&current).Namespace("default").Name("retry-deploy").Get(&current).Error; err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	// 每次都基于最新对象修改，只应增加一次
+	if *current.Spec.Replicas != 3 {
+		t.Errorf("expected replicas 3, got %d", *current.Spec.Replicas)
+	}
+
+	// Ctl 操作同样在冲突时重试
+	injectConflicts(t, k, "update", "deployments", 1)
+	if _, err = k.Resource(&appsv1.Deployment{}).Namespace("default").Name("retry-deploy").Ctl().Deployment().ReplaceImageTag("nginx", "2.0"); err != nil {
+		t.Fatalf("ReplaceImageTag failed: %v", err)
+	}
+	if err = k.Resource(&current).Namespace("default").Name("retry-deploy").Get(&current).Error; err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if current.Spec.Template.Spec.Containers[0].Image != "nginx:2.0" {
+		t.Errorf("expected image nginx:2.0, got %s", current.Spec.Template.Spec.Containers[0].Image)
+	}
+}
+
+func TestUpdateWithRetryGiveUp(t *testing.T) {
+	k := RegisterFakeCluster("retry-giveup-cluster", newRetryDeploy())
+	injectConflicts(t, k, "update", "deployments", 100)
+
+	var item appsv1.Deployment
+	err := k.Resource(&item).Namespace("default").Name("retry-deploy").UpdateWithRetry(&item, nil).Error
+	if !apierrors.IsConflict(err) {
+		t.Errorf("expected conflict error after retries exhausted, got %v", err)
+	}
+}
+
+func TestPatchWithRetry(t *testing.T) {
+	k := RegisterFakeCluster("retry-patch-cluster", newRetryDeploy())
+	calls := injectConflicts(t, k, "patch", "deployments", 1)
+
+	if err := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("retry-deploy").Ctl().Scaler().Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("expected 2 patch calls, got %d", *calls)
+	}
+	var current appsv1.Deployment
+	if err := k.Resource(&current).Namespace("default").Name("retry-deploy").Get(&current).Error; err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if *current.Spec.Replicas != 0 || current.Annotations["kom.restore.replicas"] != "2" {
+		t.Errorf("unexpected deployment after stop: replicas=%d annotations=%v", *current.Spec.Replicas, current.Annotations)
+	}
+
+	// patchFn 返回空表示无需变更，不应发送 PATCH
+	before := *calls
+	var item *unstructured.Unstructured
+	err := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("retry-deploy").
+		PatchWithRetry(&item, types.MergePatchType, func(current *unstructured.Unstructured) (string, error) {
+			return "", nil
+		}).Error
+	if err != nil || *calls != before || item == nil || item.GetName() != "retry-deploy" {
+		t.Errorf("noop patch: err=%v calls=%d item=%v", err, *calls-before, item)
+	}
+}
+
+func TestRetryRedactedSecret(t *testing.T) {
+	k := RegisterFakeCluster("retry-secret-cluster", &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("p@ss")},
+	})
+	Clusters().GetClusterById("retry-secret-cluster").redactPolicy = NewSecretRedactPolicy()
+
+	var item v1.Secret
+	err := k.Resource(&item).Namespace("default").Name("db").UpdateWithRetry(&item, func(obj interface{}) error {
+		item.Labels = map[string]string{"updated": "true"}
+		return nil
+	}).Error
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	var seen string
+	err = k.Resource(&v1.Secret{}).Namespace("default").Name("db").PatchWithRetry(&item, types.MergePatchType, func(current *unstructured.Unstructured) (string, error) {
+		seen, _, _ = unstructured.NestedString(current.Object, "data", "password")
+		return `{"metadata":{"labels":{"patched":"true"}}}`, nil
+	}).Error
+	if err != nil {
+		t.Fatalf("patch failed: %v", err)
+	}
+	if seen != "cEBzcw==" {
+		t.Errorf("patchFn should see clear text, got %s", seen)
+	}
+
+	var secret v1.Secret
+	if err := k.RevealSecrets().Resource(&secret).Namespace("default").Name("db").Get(&secret).Error; err != nil {
+		t.Fatalf("get secret failed: %v", err)
+	}
+	if string(secret.Data["password"]) != "p@ss" || secret.Labels["updated"] != "true" || secret.Labels["patched"] != "true" {
+		t.Errorf("masked values should not be written back, got %v %v", secret.Data, secret.Labels)
+	}
+}
+
+func TestWithResourceVersion(t *testing.T) {
+	data, err := withResourceVersion(types.MergePatchType, `{"spec":{"replicas":1}}`, "42")
+	if err != nil || !strings.Contains(data, `"resourceVersion":"42"`) {
+		t.Errorf("resourceVersion should be injected, got %s %v", data, err)
+	}
+	data, _ = withResourceVersion(types.StrategicMergePatchType, `{"metadata":{"resourceVersion":"7"}}`, "42")
+	if !strings.Contains(data, `"7"`) {
+		t.Errorf("existing resourceVersion should be kept, got %s", data)
+	}
+	jsonPatch := `[{"op":"replace","path":"/spec/replicas","value":1}]`
+	if data, _ = withResourceVersion(types.JSONPatchType, jsonPatch, "42"); data != jsonPatch {
+		t.Errorf("json patch should be unchanged, got %s", data)
+	}
+}
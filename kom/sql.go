@@ -76,12 +76,30 @@ func (k *Kubectl) AllNamespace() *Kubectl {
 	tx.Statement.AllNamespace = true
 	return tx
 }
+
+// DryRun 服务端试运行
+// Create、Update、Patch、Delete 等变更操作会携带 dryRun=All 参数提交到 API Server，
+// 经过准入控制等完整校验流程，但不会真正落库，返回的对象为准入变更后的结果。
+// Applier 及 Ctl() 下的 Scale、Rollout、Label 等操作同样生效。
+func (k *Kubectl) DryRun() *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.DryRun = true
+	return tx
+}
 func (k *Kubectl) RemoveManagedFields() *Kubectl {
 	tx := k.getInstance()
 	tx.Statement.RemoveManagedFields = true
 	return tx
 }
 
+// MetadataOnly List 时只获取资源的 metadata（PartialObjectMetadataList），返回的对象只有 apiVersion、kind 及 metadata，
+// 适合只关心名称、标签、ownerReferences 的场景，可大幅减少大集群上的传输及内存占用
+func (k *Kubectl) MetadataOnly() *Kubectl {
+	tx := k.getInstance()
+	tx.Statement.MetadataOnly = true
+	return tx
+}
+
 // ContainerName
 // Deprecated: use Ctl().Pod().ContainerName() instead.
 func (k *Kubectl) ContainerName(c string) *Kubectl {
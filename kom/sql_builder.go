@@ -120,26 +120,12 @@ func (k *Kubectl) Where(condition string, values ...interface{}) *Kubectl {
 
 	tx.Statement.Filter.Sql = sql
 
-	stmt, err := sqlparser.Parse(sql)
+	conditions, err := parseWhereSql(sql)
 	if err != nil {
-		klog.Errorf("Error parsing SQL:%s,%v", sql, err)
 		tx.Error = err
 		return tx
 	}
 
-	var conditions []*Condition // 存储解析后的条件
-
-	// 断言为 *sqlparser.Select 类型
-	selectStmt, ok := stmt.(*sqlparser.Select)
-	if !ok {
-		klog.Errorf("not select parsing SQL:%s,%v", sql, err)
-		tx.Error = err
-		return tx
-	}
-
-	// 解析Where语句，获得执行条件
-	conditions = parseWhereExpr(conditions, 0, "AND", selectStmt.Where.Expr)
-
 	// 探测 conditions中的条件值类型
 	for i, cond := range conditions {
 		conditions[i].ValueType, conditions[i].Value = utils.DetectType(cond.Value)
@@ -152,6 +138,25 @@ func (k *Kubectl) Where(condition string, values ...interface{}) *Kubectl {
 	return tx
 }
 
+// parseWhereSql 解析 select 语句中的 Where 条件，条件值保持原始字符串
+func parseWhereSql(sql string) ([]*Condition, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		klog.Errorf("Error parsing SQL:%s,%v", sql, err)
+		return nil, err
+	}
+
+	// 断言为 *sqlparser.Select 类型
+	selectStmt, ok := stmt.(*sqlparser.Select)
+	if !ok || selectStmt.Where == nil {
+		klog.Errorf("not select parsing SQL:%s", sql)
+		return nil, fmt.Errorf("not a select statement with where clause: %s", sql)
+	}
+
+	// 解析Where语句，获得执行条件
+	return parseWhereExpr(nil, 0, "AND", selectStmt.Where.Expr), nil
+}
+
 // formatSql
 //
 //	select * from pod where pod.name='?', 'abc'
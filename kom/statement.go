@@ -32,7 +32,10 @@ type Statement struct {
 	Dest                 interface{}                  `json:"dest,omitempty"`                // 返回结果存放对象，一般为结构体指针
 	PatchType            types.PatchType              `json:"patchType,omitempty"`           // PATCH类型
 	PatchData            string                       `json:"patchData,omitempty"`           // PATCH数据
+	FieldManager         string                       `json:"fieldManager,omitempty"`        // PATCH 时使用的字段管理器名称，服务端应用时必填
+	ForceConflicts       bool                         `json:"forceConflicts,omitempty"`      // 服务端应用时强制接管其他字段管理器的字段
 	RemoveManagedFields  bool                         `json:"removeManagedFields,omitempty"` // 是否移除管理字段
+	MetadataOnly         bool                         `json:"metadataOnly,omitempty"`        // List 只获取 apiVersion、kind 及 metadata
 	useCustomGVK         bool                         `json:"-"`                             // 如果通过CRD方法设置了GVK，那么就强制使用，不再进行GVK的自动解析
 	ContainerName        string                       `json:"containerName,omitempty"`       // 容器名称，执行获取容器内日志等操作使用
 	Command              string                       `json:"command,omitempty"`             // 容器内执行命令,包括ls、cat以及用户输入的命令
@@ -41,15 +44,32 @@ type Statement struct {
 	PodLogOptions        *v1.PodLogOptions            `json:"-" `                            // 获取容器日志使用
 	Stdin                io.Reader                    `json:"-" `                            // 设置输入
 	StreamOptions        *remotecommand.StreamOptions `json:"-"`                             // 设置Stream 参数
+	ExecTTY              *bool                        `json:"execTTY,omitempty"`             // 显式指定是否分配 TTY，为空时有 stdin 即分配 TTY，StartExec 使用
 	Filter               Filter                       `json:"filter,omitempty"`
 	StdoutCallback       func(data []byte) error      `json:"-"`
 	StderrCallback       func(data []byte) error      `json:"-"`
-	CacheTTL             time.Duration                `json:"cacheTTL,omitempty"`    // 设置缓存时间
-	ForceDelete          bool                         `json:"forceDelete,omitempty"` // 强制删除标志
+	CacheTTL             time.Duration                `json:"cacheTTL,omitempty"`      // 设置缓存时间
+	ForceDelete          bool                         `json:"forceDelete,omitempty"`   // 强制删除标志
+	DryRun               bool                         `json:"dryRun,omitempty"`        // 服务端试运行，变更类操作只校验不落库
+	CtlAction            *CtlAction                   `json:"ctlAction,omitempty"`     // Ctl 高层操作的意图及参数，如 drain、rollout undo
+	RedactPolicy         *SecretRedactPolicy          `json:"-"`                       // 本次调用的 Secret 脱敏策略，优先于集群级别的策略
+	RevealSecrets        bool                         `json:"revealSecrets,omitempty"` // 显示 Secret 明文，忽略脱敏策略
 	PortForwardLocalPort string                       `json:"port_forward_local_port"`
 	PortForwardPodPort   string                       `json:"port_forward_pod_port"`
 	PortForwardStopCh    chan struct{}                `json:"-"`
+	PortForwardRequest   *PortForwardRequest          `json:"-"` // 端口转发参数，设置后优先于 PortForwardLocalPort 等参数
+	execStarts           []time.Time                  // 正在执行的处理器的开始时间，Ctl 高层操作内嵌套执行时按栈记录
 }
+
+// StartedAt 当前正在执行的处理器的开始时间，在回调（包括收尾回调）中使用，未在执行时返回零值
+// Ctl 高层操作内部的 get、patch 等处理器与外层使用同一个 Statement，嵌套执行时内外层的开始时间互不覆盖
+func (s *Statement) StartedAt() time.Time {
+	if len(s.execStarts) == 0 {
+		return time.Time{}
+	}
+	return s.execStarts[len(s.execStarts)-1]
+}
+
 type Filter struct {
 	Columns    []string     `json:"columns,omitempty"`
 	Conditions []*Condition `json:"condition,omitempty"` // xx=?
@@ -0,0 +1,360 @@
+package kom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/klog/v2"
+)
+
+// 终端消息类型
+const (
+	TerminalStdin  = "stdin"  // 客户端发送，终端输入
+	TerminalResize = "resize" // 客户端发送，调整终端尺寸
+	TerminalClose  = "close"  // 客户端发送，结束会话
+	TerminalStdout = "stdout" // 服务端发送，终端输出
+	TerminalStderr = "stderr" // 服务端发送，错误输出，仅在关闭 TTY 时出现
+	TerminalExit   = "exit"   // 服务端发送，命令结束及退出码，之后服务端关闭连接
+	TerminalError  = "error"  // 服务端发送，连接节点或执行命令失败，之后服务端关闭连接
+)
+
+// TerminalMessage WebSocket 终端的消息帧，以 JSON 文本消息传输
+//
+//	{"type":"stdin","data":"ls\r"}
+//	{"type":"resize","cols":120,"rows":40}
+//	{"type":"stdout","data":"..."}
+//	{"type":"exit","code":0}
+type TerminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"` // stdin、stdout、stderr 的内容，error 的错误信息
+	Cols uint16 `json:"cols,omitempty"` // resize 的列数
+	Rows uint16 `json:"rows,omitempty"` // resize 的行数
+	Code *int   `json:"code,omitempty"` // exit 的退出码
+}
+
+// TerminalTarget 终端连接的目标
+type TerminalTarget struct {
+	Cluster    string   // 集群ID，为空时使用默认集群
+	Namespace  string   // Pod 所在的命名空间
+	Pod        string   // Pod 名称
+	Container  string   // 容器名称，Pod 只有一个容器时可以为空
+	Node       string   // 节点名称，设置后通过 CreateNodeShell 连接节点，忽略 Pod 相关设置
+	Image      string   // NodeShell 使用的镜像，为空时使用默认镜像
+	Command    []string // 执行的命令，为空时优先使用 bash，不存在时使用 sh
+	DisableTTY bool     // 不分配 TTY，stderr 单独输出
+}
+
+// terminalShell 默认执行的命令，优先使用 bash
+const terminalShell = "TERM=xterm-256color; export TERM; [ -x /bin/bash ] && exec /bin/bash || exec /bin/sh"
+
+func (t *TerminalTarget) command() []string {
+	if len(t.Command) > 0 {
+		return t.Command
+	}
+	if t.Node != "" {
+		// 进入宿主机的命名空间
+		return []string{"nsenter", "-t", "1", "-m", "-u", "-i", "-n", "-p", "--", "/bin/sh", "-c", terminalShell}
+	}
+	return []string{"/bin/sh", "-c", terminalShell}
+}
+
+// TerminalTargetFromQuery 从 URL 参数中获取连接目标，是 NewTerminalHandler 的默认实现
+// 参数为 cluster、namespace、pod、container、node、image、command（可重复），如
+// /terminal?namespace=default&pod=nginx&container=nginx
+// /terminal?node=kind-control-plane
+func TerminalTargetFromQuery(r *http.Request) (*TerminalTarget, error) {
+	q := r.URL.Query()
+	return &TerminalTarget{
+		Cluster:   q.Get("cluster"),
+		Namespace: q.Get("namespace"),
+		Pod:       q.Get("pod"),
+		Container: q.Get("container"),
+		Node:      q.Get("node"),
+		Image:     q.Get("image"),
+		Command:   q["command"],
+	}, nil
+}
+
+// TerminalOption NewTerminalHandler 配置项
+type TerminalOption func(*terminalHandler)
+
+// TerminalTargetFunc 设置从请求中获取连接目标的方法，默认为 TerminalTargetFromQuery
+// 返回错误时以 400 拒绝连接
+func TerminalTargetFunc(fn func(r *http.Request) (*TerminalTarget, error)) TerminalOption {
+	return func(h *terminalHandler) {
+		h.target = fn
+	}
+}
+
+// TerminalCheckOrigin 设置 WebSocket 跨域校验，默认只允许同源请求
+func TerminalCheckOrigin(fn func(r *http.Request) bool) TerminalOption {
+	return func(h *terminalHandler) {
+		h.upgrader.CheckOrigin = fn
+	}
+}
+
+type terminalHandler struct {
+	target   func(r *http.Request) (*TerminalTarget, error)
+	upgrader websocket.Upgrader
+}
+
+// NewTerminalHandler 创建 WebSocket 终端，连接 Pod 容器或节点
+//
+// 连接前通过 terminal 处理器执行 callback 链，集群的只读、命名空间限制以及自定义的 callback 可以拒绝连接，
+// 此时不升级为 WebSocket，以 403 返回错误。请求的 Context 会传递给 callback，可用于获取操作者身份。
+// 连接节点时会创建 NodeShell Pod，WebSocket 关闭后自动删除。
+//
+// Example:
+//
+//	http.Handle("/terminal", kom.NewTerminalHandler())
+//	// 自定义 callback 校验权限
+//	kom.DefaultCluster().Callback().Terminal().Before("kom:terminal").Register("auth", func(k *kom.Kubectl) error {
+//		return checkPermission(k.Statement.Context, k.Statement.Namespace, k.Statement.Name)
+//	})
+func NewTerminalHandler(opts ...TerminalOption) http.Handler {
+	h := &terminalHandler{
+		target: TerminalTargetFromQuery,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+		},
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(h)
+		}
+	}
+	return h
+}
+
+func (h *terminalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target, err := h.target(r)
+	if err == nil && target.Node == "" && (target.Namespace == "" || target.Pod == "") {
+		err = fmt.Errorf("namespace and pod, or node is required")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	kubectl := Cluster(target.Cluster)
+	if kubectl == nil {
+		http.Error(w, fmt.Sprintf("cluster %s not found", target.Cluster), http.StatusNotFound)
+		return
+	}
+
+	tx := kubectl.WithContext(r.Context())
+	action := "pod"
+	params := map[string]string{}
+	if target.Node != "" {
+		action = "node"
+		tx = tx.Resource(&v1.Node{}).Name(target.Node)
+		if target.Image != "" {
+			params["image"] = target.Image
+		}
+	} else {
+		cmd := target.command()
+		tx = tx.Resource(&v1.Pod{}).Namespace(target.Namespace).Name(target.Pod).
+			Ctl().Pod().ContainerName(target.Container).Command(cmd[0], cmd[1:]...).kubectl
+	}
+	if tx.Error != nil {
+		http.Error(w, tx.Error.Error(), http.StatusBadRequest)
+		return
+	}
+
+	upgraded := false
+	err = tx.execCtl("terminal", action, params, func() error {
+		upgraded = true
+		conn, err := h.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade 失败时已返回 HTTP 错误
+			return err
+		}
+		return newTerminalConn(conn).serve(tx, target)
+	})
+	if err != nil && !upgraded {
+		// callback 拒绝连接
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+}
+
+// terminalConn 一个 WebSocket 终端连接
+type terminalConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex // WebSocket 不支持并发写
+
+	mu      sync.Mutex
+	session *ExecSession
+	size    *remotecommand.TerminalSize // 会话启动前收到的终端尺寸
+}
+
+func newTerminalConn(conn *websocket.Conn) *terminalConn {
+	return &terminalConn{conn: conn}
+}
+
+// serve 连接目标并转发输入输出，直到命令结束或 WebSocket 关闭
+func (t *terminalConn) serve(tx *Kubectl, target *TerminalTarget) error {
+	defer t.conn.Close()
+	ctx, cancel := context.WithCancel(tx.Statement.Context)
+	defer cancel()
+	stdin, stdinWriter := io.Pipe()
+	defer stdin.Close()
+	go t.readLoop(cancel, stdinWriter)
+
+	ns, podName, container := target.Namespace, target.Pod, target.Container
+	if target.Node != "" {
+		var err error
+		ns, podName, container, err = tx.Ctl().Node().CreateNodeShell(nodeShellImage(target.Image)...)
+		if err != nil {
+			t.sendError(err)
+			return err
+		}
+		defer deleteNodeShell(tx, ns, podName)
+	}
+	cmd := target.command()
+	p := tx.newInstance().WithContext(ctx).Resource(&v1.Pod{}).Namespace(ns).Name(podName).
+		Ctl().Pod().ContainerName(container).Command(cmd[0], cmd[1:]...)
+
+	stdout := &terminalWriter{conn: t, typ: TerminalStdout}
+	stderr := &terminalWriter{conn: t, typ: TerminalStderr}
+	session, err := p.StartExec(ExecTTY(!target.DisableTTY), ExecStdin(stdin), ExecStdout(stdout), ExecStderr(stderr))
+	if err != nil {
+		t.sendError(err)
+		return err
+	}
+	t.mu.Lock()
+	t.session = session
+	if t.size != nil {
+		session.Resize(t.size.Width, t.size.Height)
+	}
+	t.mu.Unlock()
+
+	result, err := session.Wait()
+	stdout.flush()
+	stderr.flush()
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			// WebSocket 已关闭或客户端结束会话
+			return nil
+		}
+		t.sendError(err)
+		return err
+	}
+	code := result.ExitCode
+	t.send(&TerminalMessage{Type: TerminalExit, Code: &code})
+	t.writeMu.Lock()
+	_ = t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	t.writeMu.Unlock()
+	return nil
+}
+
+// readLoop 读取客户端消息，WebSocket 关闭或收到 close 消息时结束会话
+func (t *terminalConn) readLoop(cancel context.CancelFunc, stdin *io.PipeWriter) {
+	defer cancel()
+	defer stdin.Close()
+	for {
+		var msg TerminalMessage
+		if err := t.conn.ReadJSON(&msg); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				klog.V(6).Infof("terminal read error: %v", err)
+			}
+			return
+		}
+		switch msg.Type {
+		case TerminalStdin:
+			if _, err := io.WriteString(stdin, msg.Data); err != nil {
+				return
+			}
+		case TerminalResize:
+			t.resize(msg.Cols, msg.Rows)
+		case TerminalClose:
+			return
+		default:
+			klog.V(6).Infof("terminal ignored unknown message type %q", msg.Type)
+		}
+	}
+}
+
+func (t *terminalConn) resize(cols, rows uint16) {
+	if cols == 0 || rows == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session != nil {
+		t.session.Resize(cols, rows)
+		return
+	}
+	t.size = &remotecommand.TerminalSize{Width: cols, Height: rows}
+}
+
+func (t *terminalConn) send(msg *TerminalMessage) {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if err := t.conn.WriteJSON(msg); err != nil {
+		klog.V(6).Infof("terminal write error: %v", err)
+	}
+}
+
+func (t *terminalConn) sendError(err error) {
+	t.send(&TerminalMessage{Type: TerminalError, Data: err.Error()})
+}
+
+// terminalWriter 将输出转为消息帧，跨帧的不完整 UTF-8 字符留到下一次发送
+type terminalWriter struct {
+	conn    *terminalConn
+	typ     string
+	pending []byte
+}
+
+func (w *terminalWriter) Write(p []byte) (int, error) {
+	data := append(w.pending, p...)
+	n := len(data)
+	// 末尾最多 3 个字节可能是不完整的字符
+	for i := 1; i <= 3 && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				n = len(data) - i
+			}
+			break
+		}
+	}
+	w.pending = append([]byte(nil), data[n:]...)
+	if n > 0 {
+		w.conn.send(&TerminalMessage{Type: w.typ, Data: strings.ToValidUTF8(string(data[:n]), "�")})
+	}
+	return len(p), nil
+}
+
+func (w *terminalWriter) flush() {
+	if len(w.pending) > 0 {
+		w.conn.send(&TerminalMessage{Type: w.typ, Data: strings.ToValidUTF8(string(w.pending), "�")})
+		w.pending = nil
+	}
+}
+
+func nodeShellImage(image string) []string {
+	if image == "" {
+		return nil
+	}
+	return []string{image}
+}
+
+// deleteNodeShell 删除 NodeShell Pod，请求的 Context 此时已结束，使用新的 Context
+func deleteNodeShell(tx *Kubectl, ns, name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := tx.newInstance().WithContext(ctx).Resource(&v1.Pod{}).Namespace(ns).Name(name).ForceDelete().Error
+	if err != nil {
+		klog.Errorf("delete node shell %s/%s error: %v", ns, name, err)
+	}
+}
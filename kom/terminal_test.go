@@ -0,0 +1,189 @@
+package kom
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// dialTerminal 启动终端服务并建立 WebSocket 连接
+func dialTerminal(t *testing.T, query string) (*websocket.Conn, *http.Response, error) {
+	server := httptest.NewServer(NewTerminalHandler())
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/terminal?" + query
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if conn != nil {
+		t.Cleanup(func() { _ = conn.Close() })
+	}
+	return conn, resp, err
+}
+
+// readTerminal 读取消息直到 exit 或 error，返回输出内容及最后一条消息
+func readTerminal(t *testing.T, conn *websocket.Conn) (string, *TerminalMessage) {
+	_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	var out strings.Builder
+	for {
+		var msg TerminalMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read terminal message failed: %v, output %q", err, out.String())
+		}
+		switch msg.Type {
+		case TerminalStdout, TerminalStderr:
+			out.WriteString(msg.Data)
+		case TerminalExit, TerminalError:
+			return out.String(), &msg
+		}
+	}
+}
+
+func TestTerminalPod(t *testing.T) {
+	k := RegisterFakeCluster("terminal-pod-cluster", runningPod("web-0", nil, "app"))
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		if !opts.Tty || k.Statement.ContainerName != "app" || k.Statement.Command != "/bin/sh" {
+			t.Errorf("unexpected exec %s %v tty=%v", k.Statement.Command, k.Statement.Args, opts.Tty)
+		}
+		size := opts.TerminalSizeQueue.Next()
+		// 跨帧的中文字符
+		data := []byte(fmt.Sprintf("size %dx%d 你好\n", size.Width, size.Height))
+		_, _ = opts.Stdout.Write(data[:14])
+		_, _ = opts.Stdout.Write(data[14:])
+		buf := make([]byte, 64)
+		n, _ := opts.Stdin.Read(buf)
+		_, _ = fmt.Fprintf(opts.Stdout, "echo:%s", buf[:n])
+		return utilexec.CodeExitError{Err: errors.New("exit 2"), Code: 2}
+	})
+
+	conn, _, err := dialTerminal(t, "cluster=terminal-pod-cluster&namespace=default&pod=web-0&container=app")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	_ = conn.WriteJSON(&TerminalMessage{Type: TerminalResize, Cols: 120, Rows: 40})
+	_ = conn.WriteJSON(&TerminalMessage{Type: TerminalStdin, Data: "exit"})
+
+	out, last := readTerminal(t, conn)
+	if out != "size 120x40 你好\necho:exit" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if last.Type != TerminalExit || last.Code == nil || *last.Code != 2 {
+		t.Errorf("expected exit code 2, got %+v", last)
+	}
+}
+
+func TestTerminalClientClose(t *testing.T) {
+	k := RegisterFakeCluster("terminal-close-cluster", runningPod("web-0", nil, "app"))
+	closed := make(chan struct{})
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		_, _ = opts.Stdout.Write([]byte("$ "))
+		<-k.Statement.Context.Done()
+		close(closed)
+		return k.Statement.Context.Err()
+	})
+
+	conn, _, err := dialTerminal(t, "cluster=terminal-close-cluster&namespace=default&pod=web-0")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	var msg TerminalMessage
+	if err = conn.ReadJSON(&msg); err != nil || msg.Data != "$ " {
+		t.Fatalf("unexpected first message %+v, %v", msg, err)
+	}
+	_ = conn.WriteJSON(&TerminalMessage{Type: TerminalClose})
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("remote process was not closed")
+	}
+}
+
+func TestTerminalAuthorization(t *testing.T) {
+	k := RegisterFakeCluster("terminal-auth-cluster", runningPod("web-0", nil, "app"))
+	_ = k.Callback().Terminal().Before("kom:terminal").Register("test:auth", func(k *Kubectl) error {
+		a := k.Statement.CtlAction
+		if a.Action == "pod" && k.Statement.Name == "web-0" {
+			return fmt.Errorf("terminal to %s/%s is not allowed", k.Statement.Namespace, k.Statement.Name)
+		}
+		return nil
+	})
+
+	_, resp, err := dialTerminal(t, "cluster=terminal-auth-cluster&namespace=default&pod=web-0")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %v %v", resp, err)
+	}
+
+	_, resp, err = dialTerminal(t, "cluster=terminal-auth-cluster&pod=web-0")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without namespace, got %v %v", resp, err)
+	}
+	_, resp, err = dialTerminal(t, "cluster=terminal-missing-cluster&namespace=default&pod=web-0")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown cluster, got %v %v", resp, err)
+	}
+}
+
+func TestTerminalNodeShell(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	k := RegisterFakeCluster("terminal-node-cluster", node)
+	// fake 集群中 Pod 不会启动，获取时标记为就绪
+	var shellSpec v1.PodSpec
+	_ = k.Callback().Get().After("fake:get").Register("test:ready", func(k *Kubectl) error {
+		if p, ok := k.Statement.Dest.(**v1.Pod); ok && *p != nil && strings.HasPrefix((*p).Name, "node-shell-") {
+			shellSpec = (*p).Spec
+			(*p).Status.ContainerStatuses = []v1.ContainerStatus{{Name: "shell", Ready: true}}
+		}
+		return nil
+	})
+	shellPods := make(chan string, 1)
+	fakeStreamExecHandler(k, func(k *Kubectl, opts *remotecommand.StreamOptions) error {
+		shellPods <- k.Statement.Namespace + "/" + k.Statement.Name
+		if k.Statement.Command != "nsenter" || k.Statement.ContainerName != "shell" {
+			t.Errorf("unexpected node shell exec %s %v in %s", k.Statement.Command, k.Statement.Args, k.Statement.ContainerName)
+		}
+		_, _ = opts.Stdout.Write([]byte("root@node-1"))
+		return nil
+	})
+
+	conn, _, err := dialTerminal(t, "cluster=terminal-node-cluster&node=node-1")
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	out, last := readTerminal(t, conn)
+	if out != "root@node-1" || last.Type != TerminalExit || *last.Code != 0 {
+		t.Fatalf("unexpected node shell result %q %+v", out, last)
+	}
+
+	shellPod := <-shellPods
+	if !strings.HasPrefix(shellPod, "kube-system/node-shell-") {
+		t.Errorf("unexpected node shell pod %s", shellPod)
+	}
+	// 与 DebugNode 使用相同的节点调试 Pod
+	if shellSpec.NodeName != "node-1" || !shellSpec.HostPID || len(shellSpec.Tolerations) != 1 || len(shellSpec.Volumes) != 1 ||
+		shellSpec.Containers[0].Command[0] != "nsenter" || !*shellSpec.Containers[0].SecurityContext.Privileged {
+		t.Errorf("unexpected node shell pod spec %+v", shellSpec)
+	}
+
+	// 连接关闭后删除 NodeShell Pod
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var pods []*v1.Pod
+		if err = k.Resource(&v1.Pod{}).Namespace("kube-system").List(&pods).Error; err != nil {
+			t.Fatalf("list pods failed: %v", err)
+		}
+		if len(pods) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("node shell pod %s was not deleted", shellPod)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,446 @@
+package kom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog/v2"
+)
+
+// readyResyncPeriod Watch 中断或遗漏事件时的兜底检查间隔
+var readyResyncPeriod = 5 * time.Second
+
+// ReadyStatus 资源的就绪状态
+type ReadyStatus struct {
+	GVK       schema.GroupVersionKind `json:"gvk"`
+	Namespace string                  `json:"namespace,omitempty"`
+	Name      string                  `json:"name,omitempty"`
+	Ready     bool                    `json:"ready"`
+	Message   string                  `json:"message,omitempty"` // 当前状态说明，如 1 of 3 updated replicas are available
+	Error     error                   `json:"-"`                 // 获取失败，或资源已处于失败状态（如 Job 失败）
+}
+
+// String 输出文本状态，如 Deployment default/web not ready: 1 of 3 updated replicas are available
+func (s *ReadyStatus) String() string {
+	id := fmt.Sprintf("%s %s/%s", s.GVK.Kind, s.Namespace, s.Name)
+	if s.Namespace == "" {
+		id = fmt.Sprintf("%s %s", s.GVK.Kind, s.Name)
+	}
+	switch {
+	case s.Error != nil:
+		return fmt.Sprintf("%s failed: %v", id, s.Error)
+	case s.Ready:
+		return fmt.Sprintf("%s ready", id)
+	default:
+		return fmt.Sprintf("%s not ready: %s", id, s.Message)
+	}
+}
+
+// readyTarget 判断就绪时需要关注的对象，Service 关注同名的 Endpoints
+type readyTarget struct {
+	gvk             schema.GroupVersionKind
+	name            string
+	resourceVersion string
+}
+
+// WaitReady 等待当前资源就绪，超时或资源进入失败状态时返回错误，此时 ReadyStatus 中为最后一次检查的状态
+// 通过 Watch 感知资源变化，不做忙轮询。各类资源的就绪条件：
+//
+// Deployment、StatefulSet、DaemonSet：滚动更新完成，与 kubectl rollout status 一致
+// Job：Complete 条件为 True，Failed 时返回错误
+// PersistentVolumeClaim：状态为 Bound
+// Service：同名 Endpoints 中存在可用地址，ExternalName 及未设置 selector 的 Service 直接就绪
+// CustomResourceDefinition：Established 条件为 True
+// Pod：Ready 条件为 True，或已运行完成（Succeeded），Failed 时返回错误
+// ReplicaSet、ReplicationController：就绪副本数达到期望值；Namespace：Active；PersistentVolume：Available 或 Bound
+// ConfigMap、Secret、RBAC 等没有状态的资源：创建即就绪
+// 其他资源（包括自定义资源）：status.observedGeneration 不小于 metadata.generation，且 Ready 条件为 True；
+// 没有 Ready 条件时要求控制器已写入 status.observedGeneration，尚未写入 status 的资源视为未就绪
+//
+// Example:
+// status, err := kom.DefaultCluster().Resource(&v1.Deployment{}).Namespace("default").Name("web").WaitReady(time.Minute)
+func (k *Kubectl) WaitReady(timeout time.Duration) (*ReadyStatus, error) {
+	stmt := k.Statement
+	if stmt.GVK.Kind == "" || stmt.Name == "" {
+		return nil, fmt.Errorf("wait ready: resource kind and name are required")
+	}
+	ns := stmt.Namespace
+	if !stmt.Namespaced {
+		ns = ""
+	}
+	ctx, cancel := context.WithTimeout(stmt.Context, timeout)
+	defer cancel()
+	status := k.waitReady(ctx, stmt.GVK, ns, stmt.Name)
+	if err := readyError(ctx, timeout, []*ReadyStatus{status}); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+// readyError 汇总未就绪的资源
+func readyError(ctx context.Context, timeout time.Duration, statuses []*ReadyStatus) error {
+	var notReady []string
+	for _, s := range statuses {
+		if !s.Ready {
+			notReady = append(notReady, s.String())
+		}
+	}
+	if len(notReady) == 0 {
+		return nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("wait for ready timeout after %s: %s", timeout, strings.Join(notReady, "; "))
+	}
+	return fmt.Errorf("wait for ready failed: %s", strings.Join(notReady, "; "))
+}
+
+// waitReady 等待资源就绪，直到就绪、资源失败或 ctx 结束
+func (k *Kubectl) waitReady(ctx context.Context, gvk schema.GroupVersionKind, ns, name string) *ReadyStatus {
+	status := &ReadyStatus{GVK: gvk, Namespace: ns, Name: name}
+	for {
+		target := readyTarget{gvk: gvk, name: name}
+		var obj *unstructured.Unstructured
+		err := k.newInstance().WithContext(ctx).GVK(gvk.Group, gvk.Version, gvk.Kind).Namespace(ns).Name(name).Get(&obj).Error
+		switch {
+		case apierrors.IsNotFound(err):
+			status.Message = "not found"
+		case err != nil:
+			if ctx.Err() != nil {
+				// 超时或取消，保留上一次的状态
+				return status
+			}
+			status.Error = err
+			return status
+		default:
+			target.resourceVersion = obj.GetResourceVersion()
+			ready, message, failed := k.evaluateReady(ctx, obj, &target)
+			status.Ready, status.Message = ready, message
+			if failed != nil {
+				status.Error = failed
+				return status
+			}
+			if ready {
+				return status
+			}
+		}
+		klog.V(6).Infof("wait ready %s %s/%s: %s", gvk.Kind, ns, name, status.Message)
+		if !k.waitReadyChange(ctx, ns, target) {
+			return status
+		}
+	}
+}
+
+// waitReadyChange 等待关注对象发生变化，ctx 结束时返回 false
+// Watch 失败或长时间无事件时，按 readyResyncPeriod 重新检查
+func (k *Kubectl) waitReadyChange(ctx context.Context, ns string, target readyTarget) bool {
+	var watcher watch.Interface
+	opts := metav1.ListOptions{
+		FieldSelector:   fmt.Sprintf("metadata.name=%s", target.name),
+		ResourceVersion: target.resourceVersion,
+	}
+	err := k.newInstance().WithContext(ctx).GVK(target.gvk.Group, target.gvk.Version, target.gvk.Kind).Namespace(ns).Watch(&watcher, opts).Error
+	if err != nil || watcher == nil {
+		klog.V(6).Infof("watch %s %s/%s error: %v", target.gvk.Kind, ns, target.name, err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(readyResyncPeriod):
+			return true
+		}
+	}
+	defer watcher.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-watcher.ResultChan():
+		return true
+	case <-time.After(readyResyncPeriod):
+		return true
+	}
+}
+
+// evaluateReady 判断资源是否就绪，返回就绪状态、状态说明；资源已失败时返回错误
+func (k *Kubectl) evaluateReady(ctx context.Context, obj *unstructured.Unstructured, target *readyTarget) (bool, string, error) {
+	gvk := obj.GroupVersionKind()
+	if observed, found, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration"); found && observed < obj.GetGeneration() {
+		return false, fmt.Sprintf("waiting for generation %d to be observed, current %d", obj.GetGeneration(), observed), nil
+	}
+	if isCRD(gvk) {
+		if crdEstablished(obj) {
+			return true, "established", nil
+		}
+		return false, "waiting for established", nil
+	}
+	switch gvk.GroupKind() {
+	case schema.GroupKind{Group: "apps", Kind: "Deployment"}:
+		return deploymentReady(obj)
+	case schema.GroupKind{Group: "apps", Kind: "StatefulSet"}:
+		return statefulSetReady(obj)
+	case schema.GroupKind{Group: "apps", Kind: "DaemonSet"}:
+		return daemonSetReady(obj)
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		return jobReady(obj)
+	case schema.GroupKind{Kind: "PersistentVolumeClaim"}:
+		phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+		if phase == string(v1.ClaimBound) {
+			return true, "bound", nil
+		}
+		return false, fmt.Sprintf("phase is %s", phase), nil
+	case schema.GroupKind{Kind: "Service"}:
+		return k.serviceReady(ctx, obj, target)
+	case schema.GroupKind{Kind: "Pod"}:
+		return podReady(obj)
+	case schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}, schema.GroupKind{Kind: "ReplicationController"}:
+		replicas := specReplicas(obj)
+		ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+		if ready < replicas {
+			return false, fmt.Sprintf("%d of %d pods are ready", ready, replicas), nil
+		}
+		return true, "all pods are ready", nil
+	case schema.GroupKind{Kind: "Namespace"}:
+		return phaseReady(obj, string(v1.NamespaceActive))
+	case schema.GroupKind{Kind: "PersistentVolume"}:
+		return phaseReady(obj, string(v1.VolumeAvailable), string(v1.VolumeBound))
+	}
+	if _, ok := readyWithoutStatusKinds[gvk.GroupKind()]; ok {
+		return true, "created", nil
+	}
+	return conditionReady(obj)
+}
+
+// readyWithoutStatusKinds 没有状态或状态不表示就绪的内置资源，创建后即视为就绪
+var readyWithoutStatusKinds = map[schema.GroupKind]struct{}{
+	{Kind: "ConfigMap"}:      {},
+	{Kind: "Secret"}:         {},
+	{Kind: "ServiceAccount"}: {},
+	{Kind: "Endpoints"}:      {},
+	{Kind: "LimitRange"}:     {},
+	{Kind: "ResourceQuota"}:  {},
+	{Kind: "PodTemplate"}:    {},
+	{Kind: "Event"}:          {},
+	{Group: "rbac.authorization.k8s.io", Kind: "Role"}:                              {},
+	{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}:                       {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}:                       {},
+	{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}:                {},
+	{Group: "networking.k8s.io", Kind: "NetworkPolicy"}:                             {},
+	{Group: "networking.k8s.io", Kind: "Ingress"}:                                   {},
+	{Group: "networking.k8s.io", Kind: "IngressClass"}:                              {},
+	{Group: "discovery.k8s.io", Kind: "EndpointSlice"}:                              {},
+	{Group: "storage.k8s.io", Kind: "StorageClass"}:                                 {},
+	{Group: "storage.k8s.io", Kind: "CSIDriver"}:                                    {},
+	{Group: "scheduling.k8s.io", Kind: "PriorityClass"}:                             {},
+	{Group: "node.k8s.io", Kind: "RuntimeClass"}:                                    {},
+	{Group: "policy", Kind: "PodDisruptionBudget"}:                                  {},
+	{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}:                         {},
+	{Group: "batch", Kind: "CronJob"}:                                               {},
+	{Group: "admissionregistration.k8s.io", Kind: "MutatingWebhookConfiguration"}:   {},
+	{Group: "admissionregistration.k8s.io", Kind: "ValidatingWebhookConfiguration"}: {},
+}
+
+// podReady Ready 条件为 True 或已运行完成，新建尚未调度的 Pod 没有 Ready 条件，视为未就绪
+func podReady(obj *unstructured.Unstructured) (bool, string, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	switch phase {
+	case string(v1.PodSucceeded):
+		return true, "succeeded", nil
+	case string(v1.PodFailed):
+		reason, _, _ := unstructured.NestedString(obj.Object, "status", "reason")
+		message, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+		return false, "failed", fmt.Errorf("pod failed: %s %s", reason, message)
+	}
+	c := findCondition(obj, string(v1.PodReady))
+	if c.Status == string(metav1.ConditionTrue) {
+		return true, "ready", nil
+	}
+	if c.Type == "" {
+		if phase == "" {
+			phase = string(v1.PodPending)
+		}
+		return false, fmt.Sprintf("phase is %s", phase), nil
+	}
+	return false, c.message(), nil
+}
+
+// phaseReady status.phase 为指定值之一时就绪
+func phaseReady(obj *unstructured.Unstructured, phases ...string) (bool, string, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	for _, p := range phases {
+		if phase == p {
+			return true, strings.ToLower(phase), nil
+		}
+	}
+	return false, fmt.Sprintf("phase is %s", phase), nil
+}
+
+// deploymentReady 与 kubectl rollout status 的判断一致
+func deploymentReady(obj *unstructured.Unstructured) (bool, string, error) {
+	if c := findCondition(obj, "Progressing"); c.Reason == "ProgressDeadlineExceeded" {
+		return false, c.message(), fmt.Errorf("deployment exceeded its progress deadline: %s", c.message())
+	}
+	replicas := specReplicas(obj)
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	current, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	switch {
+	case updated < replicas:
+		return false, fmt.Sprintf("%d out of %d new replicas have been updated", updated, replicas), nil
+	case current > updated:
+		return false, fmt.Sprintf("%d old replicas are pending termination", current-updated), nil
+	case available < updated:
+		return false, fmt.Sprintf("%d of %d updated replicas are available", available, updated), nil
+	}
+	return true, "successfully rolled out", nil
+}
+
+func statefulSetReady(obj *unstructured.Unstructured) (bool, string, error) {
+	replicas := specReplicas(obj)
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	if ready < replicas {
+		return false, fmt.Sprintf("%d of %d pods are ready", ready, replicas), nil
+	}
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy == "OnDelete" {
+		return true, "all pods are ready", nil
+	}
+	if partition, found, _ := unstructured.NestedInt64(obj.Object, "spec", "updateStrategy", "rollingUpdate", "partition"); found && partition > 0 {
+		updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+		if updated < replicas-partition {
+			return false, fmt.Sprintf("%d of %d pods have been updated", updated, replicas-partition), nil
+		}
+		return true, "partitioned roll out complete", nil
+	}
+	currentRevision, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	updateRevision, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	if currentRevision != updateRevision {
+		return false, fmt.Sprintf("waiting for rolling update to complete, pods at revision %s", updateRevision), nil
+	}
+	return true, "successfully rolled out", nil
+}
+
+func daemonSetReady(obj *unstructured.Unstructured) (bool, string, error) {
+	desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+	if updated < desired {
+		return false, fmt.Sprintf("%d out of %d new pods have been updated", updated, desired), nil
+	}
+	if available < desired {
+		return false, fmt.Sprintf("%d of %d updated pods are available", available, desired), nil
+	}
+	return true, "successfully rolled out", nil
+}
+
+func jobReady(obj *unstructured.Unstructured) (bool, string, error) {
+	if c := findCondition(obj, "Failed"); c.Status == string(metav1.ConditionTrue) {
+		return false, c.message(), fmt.Errorf("job failed: %s", c.message())
+	}
+	if c := findCondition(obj, "Complete"); c.Status == string(metav1.ConditionTrue) {
+		return true, "completed", nil
+	}
+	active, _, _ := unstructured.NestedInt64(obj.Object, "status", "active")
+	succeeded, _, _ := unstructured.NestedInt64(obj.Object, "status", "succeeded")
+	return false, fmt.Sprintf("%d active, %d succeeded", active, succeeded), nil
+}
+
+// serviceReady 检查同名 Endpoints 中是否存在可用地址，之后关注 Endpoints 的变化
+func (k *Kubectl) serviceReady(ctx context.Context, obj *unstructured.Unstructured, target *readyTarget) (bool, string, error) {
+	svcType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if svcType == string(v1.ServiceTypeExternalName) {
+		return true, "external name service", nil
+	}
+	if selector, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "selector"); len(selector) == 0 {
+		return true, "service without selector", nil
+	}
+	*target = readyTarget{gvk: v1.SchemeGroupVersion.WithKind("Endpoints"), name: obj.GetName()}
+	var endpoints v1.Endpoints
+	err := k.newInstance().WithContext(ctx).Resource(&endpoints).Namespace(obj.GetNamespace()).Name(obj.GetName()).Get(&endpoints).Error
+	if apierrors.IsNotFound(err) {
+		return false, "endpoints not found", nil
+	}
+	if err != nil {
+		return false, err.Error(), nil
+	}
+	target.resourceVersion = endpoints.ResourceVersion
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return true, fmt.Sprintf("%d ready endpoints", len(subset.Addresses)), nil
+		}
+	}
+	return false, "no ready endpoints", nil
+}
+
+// conditionReady 通用资源：存在 Ready 条件时要求其为 True，
+// 没有 Ready 条件时要求控制器已写入 status.observedGeneration（调用前已检查其不小于 metadata.generation）
+func conditionReady(obj *unstructured.Unstructured) (bool, string, error) {
+	c := findCondition(obj, "Ready")
+	if c.Type != "" {
+		if c.Status == string(metav1.ConditionTrue) {
+			return true, "ready", nil
+		}
+		return false, c.message(), nil
+	}
+	status, _, _ := unstructured.NestedMap(obj.Object, "status")
+	if len(status) == 0 {
+		return false, "waiting for status", nil
+	}
+	if _, found := status["observedGeneration"]; found {
+		return true, "generation observed", nil
+	}
+	return false, "waiting for ready condition", nil
+}
+
+func specReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		// 未设置时默认为 1
+		return 1
+	}
+	return replicas
+}
+
+type readyCondition struct {
+	Type    string
+	Status  string
+	Reason  string
+	Message string
+}
+
+func (c readyCondition) message() string {
+	if c.Type == "" {
+		return ""
+	}
+	s := fmt.Sprintf("%s=%s", c.Type, c.Status)
+	if c.Reason != "" {
+		s += " " + c.Reason
+	}
+	if c.Message != "" {
+		s += ": " + c.Message
+	}
+	return s
+}
+
+// findCondition 查找 status.conditions 中指定类型的条件，不存在时返回空值
+func findCondition(obj *unstructured.Unstructured, conditionType string) readyCondition {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+		result := readyCondition{Type: conditionType}
+		result.Status, _ = condition["status"].(string)
+		result.Reason, _ = condition["reason"].(string)
+		result.Message, _ = condition["message"].(string)
+		return result
+	}
+	return readyCondition{}
+}
@@ -0,0 +1,183 @@
+package kom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWaitReadyWatchesChanges(t *testing.T) {
+	replicas := int32(2)
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1},
+	}
+	k := RegisterFakeCluster("wait-ready-cluster", deploy)
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		var d appsv1.Deployment
+		if err := k.Resource(&d).Namespace("default").Name("web").Get(&d).Error; err != nil {
+			return
+		}
+		d.Status.AvailableReplicas = 2
+		d.Status.ReadyReplicas = 2
+		k.Resource(&d).Update(&d)
+	}()
+
+	start := time.Now()
+	status, err := k.Resource(&appsv1.Deployment{}).Namespace("default").Name("web").WaitReady(10 * time.Second)
+	if err != nil {
+		t.Fatalf("wait ready failed: %v", err)
+	}
+	if !status.Ready || status.Message != "successfully rolled out" {
+		t.Errorf("unexpected status %s", status)
+	}
+	// 通过 Watch 感知变化，不需要等到兜底检查
+	if elapsed := time.Since(start); elapsed >= readyResyncPeriod {
+		t.Errorf("wait ready should be notified by watch, took %s", elapsed)
+	}
+}
+
+func TestWaitReadyTimeoutReport(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+		Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+	k := RegisterFakeCluster("wait-ready-timeout-cluster", pvc)
+
+	status, err := k.Resource(&v1.PersistentVolumeClaim{}).Namespace("default").Name("data").WaitReady(300 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if status.Ready || status.Message != "phase is Pending" {
+		t.Errorf("unexpected status %s", status)
+	}
+	if !strings.Contains(err.Error(), "PersistentVolumeClaim default/data not ready: phase is Pending") {
+		t.Errorf("error should contain object status, got %v", err)
+	}
+}
+
+func TestWaitReadyPerKind(t *testing.T) {
+	failedJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "failed", Namespace: "default"},
+		Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+			{Type: batchv1.JobFailed, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded"},
+		}},
+	}
+	svc := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       v1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	endpoints := &v1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Subsets:    []v1.EndpointSubset{{Addresses: []v1.EndpointAddress{{IP: "10.0.0.1"}}}},
+	}
+	k := RegisterFakeCluster("wait-ready-kind-cluster", failedJob, svc, endpoints)
+
+	start := time.Now()
+	status, err := k.Resource(&batchv1.Job{}).Namespace("default").Name("failed").WaitReady(10 * time.Second)
+	if err == nil || status.Error == nil || !strings.Contains(status.Error.Error(), "BackoffLimitExceeded") {
+		t.Errorf("failed job should return error, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("failed job should not wait until timeout")
+	}
+
+	status, err = k.Resource(&v1.Service{}).Namespace("default").Name("web").WaitReady(time.Second)
+	if err != nil || !status.Ready {
+		t.Errorf("service with endpoints should be ready, got %v %s", err, status)
+	}
+}
+
+func TestApplyAndWait(t *testing.T) {
+	k := RegisterFakeCluster("apply-and-wait-cluster")
+	manifest := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+  namespace: default
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: data
+  namespace: default
+status:
+  phase: Pending
+`
+	results, err := k.Applier().ApplyAndWait(manifest, 300*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "PersistentVolumeClaim default/data") {
+		t.Fatalf("expected pvc timeout, got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Ready == nil {
+			t.Fatalf("result %s should have ready status", r)
+		}
+		switch r.GVK.Kind {
+		case "ConfigMap":
+			if !r.Ready.Ready {
+				t.Errorf("configmap should be ready, got %s", r.Ready)
+			}
+		case "PersistentVolumeClaim":
+			if r.Ready.Ready {
+				t.Errorf("pvc should not be ready, got %s", r.Ready)
+			}
+		}
+	}
+}
+
+func TestWaitReadyPodAndCustomResource(t *testing.T) {
+	pending := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "default"},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+	running := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "default"},
+		Status: v1.PodStatus{Phase: v1.PodRunning, Conditions: []v1.PodCondition{
+			{Type: v1.PodReady, Status: v1.ConditionTrue},
+		}},
+	}
+	fresh := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "MyApp",
+		"metadata":   map[string]interface{}{"name": "fresh", "namespace": "default", "generation": int64(1)},
+	}}
+	observed := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "MyApp",
+		"metadata":   map[string]interface{}{"name": "observed", "namespace": "default", "generation": int64(1)},
+		"status":     map[string]interface{}{"observedGeneration": int64(1)},
+	}}
+	k := RegisterFakeCluster("wait-ready-pod-cluster", pending, running, fresh, observed)
+
+	status, err := k.Resource(&v1.Pod{}).Namespace("default").Name("pending").WaitReady(300 * time.Millisecond)
+	if err == nil || status.Ready || status.Message != "phase is Pending" {
+		t.Errorf("pending pod should not be ready, got %v %s", err, status)
+	}
+	status, err = k.Resource(&v1.Pod{}).Namespace("default").Name("running").WaitReady(time.Second)
+	if err != nil || !status.Ready {
+		t.Errorf("running pod should be ready, got %v %s", err, status)
+	}
+
+	// 控制器尚未写入状态的自定义资源
+	status, err = k.GVK("example.com", "v1", "MyApp").Namespace("default").Name("fresh").WaitReady(300 * time.Millisecond)
+	if err == nil || status.Ready || status.Message != "waiting for status" {
+		t.Errorf("custom resource without status should not be ready, got %v %s", err, status)
+	}
+	status, err = k.GVK("example.com", "v1", "MyApp").Namespace("default").Name("observed").WaitReady(time.Second)
+	if err != nil || !status.Ready {
+		t.Errorf("observed custom resource should be ready, got %v %s", err, status)
+	}
+}
@@ -0,0 +1,41 @@
+package pod
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/weibaohui/kom/kom"
+	"github.com/weibaohui/kom/mcp/tools"
+	v1 "k8s.io/api/core/v1"
+)
+
+func DiagnosePod() mcp.Tool {
+	return mcp.NewTool(
+		"diagnose_k8s_pod",
+		mcp.WithDescription("诊断Pod异常原因，根据Pod状态、容器状态、事件及节点状态给出诊断结果、严重程度及处理建议。覆盖CrashLoopBackOff、OOMKilled、镜像拉取失败、无法调度、探针失败、存储卷挂载失败及节点异常"),
+		mcp.WithTitleAnnotation("Diagnose Pod"),
+		mcp.WithReadOnlyHintAnnotation(true),
+		mcp.WithString("cluster", mcp.Description("Pod所在集群（使用空字符串表示默认集群）")),
+		mcp.WithString("namespace", mcp.Required(), mcp.Description("Pod所在的命名空间")),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Pod名称")),
+	)
+}
+
+func DiagnosePodHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// 获取资源元数据
+	ctx, meta, err := tools.ParseFromRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := kom.Cluster(meta.Cluster).WithContext(ctx).
+		Resource(&v1.Pod{}).
+		Namespace(meta.Namespace).
+		Name(meta.Name).
+		Ctl().Pod().Diagnose()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diagnose pod [%s/%s]: %v", meta.Namespace, meta.Name, err)
+	}
+	return tools.TextResult(report, meta)
+}
@@ -2,21 +2,19 @@ package pod
 
 import (
 	"context"
-	"io"
+	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/weibaohui/kom/kom"
 	"github.com/weibaohui/kom/mcp/tools"
-	utils2 "github.com/weibaohui/kom/utils"
-
-	v1 "k8s.io/api/core/v1"
 )
 
 // GetPodLogsTool 创建一个获取Pod日志的工具
 func GetPodLogsTool() mcp.Tool {
 	return mcp.NewTool(
 		"get_k8s_pod_logs",
-		mcp.WithDescription("获取Pod日志，通过集群、命名空间和名称，可限制返回行数 (类似命令: kubectl logs [-f] [-p] [-c container] [-n namespace] <pod-name> [--tail=N]) / Get pod logs by cluster, namespace and name with tail lines limit"),
+		mcp.WithDescription("获取Pod日志，通过集群、命名空间和名称，可限制返回行数、时间范围，并支持正则及JSON字段过滤 (类似命令: kubectl logs [-p] [-c container] [-n namespace] <pod-name> [--tail=N] [--since=1h] | grep -C N pattern) / Get pod logs by cluster, namespace and name with tail lines, time window, regex and JSON field filters"),
 		mcp.WithTitleAnnotation("Get Pod Logs"),
 		mcp.WithReadOnlyHintAnnotation(true),
 		mcp.WithString("cluster", mcp.Description("运行Pod的集群 （使用空字符串表示默认集群） （使用空字符串表示默认集群）/ The cluster runs the pod")),
@@ -25,6 +23,14 @@ func GetPodLogsTool() mcp.Tool {
 		mcp.WithString("container", mcp.Description("Pod中容器的名称(如果Pod中有多个容器则必须指定,只有一个容器时可以为空) / Name of the container in the pod (must be specified if there are more than one container in Pod, only one container could use empty string)")),
 		mcp.WithNumber("tail", mcp.Description("显示日志末尾的行数(默认100行) / Number of lines from the end of the logs to show (default 100)")),
 		mcp.WithBoolean("previous", mcp.Description("是否获取上一个容器的日志(默认false) / Whether to get logs from the previous container (default false)")),
+		mcp.WithString("since", mcp.Description("只获取最近一段时间的日志，如 10m、1h / Only return logs newer than a relative duration like 10m or 1h")),
+		mcp.WithString("since_time", mcp.Description("只获取该时间之后的日志(RFC3339格式) / Only return logs after this time (RFC3339)")),
+		mcp.WithString("until_time", mcp.Description("只获取该时间及之前的日志(RFC3339格式) / Only return logs up to this time (RFC3339)")),
+		mcp.WithNumber("limit_bytes", mcp.Description("API Server 返回的最大字节数 / Maximum bytes of logs returned by the API server")),
+		mcp.WithString("include", mcp.Description("只保留匹配该正则表达式的行 / Only keep lines matching this regular expression")),
+		mcp.WithString("exclude", mcp.Description("排除匹配该正则表达式的行 / Drop lines matching this regular expression")),
+		mcp.WithNumber("context", mcp.Description("输出匹配行前后的行数(类似 grep -C) / Number of lines around each matching line (like grep -C)")),
+		mcp.WithString("where", mcp.Description("按JSON日志字段过滤，语法同SQL Where，如 level='error' and latency>500 / Filter JSON log fields with SQL where syntax, e.g. level='error' and latency>500")),
 	)
 }
 
@@ -36,23 +42,55 @@ func GetPodLogsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.C
 		return nil, err
 	}
 
-	tailLines := int64(request.GetInt("tail", 100))
-	previous := request.GetBool("previous", false)
+	opts := []kom.LogQueryOption{kom.LogQueryTailLines(int64(request.GetInt("tail", 100)))}
+	if request.GetBool("previous", false) {
+		opts = append(opts, kom.LogQueryPrevious())
+	}
+	if since := request.GetString("since", ""); since != "" {
+		d, err := time.ParseDuration(since)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since %q: %w", since, err)
+		}
+		opts = append(opts, kom.LogQuerySince(d))
+	}
+	if sinceTime := request.GetString("since_time", ""); sinceTime != "" {
+		t, err := time.Parse(time.RFC3339, sinceTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid since_time %q: %w", sinceTime, err)
+		}
+		opts = append(opts, kom.LogQuerySinceTime(t))
+	}
+	if untilTime := request.GetString("until_time", ""); untilTime != "" {
+		t, err := time.Parse(time.RFC3339, untilTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid until_time %q: %w", untilTime, err)
+		}
+		opts = append(opts, kom.LogQueryUntil(t))
+	}
+	if limitBytes := request.GetInt("limit_bytes", 0); limitBytes > 0 {
+		opts = append(opts, kom.LogQueryLimitBytes(int64(limitBytes)))
+	}
+	if include := request.GetString("include", ""); include != "" {
+		opts = append(opts, kom.LogQueryInclude(include))
+	}
+	if exclude := request.GetString("exclude", ""); exclude != "" {
+		opts = append(opts, kom.LogQueryExclude(exclude))
+	}
+	if lines := request.GetInt("context", 0); lines > 0 {
+		opts = append(opts, kom.LogQueryContext(lines, lines))
+	}
+	if where := request.GetString("where", ""); where != "" {
+		opts = append(opts, kom.LogQueryWhere(where))
+	}
+
 	containerName := request.GetString("container", "")
-	var stream io.ReadCloser
-	opt := &v1.PodLogOptions{}
-	opt.TailLines = utils2.Ptr(tailLines)
-	// 设置是否获取上一个容器的日志
-	opt.Previous = previous
-	err = kom.Cluster(meta.Cluster).WithContext(ctx).Namespace(meta.Namespace).Name(meta.Name).Ctl().Pod().ContainerName(containerName).GetLogs(&stream, opt).Error
+	result, err := kom.Cluster(meta.Cluster).WithContext(ctx).Namespace(meta.Namespace).Name(meta.Name).Ctl().Pod().ContainerName(containerName).QueryLogs(opts...)
 	if err != nil {
 		return nil, err
 	}
-	// 读取所有日志内容
-	var logs []byte
-	logs, err = io.ReadAll(stream)
-	if err != nil {
-		return nil, err
+	logs := result.String()
+	if result.Truncated {
+		logs += "\n(日志已达到 limit_bytes 限制被截断 / logs truncated by limit_bytes)\n"
 	}
-	return tools.TextResult(string(logs), meta)
+	return tools.TextResult(logs, meta)
 }
@@ -71,6 +71,10 @@ func RegisterTools(s *server.MCPServer) {
 		DescribePod(),
 		DescribePodHandler)
 
+	s.AddTool(
+		DiagnosePod(),
+		DiagnosePodHandler)
+
 	s.AddTool(
 		ListPodEventResource(),
 		ListPodEventResourceHandler)
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weibaohui/kom/kom"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// 处理器名称，与 kom callback 的处理器保持一致
+const (
+	VerbGet         = "get"
+	VerbList        = "list"
+	VerbCreate      = "create"
+	VerbUpdate      = "update"
+	VerbPatch       = "patch"
+	VerbDelete      = "delete"
+	VerbExec        = "exec"
+	VerbStreamExec  = "stream-exec"
+	VerbLogs        = "logs"
+	VerbWatch       = "watch"
+	VerbDescribe    = "describe"
+	VerbDoc         = "doc"
+	VerbPortForward = "port-forward"
+
+	// Ctl 高层操作
+	VerbDrain     = "drain"
+	VerbCordon    = "cordon"
+	VerbTaint     = "taint"
+	VerbRollout   = "rollout"
+	VerbScale     = "scale"
+	VerbImage     = "image"
+	VerbNodeShell = "node-shell"
+	VerbTerminal  = "terminal"
+	VerbDebug     = "debug"
+)
+
+// DefaultVerbs 默认统计所有处理器
+var DefaultVerbs = []string{
+	VerbGet, VerbList, VerbCreate, VerbUpdate, VerbPatch, VerbDelete, VerbExec, VerbStreamExec,
+	VerbLogs, VerbWatch, VerbDescribe, VerbDoc, VerbPortForward,
+	VerbDrain, VerbCordon, VerbTaint, VerbRollout, VerbScale, VerbImage, VerbNodeShell, VerbTerminal, VerbDebug,
+}
+
+// 错误原因中 kom 自身定义的部分，其余取自 API Server 返回的 StatusReason
+const (
+	ReasonUnknown             = "Unknown"
+	ReasonTimeout             = "Timeout"
+	ReasonCanceled            = "Canceled"
+	ReasonReadOnly            = "ReadOnly"
+	ReasonNamespaceNotAllowed = "NamespaceNotAllowed"
+)
+
+var operationLabels = []string{"cluster", "verb", "group", "version", "resource"}
+
+// Metrics 操作指标采集器，实现了 prometheus.Collector
+// 同一个采集器可注册到多个集群，通过 cluster 标签区分
+type Metrics struct {
+	verbs []string
+
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	tokens     *prometheus.CounterVec
+
+	cacheHits   *prometheus.Desc
+	cacheMisses *prometheus.Desc
+	cacheRatio  *prometheus.Desc
+
+	clusters sync.Map // map[string]struct{} 已注册的集群
+}
+
+type config struct {
+	namespace string
+	buckets   []float64
+	verbs     []string
+}
+
+// Option 采集器配置项
+type Option func(*config)
+
+// WithNamespace 设置指标名称前缀，默认为 kom
+func WithNamespace(ns string) Option {
+	return func(c *config) { c.namespace = ns }
+}
+
+// WithBuckets 设置耗时直方图的分桶，默认为 prometheus.DefBuckets
+func WithBuckets(buckets ...float64) Option {
+	return func(c *config) { c.buckets = buckets }
+}
+
+// WithVerbs 设置需要统计的处理器，默认为 DefaultVerbs
+func WithVerbs(verbs ...string) Option {
+	return func(c *config) { c.verbs = verbs }
+}
+
+// New 创建采集器，需自行注册到 prometheus，再调用 Register 挂载到集群
+// Example:
+// m := metrics.New()
+// prometheus.MustRegister(m)
+// err := m.Register(kom.Cluster("default"))
+func New(opts ...Option) *Metrics {
+	c := &config{
+		namespace: "kom",
+		buckets:   prometheus.DefBuckets,
+		verbs:     DefaultVerbs,
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+	return &Metrics{
+		verbs: c.verbs,
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Name:      "operations_total",
+			Help:      "Total number of kom operations by cluster, verb and GVR.",
+		}, operationLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Name:      "operation_errors_total",
+			Help:      "Total number of failed kom operations by cluster, verb, GVR and reason.",
+		}, append(append([]string{}, operationLabels...), "reason")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: c.namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of kom operations by cluster, verb and GVR.",
+			Buckets:   c.buckets,
+		}, operationLabels),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: c.namespace,
+			Name:      "eks_token_refresh_total",
+			Help:      "Total number of EKS token refreshes by cluster and outcome.",
+		}, []string{"cluster", "outcome"}),
+		cacheHits: prometheus.NewDesc(prometheus.BuildFQName(c.namespace, "cache", "hits_total"),
+			"Total number of cluster cache hits.", []string{"cluster"}, nil),
+		cacheMisses: prometheus.NewDesc(prometheus.BuildFQName(c.namespace, "cache", "misses_total"),
+			"Total number of cluster cache misses.", []string{"cluster"}, nil),
+		cacheRatio: prometheus.NewDesc(prometheus.BuildFQName(c.namespace, "cache", "hit_ratio"),
+			"Cluster cache hit ratio.", []string{"cluster"}, nil),
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.operations.Describe(ch)
+	m.errors.Describe(ch)
+	m.duration.Describe(ch)
+	m.tokens.Describe(ch)
+	ch <- m.cacheHits
+	ch <- m.cacheMisses
+	ch <- m.cacheRatio
+}
+
+// Collect 实现 prometheus.Collector
+// 缓存命中率在采集时从各集群的缓存统计中实时读取
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.operations.Collect(ch)
+	m.errors.Collect(ch)
+	m.duration.Collect(ch)
+	m.tokens.Collect(ch)
+	m.clusters.Range(func(key, _ any) bool {
+		id := key.(string)
+		cluster := kom.Clusters().GetClusterById(id)
+		if cluster == nil || cluster.Cache == nil || cluster.Cache.Metrics == nil {
+			return true
+		}
+		stats := cluster.Cache.Metrics
+		ch <- prometheus.MustNewConstMetric(m.cacheHits, prometheus.CounterValue, float64(stats.Hits()), id)
+		ch <- prometheus.MustNewConstMetric(m.cacheMisses, prometheus.CounterValue, float64(stats.Misses()), id)
+		ch <- prometheus.MustNewConstMetric(m.cacheRatio, prometheus.GaugeValue, stats.Ratio(), id)
+		return true
+	})
+}
+
+// Register 将采集回调注册到指定集群
+// 在收尾回调中记录次数、耗时及错误，开始时间取自 Statement.StartedAt，因此失败的操作同样会被统计
+// EKS 集群还会统计 token 刷新结果
+func (m *Metrics) Register(k *kom.Kubectl) error {
+	if k == nil {
+		return fmt.Errorf("metrics register: kubectl is nil")
+	}
+	cb := k.Callback()
+	if cb == nil {
+		return fmt.Errorf("metrics register: cluster %s callbacks not initialized", k.ID)
+	}
+	for _, verb := range m.verbs {
+		p := cb.Processor(verb)
+		if p == nil {
+			return fmt.Errorf("metrics register: unsupported verb %s", verb)
+		}
+		if err := p.Finally().Register("kom:metrics", m.Finish(verb)); err != nil {
+			return err
+		}
+	}
+
+	if _, loaded := m.clusters.LoadOrStore(k.ID, struct{}{}); !loaded {
+		if cluster := kom.Clusters().GetClusterById(k.ID); cluster != nil && cluster.IsEKSCluster() {
+			id := k.ID
+			cluster.OnTokenRefresh(func(err error) {
+				outcome := "success"
+				if err != nil {
+					outcome = "failure"
+				}
+				m.tokens.WithLabelValues(id, outcome).Inc()
+			})
+		}
+	}
+	return nil
+}
+
+// Finish 返回记录指标的收尾回调
+func (m *Metrics) Finish(verb string) func(k *kom.Kubectl) error {
+	return func(k *kom.Kubectl) error {
+		gvr := k.Statement.GVR
+		labels := []string{k.ID, verb, gvr.Group, gvr.Version, gvr.Resource}
+		m.operations.WithLabelValues(labels...).Inc()
+		if start := k.Statement.StartedAt(); !start.IsZero() {
+			m.duration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		}
+		if k.Error != nil {
+			m.errors.WithLabelValues(append(labels, Reason(k.Error))...).Inc()
+		}
+		return nil
+	}
+}
+
+// Reason 将错误归类为有限的原因，避免标签基数过高
+func Reason(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, kom.ErrClusterReadOnly):
+		return ReasonReadOnly
+	case errors.Is(err, kom.ErrNamespaceNotAllowed):
+		return ReasonNamespaceNotAllowed
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout
+	case errors.Is(err, context.Canceled):
+		return ReasonCanceled
+	}
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+	return ReasonUnknown
+}
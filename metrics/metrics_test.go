@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/weibaohui/kom/kom"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newStatementKubectl() *kom.Kubectl {
+	k := &kom.Kubectl{ID: "metrics-cluster"}
+	k.Statement = &kom.Statement{
+		Kubectl: k,
+		Context: context.Background(),
+		GVR:     schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+	}
+	return k
+}
+
+func TestMetricsFinish(t *testing.T) {
+	m := New()
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(m); err != nil {
+		t.Fatalf("register collector failed: %v", err)
+	}
+
+	k := newStatementKubectl()
+	_ = m.Finish(VerbGet)(k)
+
+	k = newStatementKubectl()
+	k.Error = apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "demo")
+	_ = m.Finish(VerbGet)(k)
+
+	if v := testutil.ToFloat64(m.operations.WithLabelValues("metrics-cluster", VerbGet, "apps", "v1", "deployments")); v != 2 {
+		t.Errorf("expected 2 operations, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.errors.WithLabelValues("metrics-cluster", VerbGet, "apps", "v1", "deployments", "NotFound")); v != 1 {
+		t.Errorf("expected 1 NotFound error, got %v", v)
+	}
+	// 未经处理器执行时没有开始时间，不记录耗时
+	if n := testutil.CollectAndCount(m.duration); n != 0 {
+		t.Errorf("expected no histogram series, got %d", n)
+	}
+
+	expected := `
+# HELP kom_operation_errors_total Total number of failed kom operations by cluster, verb, GVR and reason.
+# TYPE kom_operation_errors_total counter
+kom_operation_errors_total{cluster="metrics-cluster",group="apps",reason="NotFound",resource="deployments",verb="get",version="v1"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "kom_operation_errors_total"); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+}
+
+func TestReason(t *testing.T) {
+	cases := map[string]error{
+		"":                        nil,
+		ReasonReadOnly:            fmt.Errorf("denied: %w", kom.ErrClusterReadOnly),
+		ReasonNamespaceNotAllowed: fmt.Errorf("denied: %w", kom.ErrNamespaceNotAllowed),
+		ReasonTimeout:             fmt.Errorf("get: %w", context.DeadlineExceeded),
+		ReasonCanceled:            context.Canceled,
+		"Conflict":                apierrors.NewConflict(schema.GroupResource{Resource: "pods"}, "p", nil),
+		ReasonUnknown:             fmt.Errorf("boom"),
+	}
+	for want, err := range cases {
+		if got := Reason(err); got != want {
+			t.Errorf("Reason(%v) = %s, want %s", err, got, want)
+		}
+	}
+}
+
+func TestMetricsRegisterNil(t *testing.T) {
+	if err := New().Register(nil); err == nil {
+		t.Errorf("expected error for nil kubectl")
+	}
+}
@@ -33,3 +33,15 @@ func (lm *LabelsManager) AddCustomLabel(meta *metav1.ObjectMeta, key, value stri
 	}
 	meta.Labels[key] = value
 }
+
+// AddLabelsToObject 给实现了 metav1.Object 的对象（如 Unstructured）添加共享标签
+func (lm *LabelsManager) AddLabelsToObject(obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for k, v := range lm.Labels {
+		labels[k] = v
+	}
+	obj.SetLabels(labels)
+}